@@ -31,6 +31,7 @@ type Expression interface {
 // Program is the root of every Glace AST — a list of statements.
 type Program struct {
 	Statements []Statement
+	Comments   []lexer.Comment // every comment in the source, in order
 }
 
 func (p *Program) TokenPos() lexer.Position {
@@ -50,6 +51,7 @@ type LetStatement struct {
 	Pos   lexer.Position
 	Name  string
 	Value Expression
+	Doc   string // from /// comments directly above, without the slashes
 }
 
 func (s *LetStatement) stmtNode()                  {}
@@ -90,6 +92,18 @@ func (s *IndexAssignStatement) stmtNode()                {}
 func (s *IndexAssignStatement) TokenPos() lexer.Position { return s.Pos }
 func (s *IndexAssignStatement) String() string           { return "IndexAssignStatement" }
 
+// FieldAssignStatement: obj.field = <expr>
+type FieldAssignStatement struct {
+	Pos   lexer.Position
+	Left  Expression // the object expression
+	Field string
+	Value Expression
+}
+
+func (s *FieldAssignStatement) stmtNode()                {}
+func (s *FieldAssignStatement) TokenPos() lexer.Position { return s.Pos }
+func (s *FieldAssignStatement) String() string           { return "FieldAssignStatement(." + s.Field + ")" }
+
 // ExpressionStatement wraps an expression used as a statement.
 type ExpressionStatement struct {
 	Pos        lexer.Position
@@ -114,6 +128,7 @@ func (s *ReturnStatement) String() string           { return "ReturnStatement" }
 type BlockStatement struct {
 	Pos        lexer.Position
 	Statements []Statement
+	End        lexer.Position // the closing '}'; zero for the body of a => form
 }
 
 func (s *BlockStatement) stmtNode()                {}
@@ -176,6 +191,8 @@ type FnDeclaration struct {
 	Name   string
 	Params []string
 	Body   *BlockStatement // block body
+	Arrow  bool            // written as => <expr>; Body holds a single return
+	Doc    string          // from /// comments directly above, without the slashes
 }
 
 func (s *FnDeclaration) stmtNode()                {}
@@ -187,6 +204,7 @@ type MatchStatement struct {
 	Pos     lexer.Position
 	Subject Expression
 	Arms    []MatchArm
+	End     lexer.Position // the closing '}'
 }
 
 // MatchArm: <pattern> [if <guard>] => <expr> | <block>
@@ -194,6 +212,7 @@ type MatchArm struct {
 	Pattern Expression     // literal, ident, range, or wildcard
 	Guard   Expression     // optional if-guard, may be nil
 	Body    *BlockStatement // the arm body
+	Arrow   bool            // the body is an expression; Body holds a single return
 }
 
 func (s *MatchStatement) stmtNode()                {}
@@ -211,6 +230,52 @@ func (s *TestBlock) stmtNode()                {}
 func (s *TestBlock) TokenPos() lexer.Position { return s.Pos }
 func (s *TestBlock) String() string           { return "TestBlock(" + s.Description + ")" }
 
+// DescribeBlock: describe "group" { ... }
+// The body holds tests, hooks, nested groups and the setup they share.
+type DescribeBlock struct {
+	Pos         lexer.Position
+	Description string
+	Body        *BlockStatement
+}
+
+func (s *DescribeBlock) stmtNode()                {}
+func (s *DescribeBlock) TokenPos() lexer.Position { return s.Pos }
+func (s *DescribeBlock) String() string           { return "DescribeBlock(" + s.Description + ")" }
+
+// HookBlock: before_each { ... }, after_each, before_all or after_all
+type HookBlock struct {
+	Pos  lexer.Position
+	Kind string // the keyword, e.g. "before_each"
+	Body *BlockStatement
+}
+
+func (s *HookBlock) stmtNode()                {}
+func (s *HookBlock) TokenPos() lexer.Position { return s.Pos }
+func (s *HookBlock) String() string           { return "HookBlock(" + s.Kind + ")" }
+
+// BenchBlock: bench "description" { ... }
+type BenchBlock struct {
+	Pos         lexer.Position
+	Description string
+	Body        *BlockStatement
+}
+
+func (s *BenchBlock) stmtNode()                {}
+func (s *BenchBlock) TokenPos() lexer.Position { return s.Pos }
+func (s *BenchBlock) String() string           { return "BenchBlock(" + s.Description + ")" }
+
+// BadStatement marks a statement the parser could not make sense of.
+// It spans the damaged source from Pos up to End, so tools can still
+// analyze the rest of the program.
+type BadStatement struct {
+	Pos lexer.Position
+	End lexer.Position
+}
+
+func (s *BadStatement) stmtNode()                {}
+func (s *BadStatement) TokenPos() lexer.Position { return s.Pos }
+func (s *BadStatement) String() string           { return "BadStatement" }
+
 // ---------------------------------------------------------------------------
 // Expressions
 // ---------------------------------------------------------------------------
@@ -355,6 +420,7 @@ func (e *SafeAccessExpression) String() string           { return "SafeAccess(?.
 type ArrayLiteral struct {
 	Pos      lexer.Position
 	Elements []Expression
+	End      lexer.Position // the closing ']'
 }
 
 func (e *ArrayLiteral) exprNode()                {}
@@ -366,6 +432,7 @@ type MapLiteral struct {
 	Pos    lexer.Position
 	Keys   []Expression
 	Values []Expression
+	End    lexer.Position // the closing '}'
 }
 
 func (e *MapLiteral) exprNode()                {}
@@ -377,6 +444,7 @@ type FnLiteral struct {
 	Pos    lexer.Position
 	Params []string
 	Body   *BlockStatement
+	Arrow  bool // written as => <expr>; Body holds a single return
 }
 
 func (e *FnLiteral) exprNode()                {}
@@ -425,3 +493,14 @@ type WildcardExpression struct {
 func (e *WildcardExpression) exprNode()                {}
 func (e *WildcardExpression) TokenPos() lexer.Position { return e.Pos }
 func (e *WildcardExpression) String() string           { return "Wildcard" }
+
+// BadExpression marks an expression the parser could not make sense of,
+// spanning from Pos up to End.
+type BadExpression struct {
+	Pos lexer.Position
+	End lexer.Position
+}
+
+func (e *BadExpression) exprNode()                {}
+func (e *BadExpression) TokenPos() lexer.Position { return e.Pos }
+func (e *BadExpression) String() string           { return "BadExpression" }
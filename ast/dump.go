@@ -0,0 +1,303 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/glace-lang/glace/lexer"
+)
+
+// JSONVersion is the version of the JSON format written by WriteJSON. It
+// changes only when existing consumers would break, i.e. when a node kind
+// or field is renamed or removed; new kinds and fields may appear at any
+// version.
+const JSONVersion = 1
+
+// The dumps below are built by reflection over the node structs, so every
+// field of every node appears without per-node code. Field names are the
+// Go field names with a lowercase first letter.
+
+// WriteJSON writes node as an indented JSON document:
+//
+//	{"version": 1, "ast": <node>}
+//
+// A node is an object whose "kind" is its Go type name (e.g.
+// "LetStatement"), followed by its fields in declaration order. Positions
+// are {"file", "line", "column"} objects; "pos" is the node's start and
+// Bad* nodes also have an "end". Child nodes are nested objects, lists are
+// arrays, and a missing child is null. Helper structs that are not nodes,
+// ElifClause, MatchArm and the Program's Comments, are objects without
+// "kind".
+func WriteJSON(w io.Writer, node Node) error {
+	var buf bytes.Buffer
+	buf.WriteString(`{"version":` + strconv.Itoa(JSONVersion) + `,"ast":`)
+	writeJSONValue(&buf, reflect.ValueOf(node))
+	buf.WriteString("}")
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+		return err
+	}
+	out.WriteString("\n")
+	_, err := out.WriteTo(w)
+	return err
+}
+
+// FormatTree renders node as an indented tree, one field per line:
+//
+//	LetStatement @1:1
+//	  name: "x"
+//	  value: IntegerLiteral @1:9
+//	    value: 1
+func FormatTree(node Node) string {
+	var b strings.Builder
+	writeTree(&b, reflect.ValueOf(node), 0)
+	return b.String()
+}
+
+// FormatSExpr renders node as an S-expression, one line per top-level
+// statement: (LetStatement @1:1 :name "x" :value (IntegerLiteral @1:9 :value 1)).
+func FormatSExpr(node Node) string {
+	if p, ok := node.(*Program); ok {
+		var b strings.Builder
+		for _, s := range p.Statements {
+			writeSExpr(&b, reflect.ValueOf(s))
+			b.WriteString("\n")
+		}
+		return b.String()
+	}
+	var b strings.Builder
+	writeSExpr(&b, reflect.ValueOf(node))
+	b.WriteString("\n")
+	return b.String()
+}
+
+var positionType = reflect.TypeOf(lexer.Position{})
+
+// field is one exported struct field prepared for dumping.
+type field struct {
+	name  string
+	value reflect.Value
+}
+
+// structOf dereferences v to a struct and returns its type name, whether
+// it is a node (as opposed to a helper struct like MatchArm), and its
+// fields.
+func structOf(v reflect.Value) (string, bool, []field) {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	} else {
+		// Slices hold helper structs by value; copy to make them addressable.
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		v = p.Elem()
+	}
+	_, isNode := v.Addr().Interface().(Node)
+	fields := make([]field, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		r := []rune(f.Name)
+		r[0] = unicode.ToLower(r[0])
+		fields = append(fields, field{name: string(r), value: v.Field(i)})
+	}
+	return v.Type().Name(), isNode, fields
+}
+
+// isNilValue reports whether v is a nil interface or pointer.
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return !v.IsValid()
+}
+
+func elem(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Interface {
+		return v.Elem()
+	}
+	return v
+}
+
+// ---------------------------------------------------------------------------
+// JSON
+// ---------------------------------------------------------------------------
+
+func writeJSONValue(b *bytes.Buffer, v reflect.Value) {
+	if isNilValue(v) {
+		b.WriteString("null")
+		return
+	}
+	v = elem(v)
+	switch {
+	case v.Type() == positionType:
+		p := v.Interface().(lexer.Position)
+		data, _ := json.Marshal(p)
+		b.Write(data)
+	case v.Kind() == reflect.Slice:
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			writeJSONValue(b, v.Index(i))
+		}
+		b.WriteString("]")
+	case v.Kind() == reflect.Ptr || v.Kind() == reflect.Struct:
+		kind, isNode, fields := structOf(v)
+		b.WriteString("{")
+		if isNode {
+			b.WriteString(`"kind":`)
+			writeJSONString(b, kind)
+		}
+		for i, f := range fields {
+			if i > 0 || isNode {
+				b.WriteString(",")
+			}
+			writeJSONString(b, f.name)
+			b.WriteString(":")
+			writeJSONValue(b, f.value)
+		}
+		b.WriteString("}")
+	default:
+		data, _ := json.Marshal(v.Interface())
+		b.Write(data)
+	}
+}
+
+func writeJSONString(b *bytes.Buffer, s string) {
+	data, _ := json.Marshal(s)
+	b.Write(data)
+}
+
+// ---------------------------------------------------------------------------
+// Tree
+// ---------------------------------------------------------------------------
+
+// writeTree writes the head of v on the current line, then its fields on
+// the following lines at depth+1.
+func writeTree(b *strings.Builder, v reflect.Value, depth int) {
+	if isNilValue(v) {
+		b.WriteString("nil\n")
+		return
+	}
+	kind, _, fields := structOf(elem(v))
+	b.WriteString(kind)
+	for _, f := range fields {
+		if f.name == "pos" {
+			b.WriteString(" @" + shortPos(f.value))
+		}
+	}
+	b.WriteString("\n")
+
+	indent := strings.Repeat("  ", depth+1)
+	for _, f := range fields {
+		if f.name == "pos" {
+			continue
+		}
+		b.WriteString(indent + f.name + ":")
+		writeTreeField(b, f.value, depth+1)
+	}
+}
+
+// writeTreeField writes a field's value after its "name:" label: inline
+// for leaves and nodes, on the following lines for lists of nodes.
+func writeTreeField(b *strings.Builder, v reflect.Value, depth int) {
+	if isNilValue(v) {
+		b.WriteString(" nil\n")
+		return
+	}
+	v = elem(v)
+	switch {
+	case v.Type() == positionType:
+		b.WriteString(" " + shortPos(v) + "\n")
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		b.WriteString(" " + scalar(v) + "\n")
+	case v.Kind() == reflect.Slice:
+		if v.Len() == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		indent := strings.Repeat("  ", depth+1)
+		for i := 0; i < v.Len(); i++ {
+			fmt.Fprintf(b, "%s[%d] ", indent, i)
+			writeTree(b, v.Index(i), depth+1)
+		}
+	case v.Kind() == reflect.Ptr || v.Kind() == reflect.Struct:
+		b.WriteString(" ")
+		writeTree(b, v, depth)
+	default:
+		b.WriteString(" " + scalar(v) + "\n")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// S-expressions
+// ---------------------------------------------------------------------------
+
+func writeSExpr(b *strings.Builder, v reflect.Value) {
+	if isNilValue(v) {
+		b.WriteString("nil")
+		return
+	}
+	v = elem(v)
+	switch {
+	case v.Type() == positionType:
+		b.WriteString(shortPos(v))
+	case v.Kind() == reflect.Slice:
+		b.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteString(" ")
+			}
+			writeSExpr(b, v.Index(i))
+		}
+		b.WriteString("]")
+	case v.Kind() == reflect.Ptr || v.Kind() == reflect.Struct:
+		kind, _, fields := structOf(v)
+		b.WriteString("(" + kind)
+		for _, f := range fields {
+			if f.name == "pos" {
+				b.WriteString(" @" + shortPos(f.value))
+				continue
+			}
+			b.WriteString(" :" + f.name + " ")
+			writeSExpr(b, f.value)
+		}
+		b.WriteString(")")
+	default:
+		b.WriteString(scalar(v))
+	}
+}
+
+// scalar renders strings quoted and other leaf values as Go prints them.
+func scalar(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return strconv.Quote(v.String())
+	case reflect.Slice:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = scalar(v.Index(i))
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// shortPos renders a position as line:column; the file is the same for a
+// whole dump and is left out.
+func shortPos(v reflect.Value) string {
+	p := v.Interface().(lexer.Position)
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
@@ -0,0 +1,44 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/parser"
+)
+
+func TestDump(t *testing.T) {
+	program, errs := parser.ParseSource("let r = a ?? 1..n", "t.glace")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+
+	expected := `(LetStatement @1:1 :name "r" :value (CoalesceExpression @1:11 :left (Identifier @1:9 :name "a") :right (RangeExpression @1:15 :start (IntegerLiteral @1:14 :value 1) :end (Identifier @1:17 :name "n") :step nil)) :doc "")
+`
+	if got := ast.FormatSExpr(program); got != expected {
+		t.Errorf("wrong sexpr.\nexpected: %s\ngot:      %s", expected, got)
+	}
+	if tree := ast.FormatTree(program); !strings.Contains(tree, "\n      value: CoalesceExpression @1:11\n        left: Identifier @1:9\n") {
+		t.Errorf("wrong tree:\n%s", tree)
+	}
+
+	var b strings.Builder
+	if err := ast.WriteJSON(&b, program); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Version int
+		AST     struct {
+			Kind       string
+			Statements []map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal([]byte(b.String()), &doc); err != nil {
+		t.Fatalf("invalid JSON: %s\n%s", err, b.String())
+	}
+	if doc.Version != ast.JSONVersion || doc.AST.Kind != "Program" || doc.AST.Statements[0]["kind"] != "LetStatement" {
+		t.Errorf("unexpected JSON:\n%s", b.String())
+	}
+}
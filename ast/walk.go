@@ -0,0 +1,130 @@
+package ast
+
+// Walk traverses the tree rooted at node in depth-first order, calling fn
+// for every node. If fn returns false, the node's children are skipped.
+// Missing children (nil expressions or blocks) are not visited.
+func Walk(node Node, fn func(Node) bool) {
+	if isNil(node) || !fn(node) {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Walk(s, fn)
+		}
+
+	// --- Statements ---
+	case *LetStatement:
+		Walk(n.Value, fn)
+	case *MutStatement:
+		Walk(n.Value, fn)
+	case *AssignStatement:
+		Walk(n.Value, fn)
+	case *IndexAssignStatement:
+		Walk(n.Left, fn)
+		Walk(n.Index, fn)
+		Walk(n.Value, fn)
+	case *FieldAssignStatement:
+		Walk(n.Left, fn)
+		Walk(n.Value, fn)
+	case *ExpressionStatement:
+		Walk(n.Expression, fn)
+	case *ReturnStatement:
+		for _, v := range n.Values {
+			Walk(v, fn)
+		}
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Walk(s, fn)
+		}
+	case *IfStatement:
+		Walk(n.Condition, fn)
+		Walk(n.Consequence, fn)
+		for _, elif := range n.ElifClauses {
+			Walk(elif.Condition, fn)
+			Walk(elif.Consequence, fn)
+		}
+		Walk(n.Alternative, fn)
+	case *LoopStatement:
+		Walk(n.Condition, fn)
+		Walk(n.Iterable, fn)
+		Walk(n.Body, fn)
+	case *FnDeclaration:
+		Walk(n.Body, fn)
+	case *MatchStatement:
+		Walk(n.Subject, fn)
+		for _, arm := range n.Arms {
+			Walk(arm.Pattern, fn)
+			Walk(arm.Guard, fn)
+			Walk(arm.Body, fn)
+		}
+	case *TestBlock:
+		Walk(n.Body, fn)
+	case *BenchBlock:
+		Walk(n.Body, fn)
+	case *DescribeBlock:
+		Walk(n.Body, fn)
+	case *HookBlock:
+		Walk(n.Body, fn)
+
+	// --- Expressions ---
+	case *StringInterpolation:
+		for _, p := range n.Parts {
+			Walk(p, fn)
+		}
+	case *BinaryExpression:
+		Walk(n.Left, fn)
+		Walk(n.Right, fn)
+	case *UnaryExpression:
+		Walk(n.Operand, fn)
+	case *CallExpression:
+		Walk(n.Function, fn)
+		for _, a := range n.Arguments {
+			Walk(a, fn)
+		}
+	case *IndexExpression:
+		Walk(n.Left, fn)
+		Walk(n.Index, fn)
+	case *DotExpression:
+		Walk(n.Left, fn)
+	case *SafeAccessExpression:
+		Walk(n.Left, fn)
+	case *ArrayLiteral:
+		for _, e := range n.Elements {
+			Walk(e, fn)
+		}
+	case *MapLiteral:
+		for i := range n.Keys {
+			Walk(n.Keys[i], fn)
+			Walk(n.Values[i], fn)
+		}
+	case *FnLiteral:
+		Walk(n.Body, fn)
+	case *RangeExpression:
+		Walk(n.Start, fn)
+		Walk(n.End, fn)
+		Walk(n.Step, fn)
+	case *PipelineExpression:
+		Walk(n.Left, fn)
+		Walk(n.Right, fn)
+	case *CoalesceExpression:
+		Walk(n.Left, fn)
+		Walk(n.Right, fn)
+	}
+}
+
+// isNil reports whether node is nil or a typed nil pointer, which the
+// parser leaves behind when a sub-expression or block fails to parse.
+func isNil(node Node) bool {
+	if node == nil {
+		return true
+	}
+	switch n := node.(type) {
+	case *BlockStatement:
+		return n == nil
+	case *CallExpression:
+		return n == nil
+	}
+	return false
+}
@@ -0,0 +1,860 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/coverage"
+	"github.com/glace-lang/glace/debugger"
+	"github.com/glace-lang/glace/diag"
+	"github.com/glace-lang/glace/doc"
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/format"
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/lint"
+	"github.com/glace-lang/glace/lsp"
+	"github.com/glace-lang/glace/parser"
+	"github.com/glace-lang/glace/repl"
+	"github.com/glace-lang/glace/testreport"
+)
+
+func main() {
+	args := os.Args[1:]
+
+	if len(args) == 0 {
+		repl.Start(os.Stdin, os.Stdout)
+		return
+	}
+
+	switch args[0] {
+	case "run":
+		runCommand(args[1:])
+
+	case "test":
+		testCommand(args[1:])
+
+	case "bench":
+		benchCommand(args[1:])
+
+	case "debug":
+		debugCommand(args[1:])
+
+	case "ast":
+		astCommand(args[1:])
+
+	case "fmt":
+		fmtCommand(args[1:])
+
+	case "check":
+		checkCommand(args[1:])
+
+	case "doc":
+		docCommand(args[1:])
+
+	case "lsp":
+		if err := lsp.ServeStdio(); err != nil {
+			fmt.Fprintf(os.Stderr, "lsp: %s\n", err)
+			os.Exit(1)
+		}
+
+	case "--resume":
+		repl.Run(os.Stdin, os.Stdout, &repl.Options{Resume: true})
+
+	case "--version", "-v":
+		fmt.Printf("Glace v%s\n", repl.VERSION)
+
+	case "--help", "-h":
+		printHelp()
+
+	default:
+		// Treat as filename
+		runFile(args[0], nil, "text")
+	}
+}
+
+func runCommand(args []string) {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	trace := fs.Bool("trace", false, "log every statement and function call to stderr")
+	traceFormat := fs.String("trace-format", "text", "trace output format: text or json")
+	traceFile := fs.String("trace-file", "", "only trace statements in this file")
+	traceFn := fs.String("trace-fn", "", "only trace inside calls to this function")
+	diagnostics := fs.String("diagnostics", "text", "error output format: text or json")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: glace run [--trace] [--trace-format=text|json] [--trace-file=f] [--trace-fn=name] [--diagnostics=text|json] <file.glace>")
+		os.Exit(1)
+	}
+	checkDiagnosticsFormat(*diagnostics)
+
+	var opts *evaluator.TraceOptions
+	if *trace || flagSet(fs, "trace-format") || flagSet(fs, "trace-file") || flagSet(fs, "trace-fn") {
+		if *traceFormat != "text" && *traceFormat != "json" {
+			fmt.Fprintf(os.Stderr, "error: unknown trace format %q\n", *traceFormat)
+			os.Exit(1)
+		}
+		opts = &evaluator.TraceOptions{Format: *traceFormat, File: *traceFile, Function: *traceFn}
+	}
+	runFile(fs.Arg(0), opts, *diagnostics)
+}
+
+func checkDiagnosticsFormat(format string) {
+	if format != "text" && format != "json" {
+		fmt.Fprintf(os.Stderr, "error: unknown diagnostics format %q\n", format)
+		os.Exit(1)
+	}
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(fs *flag.FlagSet, name string) bool {
+	found := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			found = true
+		}
+	})
+	return found
+}
+
+func runFile(path string, trace *evaluator.TraceOptions, diagFormat string) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	program, diags := parser.ParseSource(string(source), path)
+	if len(diags) > 0 {
+		printDiagnostics(diags, diagFormat, path, string(source))
+		os.Exit(1)
+	}
+
+	env := evaluator.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	evaluator.RegisterHOBuiltins(env)
+
+	if trace != nil {
+		trace.Source = string(source)
+		env.Runtime().Trace(nil, *trace)
+	}
+
+	_, evalErr := evaluator.Eval(program, env)
+	if evalErr != nil {
+		if diagFormat == "json" {
+			diag.WriteJSON(os.Stderr, []*diag.Diagnostic{evaluator.ErrorDiagnostic(evalErr, diag.Position{})})
+		} else {
+			printRuntimeError(evalErr, path, string(source))
+		}
+		os.Exit(1)
+	}
+}
+
+// printDiagnostics reports diags on stderr, rendered with source snippets
+// or, when format is "json", as a JSON array.
+func printDiagnostics(diags []*diag.Diagnostic, format, path, source string) {
+	if format == "json" {
+		diag.WriteJSON(os.Stderr, diags)
+		return
+	}
+	diag.RenderAll(os.Stderr, diags, map[string]string{path: source})
+}
+
+// printRuntimeError reports err on stderr, with a traceback when it is a
+// RuntimeError.
+func printRuntimeError(err error, path, source string) {
+	if re, ok := err.(*evaluator.RuntimeError); ok {
+		fmt.Fprintln(os.Stderr, evaluator.FormatTraceback(re, map[string]string{path: source}))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s\n", err)
+}
+
+func astCommand(args []string) {
+	fs := flag.NewFlagSet("ast", flag.ExitOnError)
+	format := fs.String("format", "tree", "output format: tree, json or sexpr")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: glace ast [--format=tree|json|sexpr] <file.glace>")
+		os.Exit(1)
+	}
+	if *format != "tree" && *format != "json" && *format != "sexpr" {
+		fmt.Fprintf(os.Stderr, "error: unknown ast format %q\n", *format)
+		os.Exit(1)
+	}
+
+	path := fs.Arg(0)
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	// The tree is printed even when there are errors; damaged regions
+	// show up as Bad* nodes.
+	program, diags := parser.ParseSource(string(source), path)
+	switch *format {
+	case "json":
+		ast.WriteJSON(os.Stdout, program)
+	case "sexpr":
+		fmt.Print(ast.FormatSExpr(program))
+	default:
+		fmt.Print(ast.FormatTree(program))
+	}
+	if len(diags) > 0 {
+		printDiagnostics(diags, "text", path, string(source))
+		os.Exit(1)
+	}
+}
+
+func fmtCommand(args []string) {
+	fs := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := fs.Bool("w", false, "write the result back to the files")
+	check := fs.Bool("check", false, "list files whose formatting differs and exit 1 if there are any")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		source, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		out, diags := format.Source(string(source), "<stdin>")
+		if len(diags) > 0 {
+			printDiagnostics(diags, "text", "<stdin>", string(source))
+			os.Exit(1)
+		}
+		fmt.Print(out)
+		return
+	}
+
+	var files []string
+	for _, arg := range fs.Args() {
+		found, err := glaceFiles(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		files = append(files, found...)
+	}
+
+	failed := false
+	for _, path := range files {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			failed = true
+			continue
+		}
+		out, diags := format.Source(string(source), path)
+		if len(diags) > 0 {
+			printDiagnostics(diags, "text", path, string(source))
+			failed = true
+			continue
+		}
+		switch {
+		case *check:
+			if out != string(source) {
+				fmt.Println(path)
+				failed = true
+			}
+		case *write:
+			if out != string(source) {
+				if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+					fmt.Fprintf(os.Stderr, "error: %s\n", err)
+					failed = true
+				}
+			}
+		default:
+			fmt.Print(out)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func checkCommand(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	diagnostics := fs.String("diagnostics", "text", "output format: text or json")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: glace check [--diagnostics=text|json] <paths>")
+		os.Exit(1)
+	}
+	checkDiagnosticsFormat(*diagnostics)
+
+	var files []string
+	for _, arg := range fs.Args() {
+		found, err := glaceFiles(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		files = append(files, found...)
+	}
+
+	var all []*diag.Diagnostic
+	sources := map[string]string{}
+	for _, path := range files {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+		sources[path] = string(source)
+		program, diags := parser.ParseSource(string(source), path)
+		if len(diags) == 0 {
+			diags = lint.Check(program)
+		}
+		all = append(all, diags...)
+	}
+
+	if *diagnostics == "json" {
+		diag.WriteJSON(os.Stderr, all)
+	} else {
+		diag.RenderAll(os.Stderr, all, sources)
+	}
+	for _, d := range all {
+		if d.Severity == diag.Error {
+			os.Exit(1)
+		}
+	}
+}
+
+func docCommand(args []string) {
+	fs := flag.NewFlagSet("doc", flag.ExitOnError)
+	format := fs.String("format", "markdown", "output format: markdown or html")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: glace doc [--format=markdown|html] <file.glace>")
+		os.Exit(1)
+	}
+	if *format != "markdown" && *format != "html" {
+		fmt.Fprintf(os.Stderr, "error: unknown doc format %q\n", *format)
+		os.Exit(1)
+	}
+
+	path := fs.Arg(0)
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+	program, diags := parser.ParseSource(string(source), path)
+	if len(diags) > 0 {
+		printDiagnostics(diags, "text", path, string(source))
+		os.Exit(1)
+	}
+
+	title := strings.TrimSuffix(filepath.Base(path), ".glace")
+	if *format == "html" {
+		err = doc.HTML(os.Stdout, title, doc.Entries(program))
+	} else {
+		err = doc.Markdown(os.Stdout, title, doc.Entries(program))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// glaceFiles returns path itself if it is a file, or the .glace files
+// under it if it is a directory.
+func glaceFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && filepath.Ext(p) == ".glace" {
+			files = append(files, p)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func debugCommand(args []string) {
+	fs := flag.NewFlagSet("debug", flag.ExitOnError)
+	dap := fs.Bool("dap", false, "serve the Debug Adapter Protocol over stdio")
+	fs.Parse(args)
+
+	if *dap {
+		if err := debugger.ServeStdio(); err != nil {
+			fmt.Fprintf(os.Stderr, "dap: %s\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: glace debug <file.glace> | glace debug --dap")
+		os.Exit(1)
+	}
+
+	path := fs.Arg(0)
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	program, diags := parser.ParseSource(string(source), path)
+	if len(diags) > 0 {
+		printDiagnostics(diags, "text", path, string(source))
+		os.Exit(1)
+	}
+
+	env := evaluator.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	evaluator.RegisterHOBuiltins(env)
+
+	dbg := debugger.New()
+	debugger.NewConsole(dbg, os.Stdin, os.Stdout, path, string(source))
+	dbg.Attach(env)
+
+	_, evalErr := evaluator.Eval(program, env)
+	if evalErr == debugger.ErrQuit {
+		return
+	}
+	if evalErr != nil {
+		printRuntimeError(evalErr, path, string(source))
+		os.Exit(1)
+	}
+	fmt.Println("Program finished.")
+}
+
+func testCommand(args []string) {
+	fs := flag.NewFlagSet("test", flag.ExitOnError)
+	run := fs.String("run", "", "only run tests whose name matches this regular expression")
+	verbose := fs.Bool("v", false, "show how long each test took")
+	format := fs.String("format", "text", "result format: text, json, tap or junit")
+	parallel := fs.Int("p", runtime.GOMAXPROCS(0), "how many files to test at once")
+	cover := fs.Bool("cover", false, "report statement and branch coverage")
+	annotate := fs.Bool("cover-annotate", false, "print the source annotated with hit counts")
+	profile := fs.String("coverprofile", "", "write coverage as an LCOV tracefile to this path")
+	diagnostics := fs.String("diagnostics", "text", "error output format: text or json")
+	update := fs.Bool("update-snapshots", false, "rewrite stored snapshots that differ instead of failing")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: glace test [-run regexp] [-v] [-p n] [--format=text|json|tap|junit] [--update-snapshots] [--cover] [--cover-annotate] [--coverprofile=out.lcov] [--diagnostics=text|json] <paths>")
+		os.Exit(1)
+	}
+	checkDiagnosticsFormat(*diagnostics)
+	if !slices.Contains(testreport.Formats, *format) {
+		fmt.Fprintf(os.Stderr, "error: unknown test format %q\n", *format)
+		os.Exit(1)
+	}
+	var opts evaluator.TestOptions
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -run: %s\n", err)
+			os.Exit(1)
+		}
+		opts.Run = re
+	}
+	files, err := testFiles(fs.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+
+	var cov *coverage.Collector
+	if *cover || *annotate || *profile != "" {
+		// The collector is not safe for concurrent use.
+		cov = coverage.New()
+		*parallel = 1
+	}
+	runs := make([]testRun, len(files))
+	sem := make(chan struct{}, max(*parallel, 1))
+	var wg sync.WaitGroup
+	for i, path := range files {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, path string) {
+			defer wg.Done()
+			runs[i] = testFile(path, cov, opts, *update)
+			<-sem
+		}(i, path)
+	}
+	wg.Wait()
+
+	suites := make([]testreport.Suite, len(runs))
+	var failures []*diag.Diagnostic
+	for i, r := range runs {
+		suites[i] = r.suite
+		if len(r.diags) > 0 && *diagnostics != "json" {
+			diag.RenderAll(os.Stderr, r.diags, map[string]string{r.suite.File: r.source})
+		}
+		failures = append(failures, r.diags...)
+		for _, res := range r.suite.Results {
+			if !res.Passed {
+				failures = append(failures, res.Diagnostic)
+			}
+		}
+	}
+	if err := testreport.Write(os.Stdout, *format, suites, *verbose); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+	if *diagnostics == "json" {
+		diag.WriteJSON(os.Stderr, failures)
+	}
+
+	if cov != nil {
+		// Keep machine-readable results on stdout parseable.
+		out := io.Writer(os.Stdout)
+		if *format != "text" {
+			out = os.Stderr
+		}
+		fmt.Fprintln(out)
+		cov.WriteSummary(out)
+		if *annotate {
+			fmt.Fprintln(out)
+			cov.WriteAnnotated(out)
+		}
+		if *profile != "" {
+			if err := writeCoverProfile(cov, *profile); err != nil {
+				fmt.Fprintf(os.Stderr, "error: %s\n", err)
+				os.Exit(1)
+			}
+		}
+	}
+	if testreport.Failed(suites) {
+		os.Exit(1)
+	}
+}
+
+// testFiles expands the paths given to glace test. Files named directly
+// always run. Directories are searched recursively, with dir/... meaning
+// the same as dir, for *_test.glace files and files with test blocks.
+func testFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		if rest, ok := strings.CutSuffix(path, "..."); ok {
+			path = filepath.Clean(rest)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		found, err := glaceFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range found {
+			if strings.HasSuffix(f, "_test.glace") {
+				files = append(files, f)
+				continue
+			}
+			source, err := os.ReadFile(f)
+			if err != nil {
+				return nil, err
+			}
+			if hasTests(string(source), f) {
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
+
+// hasTests reports whether source contains the test keyword.
+func hasTests(source, path string) bool {
+	for _, t := range lexer.New(source, path).Tokenize() {
+		if t.Type == lexer.TOKEN_TEST {
+			return true
+		}
+	}
+	return false
+}
+
+func writeCoverProfile(cov *coverage.Collector, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := cov.WriteLCOV(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// testRun is the outcome of testing one file.
+type testRun struct {
+	suite  testreport.Suite
+	source string
+	diags  []*diag.Diagnostic // syntax errors that kept the tests from running
+}
+
+// testFile runs the test blocks in path in an environment of its own.
+// What the file prints outside its tests is kept in the suite's Output.
+// When cov is non-nil the run also records coverage into it. Snapshots
+// taken by the tests are compared with, or with update rewritten into,
+// the file's __snapshots__ file.
+func testFile(path string, cov *coverage.Collector, opts evaluator.TestOptions, update bool) testRun {
+	run := testRun{suite: testreport.Suite{File: path}}
+	source, err := os.ReadFile(path)
+	if err != nil {
+		run.suite.Error = err.Error()
+		return run
+	}
+	run.source = string(source)
+
+	program, diags := parser.ParseSource(run.source, path)
+	if len(diags) > 0 {
+		run.diags = diags
+		run.suite.Error = fmt.Sprintf("%s: %s", diags[0].Start, diags[0].Message)
+		if len(diags) > 1 {
+			run.suite.Error += fmt.Sprintf(" (and %d more errors)", len(diags)-1)
+		}
+		return run
+	}
+
+	snapshots, err := evaluator.LoadSnapshots(path, update)
+	if err != nil {
+		run.suite.Error = err.Error()
+		return run
+	}
+	env := evaluator.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	evaluator.RegisterHOBuiltins(env)
+	env.Runtime().SetSnapshots(snapshots)
+	if cov != nil {
+		cov.Register(program, path, run.source)
+		env.Runtime().AddHooks(cov.Hooks())
+	}
+	var output strings.Builder
+	env.Runtime().SetStdout(&output)
+	run.suite.Results = evaluator.RunTests(program, env, opts)
+	run.suite.Output = output.String()
+	// Snapshots no test took are only dropped when every test ran and
+	// passed: a test that failed early may not have reached its own.
+	if err := snapshots.Save(update && opts.Run == nil && evaluator.AllPassed(run.suite.Results)); err != nil {
+		run.suite.Error = err.Error()
+	}
+	return run
+}
+
+func benchCommand(args []string) {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	run := fs.String("run", "", "only run benchmarks whose name matches this regular expression")
+	benchtime := fs.Duration("benchtime", time.Second, "how long to run each benchmark")
+	baseline := fs.String("baseline", "", "compare against the results saved in this file")
+	save := fs.String("save", "", "save the results to this file as a baseline")
+	threshold := fs.Float64("threshold", 10, "percentage slowdown against the baseline reported as a regression")
+	fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fmt.Fprintln(os.Stderr, "usage: glace bench [-run regexp] [-benchtime 1s] [-baseline file] [-save file] [-threshold percent] <file.glace>")
+		os.Exit(1)
+	}
+	opts := evaluator.BenchOptions{Benchtime: *benchtime}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: -run: %s\n", err)
+			os.Exit(1)
+		}
+		opts.Run = re
+	}
+	var base map[string]benchBaseline
+	if *baseline != "" {
+		var err error
+		if base, err = readBaseline(*baseline); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+
+	path := fs.Arg(0)
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %s\n", err)
+		os.Exit(1)
+	}
+	program, diags := parser.ParseSource(string(source), path)
+	if len(diags) > 0 {
+		printDiagnostics(diags, "text", path, string(source))
+		os.Exit(1)
+	}
+
+	env := evaluator.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	evaluator.RegisterHOBuiltins(env)
+	results, err := evaluator.RunBenchmarks(program, env, opts)
+	if err != nil {
+		printRuntimeError(err, path, string(source))
+		os.Exit(1)
+	}
+
+	failed, regressed := 0, 0
+	for _, r := range results {
+		if r.Diagnostic != nil {
+			fmt.Printf("  FAIL: %s — %s\n", r.Name, r.Error)
+			failed++
+			continue
+		}
+		line := fmt.Sprintf("  %-30s %10d %12d ns/op %10d B/op %8d allocs/op", r.Name, r.N, r.NsPerOp, r.BytesPerOp, r.AllocsPerOp)
+		if b, ok := base[r.Name]; ok && b.NsPerOp > 0 {
+			change := float64(r.NsPerOp-b.NsPerOp) / float64(b.NsPerOp) * 100
+			line += fmt.Sprintf("  %+6.1f%%", change)
+			if change > *threshold {
+				line += "  REGRESSION"
+				regressed++
+			}
+		}
+		fmt.Println(line)
+	}
+	if len(results) == 0 {
+		fmt.Println("  no benchmarks to run")
+	}
+	if regressed > 0 {
+		fmt.Printf("\n%d regressed by more than %g%%\n", regressed, *threshold)
+	}
+
+	if *save != "" {
+		if err := writeBaseline(*save, results, opts.Run != nil); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %s\n", err)
+			os.Exit(1)
+		}
+	}
+	if failed > 0 || regressed > 0 {
+		os.Exit(1)
+	}
+}
+
+// benchBaseline is one benchmark's entry in a file written by
+// `glace bench -save`.
+type benchBaseline struct {
+	Name        string `json:"name"`
+	NsPerOp     int64  `json:"ns_per_op"`
+	BytesPerOp  int64  `json:"bytes_per_op"`
+	AllocsPerOp int64  `json:"allocs_per_op"`
+}
+
+func readBaseline(path string) (map[string]benchBaseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []benchBaseline
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	base := map[string]benchBaseline{}
+	for _, e := range entries {
+		base[e.Name] = e
+	}
+	return base, nil
+}
+
+// writeBaseline saves the results of the benchmarks that ran without
+// error. With merge, as when -run selected only some benchmarks, the
+// entries already in path are kept and those that ran are replaced.
+func writeBaseline(path string, results []evaluator.BenchResult, merge bool) error {
+	entries := []benchBaseline{}
+	if merge {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			if err := json.Unmarshal(data, &entries); err != nil {
+				return fmt.Errorf("%s: %s", path, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	index := map[string]int{}
+	for i, e := range entries {
+		index[e.Name] = i
+	}
+	for _, r := range results {
+		if r.Diagnostic != nil {
+			continue
+		}
+		e := benchBaseline{r.Name, r.NsPerOp, r.BytesPerOp, r.AllocsPerOp}
+		if i, ok := index[r.Name]; ok {
+			entries[i] = e
+		} else {
+			entries = append(entries, e)
+		}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func printHelp() {
+	fmt.Println(`Glace — A lightweight interpreted language
+
+Usage:
+  glace                   Start the REPL
+  glace --resume          Start the REPL with the last saved session
+  glace run <file>        Execute a .glace file
+    --trace               Log statements and function calls to stderr
+    --trace-format=json   Emit one JSON object per trace event
+    --trace-file=<file>   Only trace statements in matching files
+    --trace-fn=<name>     Only trace inside calls to <name>
+    --diagnostics=json    Report errors as a JSON array on stderr
+  glace test <paths>      Run test blocks; directories (or dir/...) are searched
+                          for *_test.glace files and files with tests
+    -p=<n>                Test this many files at once (default: CPU count)
+    -run=<regexp>         Only run tests whose name matches
+    -v                    Show how long each test took
+    --format=json|tap|junit  Report results for CI instead of as text
+    --update-snapshots    Rewrite the stored values of snapshot() that differ
+    --cover               Report statement and branch coverage
+    --cover-annotate      Print the source annotated with hit counts
+    --coverprofile=<out>  Write coverage as an LCOV tracefile
+    --diagnostics=json    Report parse errors and failures as JSON on stderr
+  glace bench <file>      Run bench blocks in a .glace file
+    -run=<regexp>         Only run benchmarks whose name matches
+    -benchtime=<d>        Run each benchmark for about this long (default 1s)
+    -save=<file>          Save the results as a baseline
+    -baseline=<file>      Compare with a saved baseline and exit 1 on regressions
+    -threshold=<percent>  Slowdown counted as a regression (default 10)
+  glace ast <file>        Print the parsed AST
+    --format=tree|json|sexpr  Output format (default tree)
+  glace fmt [paths]       Format .glace files (stdin when no paths are given)
+    -w                    Rewrite the files in place
+    --check               List files that are not formatted and exit 1
+  glace check <paths>     Report likely mistakes without running the code
+    --diagnostics=json    Report them as a JSON array on stderr
+  glace doc <file>        Print the documentation of a file's functions
+    --format=markdown|html  Output format (default markdown)
+  glace lsp               Serve the Language Server Protocol over stdio
+  glace debug <file>      Run a .glace file under the interactive debugger
+  glace debug --dap       Serve the Debug Adapter Protocol over stdio
+  glace --version         Print version
+  glace --help            Print this help`)
+}
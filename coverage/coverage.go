@@ -0,0 +1,242 @@
+// Package coverage records which statements, branches and functions of a
+// Glace program run, and renders the result as a terminal summary, an
+// annotated source listing or an LCOV tracefile.
+//
+// Code inside test blocks is the test itself, so it is not counted.
+package coverage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/lexer"
+)
+
+// Collector gathers coverage for one or more programs.
+type Collector struct {
+	files    map[string]*fileData
+	order    []string
+	stmts    map[ast.Statement]*stmtData
+	branches map[ast.Statement]*branchData
+	funcs    map[*ast.BlockStatement]*funcData
+}
+
+type fileData struct {
+	name     string
+	lines    []string
+	stmts    []*stmtData
+	branches []*branchData
+	funcs    []*funcData
+}
+
+type stmtData struct {
+	pos  lexer.Position
+	hits int
+}
+
+// branchData tracks the arms of one if or match statement.
+type branchData struct {
+	pos    lexer.Position
+	labels []string
+	hits   []int
+}
+
+type funcData struct {
+	name  string
+	pos   lexer.Position
+	calls int
+	stmts []*stmtData
+	arms  []*branchData
+}
+
+// New creates an empty Collector.
+func New() *Collector {
+	return &Collector{
+		files:    make(map[string]*fileData),
+		stmts:    make(map[ast.Statement]*stmtData),
+		branches: make(map[ast.Statement]*branchData),
+		funcs:    make(map[*ast.BlockStatement]*funcData),
+	}
+}
+
+// Register records every coverable statement, branch and function of
+// program so that code which never runs is reported too. file names the
+// program in reports and source is its text, used for annotation.
+func (c *Collector) Register(program *ast.Program, file, source string) {
+	fd, ok := c.files[file]
+	if !ok {
+		fd = &fileData{name: file, lines: strings.Split(source, "\n")}
+		c.files[file] = fd
+		c.order = append(c.order, file)
+	}
+
+	var enclosing []*funcData
+	var visit func(node ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.TestBlock, *ast.BenchBlock, *ast.DescribeBlock, *ast.HookBlock:
+			return false
+		case *ast.FnDeclaration:
+			c.statement(fd, n, enclosing)
+			c.enterFunc(fd, n.Name, n.Pos, n.Body, &enclosing, visit)
+			return false
+		case *ast.FnLiteral:
+			c.enterFunc(fd, fmt.Sprintf("<fn@%d>", n.Pos.Line), n.Pos, n.Body, &enclosing, visit)
+			return false
+		case *ast.BlockStatement:
+			return true
+		case *ast.IfStatement:
+			labels := []string{"then"}
+			for i := range n.ElifClauses {
+				labels = append(labels, fmt.Sprintf("elif%d", i+1))
+			}
+			labels = append(labels, "else")
+			c.branch(fd, n, labels, enclosing)
+		case *ast.MatchStatement:
+			labels := make([]string, 0, len(n.Arms)+1)
+			for i := range n.Arms {
+				labels = append(labels, fmt.Sprintf("arm%d", i+1))
+			}
+			if !exhaustive(n) {
+				labels = append(labels, "none")
+			}
+			c.branch(fd, n, labels, enclosing)
+		}
+		if stmt, ok := node.(ast.Statement); ok {
+			c.statement(fd, stmt, enclosing)
+		}
+		return true
+	}
+	ast.Walk(program, visit)
+}
+
+// enterFunc registers a function and walks its body with it on top of
+// the enclosing stack.
+func (c *Collector) enterFunc(fd *fileData, name string, pos lexer.Position, body *ast.BlockStatement, enclosing *[]*funcData, visit func(ast.Node) bool) {
+	if body == nil {
+		return
+	}
+	f := &funcData{name: name, pos: pos}
+	fd.funcs = append(fd.funcs, f)
+	c.funcs[body] = f
+	*enclosing = append(*enclosing, f)
+	ast.Walk(body, visit)
+	*enclosing = (*enclosing)[:len(*enclosing)-1]
+}
+
+func (c *Collector) statement(fd *fileData, stmt ast.Statement, enclosing []*funcData) {
+	s := &stmtData{pos: stmt.TokenPos()}
+	fd.stmts = append(fd.stmts, s)
+	c.stmts[stmt] = s
+	if len(enclosing) > 0 {
+		f := enclosing[len(enclosing)-1]
+		f.stmts = append(f.stmts, s)
+	}
+}
+
+func (c *Collector) branch(fd *fileData, stmt ast.Statement, labels []string, enclosing []*funcData) {
+	b := &branchData{pos: stmt.TokenPos(), labels: labels, hits: make([]int, len(labels))}
+	fd.branches = append(fd.branches, b)
+	c.branches[stmt] = b
+	if len(enclosing) > 0 {
+		f := enclosing[len(enclosing)-1]
+		f.arms = append(f.arms, b)
+	}
+}
+
+// exhaustive reports whether the last arm of a match always matches, in
+// which case "no arm matched" is not a real branch.
+func exhaustive(m *ast.MatchStatement) bool {
+	if len(m.Arms) == 0 {
+		return false
+	}
+	last := m.Arms[len(m.Arms)-1]
+	if last.Guard != nil {
+		return false
+	}
+	switch last.Pattern.(type) {
+	case *ast.WildcardExpression, *ast.Identifier:
+		return true
+	}
+	return false
+}
+
+// Hooks returns the evaluator hooks that feed this collector.
+func (c *Collector) Hooks() *evaluator.Hooks {
+	return &evaluator.Hooks{
+		Statement: func(stmt ast.Statement, env *evaluator.Environment) error {
+			if s, ok := c.stmts[stmt]; ok {
+				s.hits++
+			}
+			return nil
+		},
+		Call: func(fn *evaluator.FnValue, args []evaluator.Value, pos lexer.Position) {
+			if body, ok := fn.Body.(*ast.BlockStatement); ok {
+				if f, ok := c.funcs[body]; ok {
+					f.calls++
+				}
+			}
+		},
+		Branch: func(stmt ast.Statement, arm int) {
+			if b, ok := c.branches[stmt]; ok && arm < len(b.hits) {
+				b.hits[arm]++
+			}
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Counting helpers
+// ---------------------------------------------------------------------------
+
+func stmtCounts(stmts []*stmtData) (hit, total int) {
+	for _, s := range stmts {
+		if s.hits > 0 {
+			hit++
+		}
+	}
+	return hit, len(stmts)
+}
+
+func branchCounts(branches []*branchData) (hit, total int) {
+	for _, b := range branches {
+		for _, h := range b.hits {
+			if h > 0 {
+				hit++
+			}
+		}
+		total += len(b.hits)
+	}
+	return hit, total
+}
+
+// lineHits maps each line that holds a statement to the highest hit count
+// of the statements starting on it.
+func (fd *fileData) lineHits() map[int]int {
+	lines := make(map[int]int)
+	for _, s := range fd.stmts {
+		if h, ok := lines[s.pos.Line]; !ok || s.hits > h {
+			lines[s.pos.Line] = s.hits
+		}
+	}
+	return lines
+}
+
+func sortedLines(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func percent(hit, total int) string {
+	if total == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(hit)/float64(total))
+}
@@ -0,0 +1,118 @@
+package coverage
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/parser"
+)
+
+const source = `fn sign(n) {
+    if n < 0 {
+        return "-"
+    } elif n == 0 {
+        return "0"
+    }
+    return "+"
+}
+fn name(n) {
+    match n {
+        1 => "one"
+        2 => "two"
+    }
+}
+fn unused() => 1
+let neg = sign(-1)
+let pos = [sign(5), sign(3)]
+name(1)`
+
+func collect(t *testing.T) *Collector {
+	t.Helper()
+	program, errs := parser.Parse(lexer.New(source, "m.glace").Tokenize())
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	c := New()
+	c.Register(program, "m.glace", source)
+	env := evaluator.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	env.Runtime().AddHooks(c.Hooks())
+	if _, err := evaluator.Eval(program, env); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestLineAndBranchHits(t *testing.T) {
+	c := collect(t)
+	fd := c.files["m.glace"]
+	hits := fd.lineHits()
+	for line, want := range map[int]int{1: 1, 2: 3, 3: 1, 5: 0, 7: 2, 10: 1, 11: 1, 12: 0, 15: 1, 16: 1, 17: 1, 18: 1} {
+		if hits[line] != want {
+			t.Errorf("line %d: %d hits, want %d", line, hits[line], want)
+		}
+	}
+
+	arms := map[int]string{}
+	for _, b := range fd.branches {
+		var parts []string
+		for i, label := range b.labels {
+			parts = append(parts, fmt.Sprintf("%s=%d", label, b.hits[i]))
+		}
+		arms[b.pos.Line] = strings.Join(parts, " ")
+	}
+	if arms[2] != "then=1 elif1=0 else=2" {
+		t.Errorf("if arms: %s", arms[2])
+	}
+	if arms[10] != "arm1=1 arm2=0 none=0" {
+		t.Errorf("match arms: %s", arms[10])
+	}
+}
+
+func TestWriteLCOV(t *testing.T) {
+	var b strings.Builder
+	if err := collect(t).WriteLCOV(&b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `TN:
+SF:m.glace
+FN:1,sign
+FN:9,name
+FN:15,unused
+FNDA:3,sign
+FNDA:1,name
+FNDA:0,unused
+FNF:3
+FNH:2
+BRDA:2,0,0,1
+BRDA:2,0,1,0
+BRDA:2,0,2,2
+BRDA:10,1,0,1
+BRDA:10,1,1,0
+BRDA:10,1,2,0
+BRF:6
+BRH:3
+DA:1,1
+DA:2,3
+DA:3,1
+DA:5,0
+DA:7,2
+DA:9,1
+DA:10,1
+DA:11,1
+DA:12,0
+DA:15,1
+DA:16,1
+DA:17,1
+DA:18,1
+LF:13
+LH:11
+end_of_record
+`
+	if b.String() != expected {
+		t.Errorf("wrong LCOV output:\n%s", b.String())
+	}
+}
@@ -0,0 +1,121 @@
+package coverage
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteSummary prints statement and branch coverage per file, followed by
+// the coverage of each function in it.
+func (c *Collector) WriteSummary(w io.Writer) {
+	fmt.Fprintf(w, "%-36s %10s %10s %10s\n", "coverage", "stmts", "branches", "calls")
+	for _, name := range c.order {
+		fd := c.files[name]
+		sh, st := stmtCounts(fd.stmts)
+		bh, bt := branchCounts(fd.branches)
+		called := 0
+		for _, f := range fd.funcs {
+			if f.calls > 0 {
+				called++
+			}
+		}
+		fmt.Fprintf(w, "%-36s %10s %10s %10s\n", name, percent(sh, st), percent(bh, bt),
+			fmt.Sprintf("%d/%d fn", called, len(fd.funcs)))
+
+		for _, f := range fd.funcs {
+			sh, st := stmtCounts(f.stmts)
+			bh, bt := branchCounts(f.arms)
+			label := fmt.Sprintf("  %s (line %d)", f.name, f.pos.Line)
+			fmt.Fprintf(w, "%-36s %10s %10s %10d\n", label, percent(sh, st), percent(bh, bt), f.calls)
+		}
+	}
+}
+
+// WriteAnnotated prints each registered file with the hit count of every
+// line that holds a statement. Lines that never ran are marked #####, and
+// lines with an if or match list how often each arm was taken.
+func (c *Collector) WriteAnnotated(w io.Writer) {
+	for _, name := range c.order {
+		fd := c.files[name]
+		hits := fd.lineHits()
+		arms := make(map[int][]string)
+		for _, b := range fd.branches {
+			for i, label := range b.labels {
+				arms[b.pos.Line] = append(arms[b.pos.Line], fmt.Sprintf("%s:%d", label, b.hits[i]))
+			}
+		}
+
+		fmt.Fprintf(w, "==> %s <==\n", name)
+		for i, text := range fd.lines {
+			n := i + 1
+			count := ""
+			if h, ok := hits[n]; ok {
+				count = fmt.Sprintf("%d", h)
+				if h == 0 {
+					count = "#####"
+				}
+			}
+			line := fmt.Sprintf("%7s | %4d | %s", count, n, text)
+			if a, ok := arms[n]; ok {
+				line += "    [" + strings.Join(a, " ") + "]"
+			}
+			fmt.Fprintln(w, line)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// WriteLCOV writes the coverage as an LCOV tracefile, readable by genhtml
+// and most editor coverage viewers.
+func (c *Collector) WriteLCOV(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("TN:\n")
+	for _, name := range c.order {
+		fd := c.files[name]
+		fmt.Fprintf(&b, "SF:%s\n", name)
+
+		fnHit := 0
+		for _, f := range fd.funcs {
+			fmt.Fprintf(&b, "FN:%d,%s\n", f.pos.Line, f.name)
+		}
+		for _, f := range fd.funcs {
+			fmt.Fprintf(&b, "FNDA:%d,%s\n", f.calls, f.name)
+			if f.calls > 0 {
+				fnHit++
+			}
+		}
+		fmt.Fprintf(&b, "FNF:%d\nFNH:%d\n", len(fd.funcs), fnHit)
+
+		for block, br := range fd.branches {
+			// Exactly one arm is taken per execution, so all zeros means
+			// the statement itself never ran; LCOV writes that as "-".
+			reached := false
+			for _, h := range br.hits {
+				reached = reached || h > 0
+			}
+			for i, h := range br.hits {
+				taken := "-"
+				if reached {
+					taken = fmt.Sprintf("%d", h)
+				}
+				fmt.Fprintf(&b, "BRDA:%d,%d,%d,%s\n", br.pos.Line, block, i, taken)
+			}
+		}
+		bh, bt := branchCounts(fd.branches)
+		fmt.Fprintf(&b, "BRF:%d\nBRH:%d\n", bt, bh)
+
+		hits := fd.lineHits()
+		lh := 0
+		for _, line := range sortedLines(hits) {
+			fmt.Fprintf(&b, "DA:%d,%d\n", line, hits[line])
+			if hits[line] > 0 {
+				lh++
+			}
+		}
+		fmt.Fprintf(&b, "LF:%d\nLH:%d\n", len(hits), lh)
+		b.WriteString("end_of_record\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,226 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/glace-lang/glace/evaluator"
+)
+
+const consolePrompt = "(glace-dbg) "
+
+// Console is the interactive, line-oriented front end used by
+// `glace debug <file>`.
+type Console struct {
+	dbg     *Debugger
+	in      *bufio.Scanner
+	out     io.Writer
+	file    string
+	lines   []string
+	frame   int    // frame selected for print/locals, 0 = innermost
+	lastCmd string // repeated when the user presses enter
+}
+
+// NewConsole wires a console to dbg. source is the program text, used by
+// the list command and to echo the current line.
+func NewConsole(dbg *Debugger, in io.Reader, out io.Writer, file, source string) *Console {
+	c := &Console{
+		dbg:   dbg,
+		in:    bufio.NewScanner(in),
+		out:   out,
+		file:  file,
+		lines: strings.Split(source, "\n"),
+	}
+	dbg.OnStop = c.stopped
+	return c
+}
+
+func (c *Console) stopped(reason string, bp *Breakpoint) error {
+	c.frame = 0
+	top := c.dbg.Stack()[0]
+	switch reason {
+	case ReasonBreakpoint:
+		fmt.Fprintf(c.out, "Breakpoint %d hit in %s at %s\n", bp.ID, top.Name, top.Pos)
+	case ReasonEntry:
+		fmt.Fprintf(c.out, "Stopped at entry: %s\n", top.Pos)
+	default:
+		fmt.Fprintf(c.out, "%s at %s\n", top.Name, top.Pos)
+	}
+	c.printLine(top.Pos.Line, true)
+
+	for {
+		fmt.Fprint(c.out, consolePrompt)
+		if !c.in.Scan() {
+			return ErrQuit
+		}
+		line := strings.TrimSpace(c.in.Text())
+		if line == "" {
+			line = c.lastCmd
+		}
+		if line == "" {
+			continue
+		}
+		c.lastCmd = line
+
+		resume, err := c.command(line)
+		if err != nil {
+			return err
+		}
+		if resume {
+			return nil
+		}
+	}
+}
+
+// command executes one console command. It reports whether the program
+// should resume.
+func (c *Console) command(line string) (bool, error) {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch cmd {
+	case "c", "continue":
+		c.dbg.Continue()
+		return true, nil
+	case "n", "next":
+		c.dbg.StepOver()
+		return true, nil
+	case "s", "step":
+		c.dbg.StepIn()
+		return true, nil
+	case "o", "out", "finish":
+		c.dbg.StepOut()
+		return true, nil
+	case "q", "quit":
+		return false, ErrQuit
+	case "b", "break":
+		c.setBreakpoint(arg)
+	case "d", "delete":
+		id, err := strconv.Atoi(arg)
+		if err != nil || !c.dbg.RemoveBreakpoint(id) {
+			fmt.Fprintf(c.out, "no breakpoint %q\n", arg)
+		}
+	case "bl", "breakpoints":
+		for _, bp := range c.dbg.Breakpoints() {
+			fmt.Fprintf(c.out, "  %d  %s:%d", bp.ID, bp.File, bp.Line)
+			if bp.Condition != "" {
+				fmt.Fprintf(c.out, " if %s", bp.Condition)
+			}
+			fmt.Fprintf(c.out, "  (hits: %d)\n", bp.Hits)
+		}
+	case "bt", "stack", "where":
+		for i, f := range c.dbg.Stack() {
+			marker := " "
+			if i == c.frame {
+				marker = ">"
+			}
+			fmt.Fprintf(c.out, "%s #%d  %s at %s\n", marker, i, f.Name, f.Pos)
+		}
+	case "f", "frame":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 || n >= len(c.dbg.Stack()) {
+			fmt.Fprintf(c.out, "no frame %q\n", arg)
+			break
+		}
+		c.frame = n
+		f := c.dbg.Stack()[n]
+		fmt.Fprintf(c.out, "#%d  %s at %s\n", n, f.Name, f.Pos)
+	case "p", "print":
+		val, err := c.dbg.Evaluate(arg, c.frame)
+		if err != nil {
+			fmt.Fprintf(c.out, "error: %s\n", err)
+			break
+		}
+		fmt.Fprintf(c.out, "%s\n", evaluator.Inspect(val))
+	case "locals", "vars":
+		c.printScopes()
+	case "l", "list":
+		c.list()
+	case "h", "help":
+		c.help()
+	default:
+		fmt.Fprintf(c.out, "unknown command %q (type 'help')\n", cmd)
+	}
+	return false, nil
+}
+
+// setBreakpoint handles `break [file:]line [if cond]`.
+func (c *Console) setBreakpoint(arg string) {
+	loc, cond, _ := strings.Cut(arg, " if ")
+	loc = strings.TrimSpace(loc)
+	file := c.file
+	if i := strings.LastIndex(loc, ":"); i >= 0 {
+		file, loc = loc[:i], loc[i+1:]
+	}
+	line, err := strconv.Atoi(loc)
+	if err != nil {
+		fmt.Fprintln(c.out, "usage: break [file:]line [if <condition>]")
+		return
+	}
+	bp, err := c.dbg.SetBreakpoint(file, line, strings.TrimSpace(cond))
+	if err != nil {
+		fmt.Fprintf(c.out, "invalid condition: %s\n", err)
+		return
+	}
+	fmt.Fprintf(c.out, "Breakpoint %d at %s:%d\n", bp.ID, bp.File, bp.Line)
+}
+
+func (c *Console) printScopes() {
+	f := c.dbg.Stack()[c.frame]
+	if f.Env == nil {
+		fmt.Fprintln(c.out, "  (no scope yet)")
+		return
+	}
+	for depth, scope := range Scopes(f.Env) {
+		label := fmt.Sprintf("scope %d", depth)
+		if scope.Global {
+			label = "globals"
+		}
+		fmt.Fprintf(c.out, "%s:\n", label)
+		for _, b := range scope.Bindings {
+			kind := "let"
+			if b.Mutable {
+				kind = "mut"
+			}
+			fmt.Fprintf(c.out, "  %s %s = %s\n", kind, b.Name, evaluator.Inspect(b.Value))
+		}
+	}
+}
+
+func (c *Console) list() {
+	line := c.dbg.Stack()[c.frame].Pos.Line
+	for n := line - 5; n <= line+5; n++ {
+		c.printLine(n, n == line)
+	}
+}
+
+func (c *Console) printLine(n int, current bool) {
+	if n < 1 || n > len(c.lines) {
+		return
+	}
+	marker := "  "
+	if current {
+		marker = "=>"
+	}
+	fmt.Fprintf(c.out, "%s %4d  %s\n", marker, n, c.lines[n-1])
+}
+
+func (c *Console) help() {
+	fmt.Fprintln(c.out, `Commands:
+  b, break [file:]line [if cond]  set a (conditional) breakpoint
+  d, delete <id>                  delete a breakpoint
+  bl, breakpoints                 list breakpoints
+  c, continue                     run to the next breakpoint
+  n, next                         step over
+  s, step                         step into
+  o, out                          step out of the current function
+  bt, stack                       show the call stack
+  f, frame <n>                    select a stack frame
+  p, print <expr>                 evaluate an expression in the selected frame
+  locals                          show variables in every enclosing scope
+  l, list                         show source around the current line
+  q, quit                         stop the program`)
+}
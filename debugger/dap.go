@@ -0,0 +1,496 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/parser"
+)
+
+// threadID is the only thread a Glace program has.
+const threadID = 1
+
+// dapMessage covers requests, responses and events of the Debug Adapter
+// Protocol; unused fields are omitted on the wire.
+type dapMessage struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    *bool           `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Body       interface{}     `json:"body,omitempty"`
+}
+
+// dapVariable is a DAP Variable.
+type dapVariable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// DAPServer speaks the Debug Adapter Protocol and drives a Debugger.
+type DAPServer struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	outMu sync.Mutex
+	seq   int
+
+	dbg         *Debugger
+	program     *ast.Program
+	launched    bool
+	configured  bool
+	stopOnEntry bool
+
+	pauseMu sync.Mutex
+	paused  bool
+	resume  chan error // sent to release a paused program
+	done    chan struct{}
+
+	refMu   sync.Mutex
+	refs    map[int]interface{} // *evaluator.Environment or evaluator.Value
+	nextRef int
+}
+
+// NewDAPServer creates a server that reads requests from in and writes
+// responses and events to out.
+func NewDAPServer(in io.Reader, out io.Writer) *DAPServer {
+	s := &DAPServer{
+		in:     bufio.NewReader(in),
+		out:    out,
+		dbg:    New(),
+		resume: make(chan error),
+		done:   make(chan struct{}),
+		refs:   make(map[int]interface{}),
+	}
+	s.dbg.OnStop = s.stopped
+	return s
+}
+
+// ServeStdio runs a DAP session over the process's stdin and stdout.
+// The program's own output is redirected and forwarded as output events
+// so it cannot corrupt the protocol stream.
+func ServeStdio() error {
+	protocol := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	os.Stdout = w
+	defer func() { os.Stdout = protocol }()
+
+	s := NewDAPServer(os.Stdin, protocol)
+	go s.forwardOutput(r, "stdout")
+	return s.Serve()
+}
+
+// Serve handles requests until the client disconnects.
+func (s *DAPServer) Serve() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Type != "request" {
+			continue
+		}
+		if quit := s.handle(msg); quit {
+			return nil
+		}
+	}
+}
+
+func (s *DAPServer) handle(req *dapMessage) bool {
+	switch req.Command {
+	case "initialize":
+		s.respond(req, map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsConditionalBreakpoints":   true,
+			"supportsEvaluateForHovers":        true,
+		})
+		s.event("initialized", nil)
+
+	case "launch":
+		var args struct {
+			Program     string `json:"program"`
+			StopOnEntry bool   `json:"stopOnEntry"`
+		}
+		json.Unmarshal(req.Arguments, &args)
+		if err := s.load(args.Program); err != nil {
+			s.fail(req, err.Error())
+			return false
+		}
+		s.stopOnEntry = args.StopOnEntry
+		s.launched = true
+		s.respond(req, nil)
+		s.maybeStart()
+
+	case "setBreakpoints":
+		s.setBreakpoints(req)
+
+	case "configurationDone":
+		s.respond(req, nil)
+		s.configured = true
+		s.maybeStart()
+
+	case "threads":
+		s.respond(req, map[string]interface{}{
+			"threads": []map[string]interface{}{{"id": threadID, "name": "main"}},
+		})
+
+	case "stackTrace":
+		s.stackTrace(req)
+
+	case "scopes":
+		s.scopes(req)
+
+	case "variables":
+		s.variables(req)
+
+	case "evaluate":
+		s.evaluate(req)
+
+	case "continue":
+		s.respond(req, map[string]interface{}{"allThreadsContinued": true})
+		s.dbg.Continue()
+		s.release(nil)
+
+	case "next":
+		s.respond(req, nil)
+		s.dbg.StepOver()
+		s.release(nil)
+
+	case "stepIn":
+		s.respond(req, nil)
+		s.dbg.StepIn()
+		s.release(nil)
+
+	case "stepOut":
+		s.respond(req, nil)
+		s.dbg.StepOut()
+		s.release(nil)
+
+	case "pause":
+		s.dbg.Pause()
+		s.respond(req, nil)
+
+	case "disconnect", "terminate":
+		s.respond(req, nil)
+		s.release(ErrQuit)
+		return true
+
+	default:
+		s.fail(req, fmt.Sprintf("unsupported request %q", req.Command))
+	}
+	return false
+}
+
+// ---------------------------------------------------------------------------
+// Running the program
+// ---------------------------------------------------------------------------
+
+func (s *DAPServer) load(path string) error {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	program, errs := parser.ParseSource(string(source), path)
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	s.program = program
+	return nil
+}
+
+// maybeStart runs the program once it has been both launched and
+// configured; clients may send those two requests in either order.
+func (s *DAPServer) maybeStart() {
+	if !s.launched || !s.configured || s.program == nil {
+		return
+	}
+	program := s.program
+	s.program = nil
+
+	env := evaluator.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	evaluator.RegisterHOBuiltins(env)
+	if !s.stopOnEntry {
+		s.dbg.Continue()
+	}
+	s.dbg.Attach(env)
+
+	go func() {
+		defer close(s.done)
+		exitCode := 0
+		if _, err := evaluator.Eval(program, env); err != nil && err != ErrQuit {
+			s.event("output", map[string]interface{}{"category": "stderr", "output": err.Error() + "\n"})
+			exitCode = 1
+		}
+		s.event("exited", map[string]interface{}{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// stopped runs on the program goroutine when the debugger pauses.
+func (s *DAPServer) stopped(reason string, bp *Breakpoint) error {
+	s.resetRefs()
+	body := map[string]interface{}{
+		"reason":            reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	}
+	if bp != nil {
+		body["hitBreakpointIds"] = []int{bp.ID}
+	}
+	s.pauseMu.Lock()
+	s.paused = true
+	s.pauseMu.Unlock()
+	s.event("stopped", body)
+	return <-s.resume
+}
+
+// release lets a paused program continue with err as the checkpoint's
+// result. It does nothing while the program is running.
+func (s *DAPServer) release(err error) {
+	s.pauseMu.Lock()
+	defer s.pauseMu.Unlock()
+	if !s.paused {
+		return
+	}
+	s.paused = false
+	s.resume <- err
+}
+
+func (s *DAPServer) forwardOutput(r io.Reader, category string) {
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			s.event("output", map[string]interface{}{"category": category, "output": string(buf[:n])})
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Requests
+// ---------------------------------------------------------------------------
+
+func (s *DAPServer) setBreakpoints(req *dapMessage) {
+	var args struct {
+		Source struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []struct {
+			Line      int    `json:"line"`
+			Condition string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+	json.Unmarshal(req.Arguments, &args)
+
+	s.dbg.ClearBreakpoints(args.Source.Path)
+	result := make([]map[string]interface{}, 0, len(args.Breakpoints))
+	for _, b := range args.Breakpoints {
+		bp, err := s.dbg.SetBreakpoint(args.Source.Path, b.Line, b.Condition)
+		if err != nil {
+			result = append(result, map[string]interface{}{"verified": false, "line": b.Line, "message": err.Error()})
+			continue
+		}
+		result = append(result, map[string]interface{}{"id": bp.ID, "verified": true, "line": bp.Line})
+	}
+	s.respond(req, map[string]interface{}{"breakpoints": result})
+}
+
+func (s *DAPServer) stackTrace(req *dapMessage) {
+	stack := s.dbg.Stack()
+	frames := make([]map[string]interface{}, len(stack))
+	for i, f := range stack {
+		frames[i] = map[string]interface{}{
+			"id":     i,
+			"name":   f.Name,
+			"line":   f.Pos.Line,
+			"column": f.Pos.Column,
+			"source": map[string]interface{}{"name": filepath.Base(f.Pos.File), "path": f.Pos.File},
+		}
+	}
+	s.respond(req, map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)})
+}
+
+func (s *DAPServer) scopes(req *dapMessage) {
+	var args struct {
+		FrameID int `json:"frameId"`
+	}
+	json.Unmarshal(req.Arguments, &args)
+
+	stack := s.dbg.Stack()
+	scopes := make([]map[string]interface{}, 0, 2)
+	if args.FrameID >= 0 && args.FrameID < len(stack) && stack[args.FrameID].Env != nil {
+		env := stack[args.FrameID].Env
+		if env.Parent() != nil {
+			scopes = append(scopes, map[string]interface{}{
+				"name": "Locals", "variablesReference": s.ref(env), "expensive": false,
+			})
+		}
+		root := env
+		for root.Parent() != nil {
+			root = root.Parent()
+		}
+		scopes = append(scopes, map[string]interface{}{
+			"name": "Globals", "variablesReference": s.ref(root), "expensive": false,
+		})
+	}
+	s.respond(req, map[string]interface{}{"scopes": scopes})
+}
+
+func (s *DAPServer) variables(req *dapMessage) {
+	var args struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	json.Unmarshal(req.Arguments, &args)
+
+	s.refMu.Lock()
+	target := s.refs[args.VariablesReference]
+	s.refMu.Unlock()
+
+	vars := make([]dapVariable, 0)
+	switch t := target.(type) {
+	case *evaluator.Environment:
+		// Locals merge every non-global scope, innermost binding first.
+		seen := make(map[string]bool)
+		for _, scope := range Scopes(t) {
+			if scope.Global && t.Parent() != nil {
+				break
+			}
+			for _, b := range scope.Bindings {
+				if !seen[b.Name] {
+					seen[b.Name] = true
+					vars = append(vars, s.variable(b.Name, b.Value))
+				}
+			}
+		}
+	case *evaluator.ArrayValue:
+		for i, e := range t.Elements {
+			vars = append(vars, s.variable(strconv.Itoa(i), e))
+		}
+	case *evaluator.MapValue:
+		keys := make([]string, 0, len(t.Pairs))
+		for k := range t.Pairs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			vars = append(vars, s.variable(k, t.Pairs[k]))
+		}
+	}
+	s.respond(req, map[string]interface{}{"variables": vars})
+}
+
+func (s *DAPServer) evaluate(req *dapMessage) {
+	var args struct {
+		Expression string `json:"expression"`
+		FrameID    int    `json:"frameId"`
+	}
+	json.Unmarshal(req.Arguments, &args)
+
+	val, err := s.dbg.Evaluate(args.Expression, args.FrameID)
+	if err != nil {
+		s.fail(req, err.Error())
+		return
+	}
+	v := s.variable("", val)
+	s.respond(req, map[string]interface{}{
+		"result":             v.Value,
+		"type":               v.Type,
+		"variablesReference": v.VariablesReference,
+	})
+}
+
+func (s *DAPServer) variable(name string, val evaluator.Value) dapVariable {
+	v := dapVariable{Name: name, Value: evaluator.Inspect(val), Type: val.Type()}
+	switch val.(type) {
+	case *evaluator.ArrayValue, *evaluator.MapValue:
+		v.VariablesReference = s.ref(val)
+	}
+	return v
+}
+
+// ref allocates a variables reference, valid until the program resumes.
+func (s *DAPServer) ref(target interface{}) int {
+	s.refMu.Lock()
+	defer s.refMu.Unlock()
+	s.nextRef++
+	s.refs[s.nextRef] = target
+	return s.nextRef
+}
+
+func (s *DAPServer) resetRefs() {
+	s.refMu.Lock()
+	defer s.refMu.Unlock()
+	s.refs = make(map[int]interface{})
+}
+
+// ---------------------------------------------------------------------------
+// Wire format
+// ---------------------------------------------------------------------------
+
+func (s *DAPServer) read() (*dapMessage, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("dap: bad Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	msg := &dapMessage{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (s *DAPServer) send(msg *dapMessage) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	s.seq++
+	msg.Seq = s.seq
+	data, _ := json.Marshal(msg)
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *DAPServer) respond(req *dapMessage, body interface{}) {
+	ok := true
+	s.send(&dapMessage{Type: "response", Command: req.Command, RequestSeq: req.Seq, Success: &ok, Body: body})
+}
+
+func (s *DAPServer) fail(req *dapMessage, message string) {
+	ok := false
+	s.send(&dapMessage{Type: "response", Command: req.Command, RequestSeq: req.Seq, Success: &ok, Message: message})
+}
+
+func (s *DAPServer) event(name string, body interface{}) {
+	s.send(&dapMessage{Type: "event", Event: name, Body: body})
+}
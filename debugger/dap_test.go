@@ -0,0 +1,175 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const dapSource = `fn add(a, b) {
+    let sum = a + b
+    return sum
+}
+let xs = [1, 2]
+let total = add(xs[0], xs[1])`
+
+// dapClient drives a DAPServer over pipes, one request at a time.
+type dapClient struct {
+	t    *testing.T
+	in   io.Writer
+	msgs chan *dapMessage
+	seq  int
+}
+
+func (c *dapClient) request(command string, args interface{}) int {
+	c.t.Helper()
+	c.seq++
+	data, _ := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatalf("%s: %v", command, err)
+	}
+	return c.seq
+}
+
+// next waits for the next response or event the server sends.
+func (c *dapClient) next() *dapMessage {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("server closed the stream")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+// call sends a request and returns the body of its response, which must
+// be the next message and must succeed.
+func (c *dapClient) call(command string, args interface{}) string {
+	c.t.Helper()
+	seq := c.request(command, args)
+	msg := c.next()
+	if msg.Type != "response" || msg.RequestSeq != seq || msg.Success == nil || !*msg.Success {
+		c.t.Fatalf("%s: unexpected reply %+v", command, msg)
+	}
+	data, _ := json.Marshal(msg.Body)
+	return string(data)
+}
+
+// expectEvent checks that the next message is the named event and
+// returns its body.
+func (c *dapClient) expectEvent(name string) string {
+	c.t.Helper()
+	msg := c.next()
+	if msg.Type != "event" || msg.Event != name {
+		c.t.Fatalf("expected %s event, got %+v", name, msg)
+	}
+	data, _ := json.Marshal(msg.Body)
+	return string(data)
+}
+
+func TestDAPSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "add.glace")
+	if err := os.WriteFile(path, []byte(dapSource), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- NewDAPServer(inR, outW).Serve()
+		outW.Close()
+	}()
+	c := &dapClient{t: t, in: inW, msgs: make(chan *dapMessage, 16)}
+	go func() {
+		defer close(c.msgs)
+		reader := &DAPServer{in: bufio.NewReader(outR)}
+		for {
+			msg, err := reader.read()
+			if err != nil {
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+
+	check := func(what, got string, wants ...string) {
+		t.Helper()
+		for _, want := range wants {
+			if !strings.Contains(got, want) {
+				t.Errorf("%s = %s\nwant it to contain %s", what, got, want)
+			}
+		}
+	}
+
+	check("initialize", c.call("initialize", map[string]string{"adapterID": "glace"}),
+		`"supportsConfigurationDoneRequest":true`)
+	c.expectEvent("initialized")
+	c.call("launch", map[string]interface{}{"program": path})
+	check("setBreakpoints", c.call("setBreakpoints", map[string]interface{}{
+		"source":      map[string]string{"path": path},
+		"breakpoints": []map[string]interface{}{{"line": 2}},
+	}), `{"id":1,"line":2,"verified":true}`)
+	c.call("configurationDone", nil)
+
+	check("stopped", c.expectEvent("stopped"), `"reason":"breakpoint"`, `"hitBreakpointIds":[1]`)
+	check("stackTrace", c.call("stackTrace", map[string]int{"threadId": threadID}),
+		`"totalFrames":2`,
+		`{"column":5,"id":0,"line":2,"name":"add"`,
+		`{"column":1,"id":1,"line":6,"name":"\u003cmain\u003e"`)
+
+	var scopes struct {
+		Scopes []struct {
+			Name               string
+			VariablesReference int
+		}
+	}
+	json.Unmarshal([]byte(c.call("scopes", map[string]int{"frameId": 0})), &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("scopes = %+v, want Locals and Globals", scopes.Scopes)
+	}
+	locals := c.call("variables", map[string]int{"variablesReference": scopes.Scopes[0].VariablesReference})
+	check("locals", locals, `{"name":"a","type":"int","value":"1","variablesReference":0}`,
+		`{"name":"b","type":"int","value":"2","variablesReference":0}`)
+	if strings.Contains(locals, `"sum"`) {
+		t.Errorf("locals = %s, sum is not bound yet", locals)
+	}
+
+	var globals struct {
+		Variables []dapVariable
+	}
+	json.Unmarshal([]byte(c.call("variables", map[string]int{"variablesReference": scopes.Scopes[1].VariablesReference})), &globals)
+	xsRef := 0
+	for _, v := range globals.Variables {
+		if v.Name == "xs" {
+			xsRef = v.VariablesReference
+		}
+	}
+	if xsRef == 0 {
+		t.Fatalf("globals = %+v, want xs with a variables reference", globals.Variables)
+	}
+	check("xs", c.call("variables", map[string]int{"variablesReference": xsRef}),
+		`{"name":"0","type":"int","value":"1"`, `{"name":"1","type":"int","value":"2"`)
+	check("evaluate", c.call("evaluate", map[string]interface{}{"expression": "a + b", "frameId": 0}),
+		`"result":"3"`)
+
+	check("continue", c.call("continue", map[string]int{"threadId": threadID}), `"allThreadsContinued":true`)
+	check("exited", c.expectEvent("exited"), `"exitCode":0`)
+	c.expectEvent("terminated")
+	c.call("disconnect", nil)
+
+	inW.Close()
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+}
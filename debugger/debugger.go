@@ -0,0 +1,348 @@
+// Package debugger implements breakpoints, stepping and state inspection
+// for Glace programs. The engine attaches to the evaluator through its
+// statement checkpoint hook; the console (glace debug) and the Debug
+// Adapter Protocol server (glace debug --dap) are two front ends over it.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/parser"
+)
+
+// ErrQuit is returned from Eval when the user stops the program from the
+// debugger.
+var ErrQuit = errors.New("debugger: program stopped")
+
+// stepMode says when the debugger should next pause.
+type stepMode int
+
+const (
+	modeContinue stepMode = iota // only at breakpoints
+	modeStepIn                   // at the very next statement
+	modeStepOver                 // at the next statement in this frame or a caller
+	modeStepOut                  // at the next statement in a caller
+)
+
+// Stop reasons passed to OnStop.
+const (
+	ReasonEntry      = "entry"
+	ReasonBreakpoint = "breakpoint"
+	ReasonStep       = "step"
+	ReasonPause      = "pause"
+)
+
+// Breakpoint is a line breakpoint with an optional condition.
+type Breakpoint struct {
+	ID        int
+	File      string
+	Line      int
+	Condition string // Glace expression; empty means always
+	Hits      int
+
+	cond ast.Expression
+}
+
+// Frame is one entry of the Glace call stack.
+type Frame struct {
+	Name string
+	Pos  lexer.Position         // statement about to run in this frame
+	Env  *evaluator.Environment // innermost scope of that statement; nil before the first one
+}
+
+// Debugger controls a running program. Create one with New, configure
+// OnStop, then Attach it to the environment before calling Eval.
+type Debugger struct {
+	// OnStop is called on the program's goroutine whenever execution
+	// pauses. It should block until the user picks how to resume (by
+	// calling Continue, StepIn, StepOver or StepOut) and then return nil.
+	// A non-nil error aborts the program.
+	OnStop func(reason string, bp *Breakpoint) error
+
+	mu          sync.Mutex
+	breakpoints []*Breakpoint
+	nextID      int
+	mode        stepMode
+	stepDepth   int
+
+	frames     []*Frame
+	evaluating bool
+}
+
+// New creates a Debugger that pauses before the first statement.
+func New() *Debugger {
+	return &Debugger{mode: modeStepIn, nextID: 1}
+}
+
+// Attach installs the debugger's checkpoint on env's runtime.
+func (d *Debugger) Attach(env *evaluator.Environment) {
+	d.frames = []*Frame{{Name: "<main>", Env: env}}
+	env.Runtime().AddHooks(&evaluator.Hooks{
+		Statement: d.checkpoint,
+		Call: func(fn *evaluator.FnValue, args []evaluator.Value, pos lexer.Position) {
+			name := fn.Name
+			if name == "" {
+				name = "<fn>"
+			}
+			d.frames = append(d.frames, &Frame{Name: name, Pos: pos})
+		},
+		Return: func(fn *evaluator.FnValue, result evaluator.Value, err error) {
+			if len(d.frames) > 1 {
+				d.frames = d.frames[:len(d.frames)-1]
+			}
+		},
+	})
+}
+
+// checkpoint runs before every statement and decides whether to pause.
+func (d *Debugger) checkpoint(stmt ast.Statement, env *evaluator.Environment) error {
+	if d.evaluating {
+		return nil
+	}
+	top := d.frames[len(d.frames)-1]
+	top.Pos = stmt.TokenPos()
+	top.Env = env
+
+	d.mu.Lock()
+	depth := len(d.frames)
+	var reason string
+	switch {
+	case d.mode == modeStepIn && d.stepDepth == 0:
+		reason = ReasonEntry
+	case d.mode == modeStepIn && d.stepDepth < 0:
+		reason = ReasonPause
+	case d.mode == modeStepIn:
+		reason = ReasonStep
+	case d.mode == modeStepOver && depth <= d.stepDepth:
+		reason = ReasonStep
+	case d.mode == modeStepOut && depth < d.stepDepth:
+		reason = ReasonStep
+	}
+	candidates := d.breakpointsAt(top.Pos)
+	d.mu.Unlock()
+
+	var hit *Breakpoint
+	for _, bp := range candidates {
+		if d.conditionHolds(bp, env) {
+			bp.Hits++
+			hit = bp
+			break
+		}
+	}
+	if hit != nil {
+		reason = ReasonBreakpoint
+	}
+	if reason == "" {
+		return nil
+	}
+	if d.OnStop == nil {
+		return nil
+	}
+	return d.OnStop(reason, hit)
+}
+
+func (d *Debugger) breakpointsAt(pos lexer.Position) []*Breakpoint {
+	var out []*Breakpoint
+	for _, bp := range d.breakpoints {
+		if bp.Line == pos.Line && sameFile(bp.File, pos.File) {
+			out = append(out, bp)
+		}
+	}
+	return out
+}
+
+func (d *Debugger) conditionHolds(bp *Breakpoint, env *evaluator.Environment) bool {
+	if bp.cond == nil {
+		return true
+	}
+	val, err := d.evalExpr(bp.cond, env)
+	if err != nil {
+		// A broken condition should not be silently skipped.
+		return true
+	}
+	return evaluator.IsTruthy(val)
+}
+
+// evalExpr evaluates expr without re-entering the checkpoint.
+func (d *Debugger) evalExpr(expr ast.Node, env *evaluator.Environment) (evaluator.Value, error) {
+	d.evaluating = true
+	defer func() { d.evaluating = false }()
+	return evaluator.Eval(expr, env)
+}
+
+// ---------------------------------------------------------------------------
+// Breakpoints
+// ---------------------------------------------------------------------------
+
+// SetBreakpoint adds a breakpoint at file:line. condition may be empty.
+func (d *Debugger) SetBreakpoint(file string, line int, condition string) (*Breakpoint, error) {
+	bp := &Breakpoint{File: file, Line: line, Condition: condition}
+	if condition != "" {
+		expr, err := parseExpression(condition)
+		if err != nil {
+			return nil, err
+		}
+		bp.cond = expr
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	bp.ID = d.nextID
+	d.nextID++
+	d.breakpoints = append(d.breakpoints, bp)
+	return bp, nil
+}
+
+// RemoveBreakpoint deletes the breakpoint with the given id.
+func (d *Debugger) RemoveBreakpoint(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearBreakpoints deletes every breakpoint in file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	kept := d.breakpoints[:0]
+	for _, bp := range d.breakpoints {
+		if !sameFile(bp.File, file) {
+			kept = append(kept, bp)
+		}
+	}
+	d.breakpoints = kept
+}
+
+// Breakpoints returns the current breakpoints in creation order.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]*Breakpoint(nil), d.breakpoints...)
+}
+
+// ---------------------------------------------------------------------------
+// Execution Control
+// ---------------------------------------------------------------------------
+
+// Continue resumes until the next breakpoint.
+func (d *Debugger) Continue() { d.resume(modeContinue) }
+
+// StepIn resumes until the next statement, entering calls.
+func (d *Debugger) StepIn() { d.resume(modeStepIn) }
+
+// StepOver resumes until the next statement in the current frame.
+func (d *Debugger) StepOver() { d.resume(modeStepOver) }
+
+// StepOut resumes until the current function returns to its caller.
+func (d *Debugger) StepOut() { d.resume(modeStepOut) }
+
+// Pause asks a running program to stop at its next statement. It is safe
+// to call from another goroutine.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mode = modeStepIn
+	d.stepDepth = -1
+}
+
+func (d *Debugger) resume(mode stepMode) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.mode = mode
+	d.stepDepth = len(d.frames)
+}
+
+// ---------------------------------------------------------------------------
+// Inspection (valid while paused)
+// ---------------------------------------------------------------------------
+
+// Stack returns the call stack, innermost frame first.
+func (d *Debugger) Stack() []*Frame {
+	out := make([]*Frame, len(d.frames))
+	for i, f := range d.frames {
+		out[len(d.frames)-1-i] = f
+	}
+	return out
+}
+
+// Evaluate parses src as an expression and evaluates it in the scope of
+// the given stack frame (0 is the innermost).
+func (d *Debugger) Evaluate(src string, frame int) (evaluator.Value, error) {
+	stack := d.Stack()
+	if frame < 0 || frame >= len(stack) {
+		return nil, fmt.Errorf("no frame %d", frame)
+	}
+	env := stack[frame].Env
+	if env == nil {
+		return nil, fmt.Errorf("frame %d has no scope yet", frame)
+	}
+	expr, err := parseExpression(src)
+	if err != nil {
+		return nil, err
+	}
+	return d.evalExpr(expr, env)
+}
+
+// Scope is one level of the Environment chain as seen from a frame.
+type Scope struct {
+	Global   bool
+	Bindings []evaluator.Binding
+}
+
+// Scopes walks the Environment chain of env from the innermost scope out
+// to the global one. Builtin functions are left out of the global scope.
+func Scopes(env *evaluator.Environment) []Scope {
+	var out []Scope
+	for e := env; e != nil; e = e.Parent() {
+		scope := Scope{Global: e.Parent() == nil}
+		for _, b := range e.Bindings() {
+			if _, builtin := b.Value.(*evaluator.BuiltinFn); builtin {
+				continue
+			}
+			scope.Bindings = append(scope.Bindings, b)
+		}
+		out = append(out, scope)
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// parseExpression parses src as a single Glace expression.
+func parseExpression(src string) (ast.Expression, error) {
+	program, errs := parser.ParseSource(src, "<debug>")
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	if len(program.Statements) != 1 {
+		return nil, fmt.Errorf("expected a single expression")
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil, fmt.Errorf("expected an expression, got %s", program.Statements[0])
+	}
+	return stmt.Expression, nil
+}
+
+// sameFile reports whether two paths name the same source file.
+func sameFile(a, b string) bool {
+	if a == b {
+		return true
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
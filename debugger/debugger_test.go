@@ -0,0 +1,69 @@
+package debugger
+
+import (
+	"testing"
+
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/parser"
+)
+
+func TestConditionalBreakpointAndStepOut(t *testing.T) {
+	input := `fn add(a, b) {
+    let sum = a + b
+    return sum
+}
+mut total = 0
+loop i in 0..5 {
+    total = add(total, i)
+}`
+
+	program, errs := parser.Parse(lexer.New(input, "test.glace").Tokenize())
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	env := evaluator.NewEnvironment()
+
+	dbg := New()
+	dbg.Continue()
+	if _, err := dbg.SetBreakpoint("test.glace", 2, "a == 3"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stops []string
+	dbg.OnStop = func(reason string, bp *Breakpoint) error {
+		top := dbg.Stack()[0]
+		stops = append(stops, reason+"@"+top.Name+":"+top.Pos.String())
+		if reason == ReasonBreakpoint {
+			val, err := dbg.Evaluate("b", 0)
+			if err != nil || val.String() != "3" {
+				t.Errorf("expected b == 3 at breakpoint, got %v (err %v)", val, err)
+			}
+			if len(dbg.Stack()) != 2 {
+				t.Errorf("expected 2 frames, got %d", len(dbg.Stack()))
+			}
+			dbg.StepOut()
+			return nil
+		}
+		dbg.Continue()
+		return nil
+	}
+	dbg.Attach(env)
+
+	if _, err := evaluator.Eval(program, env); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"breakpoint@add:test.glace:2:5",
+		"step@<main>:test.glace:7:5",
+	}
+	if len(stops) != len(expected) {
+		t.Fatalf("expected stops %v, got %v", expected, stops)
+	}
+	for i := range expected {
+		if stops[i] != expected[i] {
+			t.Errorf("stop[%d] - expected=%q, got=%q", i, expected[i], stops[i])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package diag defines the structured diagnostics reported by every stage
+// of Glace — lexer, parser, evaluator and the tools built on them — and
+// renders them for terminals and machines.
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Position represents a location in the source code. Lines and columns
+// start at 1; the zero Position means the location is unknown.
+type Position struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+func (p Position) String() string {
+	if p.File != "" {
+		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Severity ranks how serious a diagnostic is.
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+	Hint
+)
+
+var severityNames = [...]string{"error", "warning", "info", "hint"}
+
+func (s Severity) String() string {
+	if s < 0 || int(s) >= len(severityNames) {
+		return "unknown"
+	}
+	return severityNames[s]
+}
+
+// MarshalJSON encodes the severity by name.
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON decodes a severity name.
+func (s *Severity) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return err
+	}
+	for i, n := range severityNames {
+		if n == name {
+			*s = Severity(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", name)
+}
+
+// Diagnostic codes. Lexical errors are E00xx, syntax errors E01xx,
+// runtime errors E02xx and lint warnings W03xx.
+const (
+	CodeIllegalCharacter   = "E0001"
+	CodeUnterminatedString = "E0002"
+
+	CodeUnexpectedToken   = "E0100"
+	CodeExpectedToken     = "E0101"
+	CodeInvalidLiteral    = "E0102"
+	CodeInvalidAssignment = "E0103"
+	CodeInvalidPipeline   = "E0104"
+
+	CodeRuntime           = "E0200"
+	CodeUndefinedVariable = "E0201"
+	CodeImmutable         = "E0202"
+	CodeTypeMismatch      = "E0203"
+	CodeIndexOutOfRange   = "E0204"
+	CodeDivisionByZero    = "E0205"
+	CodeArity             = "E0206"
+	CodeNotCallable       = "E0207"
+
+	CodeUnreachable     = "W0301"
+	CodeUnusedVariable  = "W0302"
+	CodeUnusedParameter = "W0303"
+	CodeShadowsBuiltin  = "W0304"
+)
+
+// Diagnostic is a single problem found in a program. End is the position
+// just past the offending text; it equals Start when only a point is known.
+type Diagnostic struct {
+	Start    Position `json:"start"`
+	End      Position `json:"end"`
+	Code     string   `json:"code"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	Notes    []string `json:"notes,omitempty"`
+}
+
+// Error formats the diagnostic on one line, as "file:line:col: message",
+// so that a Diagnostic can be returned wherever an error is expected.
+func (d *Diagnostic) Error() string {
+	if d.Start.Line > 0 {
+		return fmt.Sprintf("%s: %s", d.Start, d.Message)
+	}
+	return d.Message
+}
+
+// HasErrors reports whether any of diags has Error severity.
+func HasErrors(diags []*Diagnostic) bool {
+	for _, d := range diags {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
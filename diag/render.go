@@ -0,0 +1,118 @@
+package diag
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Render writes d for a terminal: a header with severity, code and
+// message, the location, the offending source line with the span
+// underlined, and any notes. sources maps file names to their text;
+// the snippet is omitted when the file is missing from it.
+//
+//	error[E0201]: undefined variable 'y'
+//	  --> main.glace:3:9
+//	   |
+//	 3 |     let x = y + 1
+//	   |             ^
+//	   = note: ...
+func Render(w io.Writer, d *Diagnostic, sources map[string]string) {
+	if d.Code != "" {
+		fmt.Fprintf(w, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+	} else {
+		fmt.Fprintf(w, "%s: %s\n", d.Severity, d.Message)
+	}
+	if d.Start.Line == 0 {
+		for _, n := range d.Notes {
+			fmt.Fprintf(w, "  = note: %s\n", n)
+		}
+		return
+	}
+
+	num := fmt.Sprintf("%d", d.Start.Line)
+	gutter := strings.Repeat(" ", len(num))
+	fmt.Fprintf(w, "%s--> %s\n", gutter, d.Start)
+
+	if line, ok := sourceLine(sources, d.Start); ok {
+		fmt.Fprintf(w, "%s |\n", gutter)
+		fmt.Fprintf(w, "%s | %s\n", num, line)
+		if d.Start.Column > 0 && d.Start.Column <= len(line)+1 {
+			fmt.Fprintf(w, "%s | %s%s\n", gutter, indentFor(line, d.Start.Column-1), underline(d, len(line)))
+		}
+	}
+	for _, n := range d.Notes {
+		fmt.Fprintf(w, "%s = note: %s\n", gutter, n)
+	}
+}
+
+// RenderAll renders each diagnostic in turn, separated by blank lines.
+func RenderAll(w io.Writer, diags []*Diagnostic, sources map[string]string) {
+	for i, d := range diags {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		Render(w, d, sources)
+	}
+}
+
+// WriteJSON writes diags as a JSON array, one object per diagnostic:
+//
+//	{"start": {"file": ..., "line": ..., "column": ...}, "end": {...},
+//	 "code": "E0201", "severity": "error", "message": ..., "notes": [...]}
+//
+// An empty list is written as [] rather than null.
+func WriteJSON(w io.Writer, diags []*Diagnostic) error {
+	if diags == nil {
+		diags = []*Diagnostic{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(diags)
+}
+
+func sourceLine(sources map[string]string, pos Position) (string, bool) {
+	src, ok := sources[pos.File]
+	if !ok {
+		return "", false
+	}
+	lines := strings.Split(src, "\n")
+	if pos.Line > len(lines) {
+		return "", false
+	}
+	return strings.TrimRight(lines[pos.Line-1], " \t\r"), true
+}
+
+// indentFor returns whitespace as wide as the first n bytes of line,
+// keeping tabs so the caret lines up with the source above it.
+func indentFor(line string, n int) string {
+	if n > len(line) {
+		n = len(line)
+	}
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		if line[i] == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// underline marks the span of d on its first line: one caret for a point,
+// otherwise a caret under every column up to End or the end of the line.
+func underline(d *Diagnostic, lineLen int) string {
+	width := 1
+	if d.End.Line == d.Start.Line && d.End.Column > d.Start.Column {
+		width = d.End.Column - d.Start.Column
+	} else if d.End.Line > d.Start.Line {
+		width = lineLen - d.Start.Column + 1
+	}
+	if width < 1 {
+		width = 1
+	}
+	return strings.Repeat("^", width)
+}
@@ -0,0 +1,31 @@
+package diag
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	d := &Diagnostic{
+		Start:    Position{File: "t.glace", Line: 2, Column: 9},
+		End:      Position{File: "t.glace", Line: 2, Column: 12},
+		Code:     CodeUndefinedVariable,
+		Severity: Error,
+		Message:  "undefined variable 'foo'",
+		Notes:    []string{"in main, called at t.glace:5:1"},
+	}
+	src := "let x = 1\n\tlet y = foo + x\n"
+
+	var b strings.Builder
+	Render(&b, d, map[string]string{"t.glace": src})
+
+	expected := "error[E0201]: undefined variable 'foo'\n" +
+		" --> t.glace:2:9\n" +
+		"  |\n" +
+		"2 | \tlet y = foo + x\n" +
+		"  | \t       ^^^\n" +
+		"  = note: in main, called at t.glace:5:1\n"
+	if b.String() != expected {
+		t.Errorf("wrong rendering.\nexpected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
@@ -0,0 +1,156 @@
+// Package doc extracts the documentation of a Glace program's top-level
+// functions and bindings, written as /// comments, and renders it as
+// Markdown or HTML the way `glace doc` prints it.
+//
+// Doc lines indented by four spaces or a tab are examples, as in Go:
+//
+//	/// Adds two numbers.
+//	///
+//	///     add(1, 2)  // 3
+//	fn add(a, b) => a + b
+package doc
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/lexer"
+)
+
+// Entry is the documentation of one top-level fn or let.
+type Entry struct {
+	Name      string
+	Kind      string // "fn" or "let"
+	Signature string // e.g. "fn add(a, b)" or "let limit"
+	Text      string // the prose, paragraphs separated by blank lines
+	Examples  []string
+	Pos       lexer.Position
+}
+
+// Entries returns the documentation of prog's top-level functions, and
+// of its lets that have doc comments, in source order. Names starting
+// with _ are private and left out.
+func Entries(prog *ast.Program) []Entry {
+	var out []Entry
+	for _, stmt := range prog.Statements {
+		var e Entry
+		var doc string
+		switch s := stmt.(type) {
+		case *ast.FnDeclaration:
+			e = Entry{Name: s.Name, Kind: "fn", Pos: s.Pos}
+			e.Signature = fmt.Sprintf("fn %s(%s)", s.Name, strings.Join(s.Params, ", "))
+			doc = s.Doc
+		case *ast.LetStatement:
+			if s.Doc == "" {
+				continue
+			}
+			e = Entry{Name: s.Name, Kind: "let", Pos: s.Pos}
+			e.Signature = "let " + s.Name
+			if fn, ok := s.Value.(*ast.FnLiteral); ok {
+				e.Signature = fmt.Sprintf("let %s = fn(%s)", s.Name, strings.Join(fn.Params, ", "))
+			}
+			doc = s.Doc
+		default:
+			continue
+		}
+		if strings.HasPrefix(e.Name, "_") {
+			continue
+		}
+		e.Text, e.Examples = split(doc)
+		out = append(out, e)
+	}
+	return out
+}
+
+// split separates a doc comment into prose and indented examples.
+// Blank lines inside an example belong to it.
+func split(doc string) (text string, examples []string) {
+	var prose, example []string
+	flush := func() {
+		for len(example) > 0 && example[len(example)-1] == "" {
+			example = example[:len(example)-1]
+		}
+		if len(example) > 0 {
+			examples = append(examples, strings.Join(example, "\n"))
+		}
+		example = nil
+	}
+	for _, line := range strings.Split(doc, "\n") {
+		switch {
+		case strings.HasPrefix(line, "    "):
+			example = append(example, line[4:])
+		case strings.HasPrefix(line, "\t"):
+			example = append(example, line[1:])
+		case strings.TrimSpace(line) == "" && example != nil:
+			example = append(example, "")
+		default:
+			flush()
+			prose = append(prose, strings.TrimRight(line, " \t"))
+		}
+	}
+	flush()
+	return strings.TrimSpace(strings.Join(prose, "\n")), examples
+}
+
+// paragraphs splits text at blank lines.
+func paragraphs(text string) []string {
+	var out []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Rendering
+// ---------------------------------------------------------------------------
+
+// Markdown writes entries as a Markdown document headed by title.
+func Markdown(w io.Writer, title string, entries []Entry) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", title)
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n## %s\n\n```glace\n%s\n```\n", e.Name, e.Signature)
+		for _, p := range paragraphs(e.Text) {
+			fmt.Fprintf(&b, "\n%s\n", p)
+		}
+		for _, ex := range e.Examples {
+			fmt.Fprintf(&b, "\nExample:\n\n```glace\n%s\n```\n", ex)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// HTML writes entries as a standalone HTML page headed by title.
+func HTML(w io.Writer, title string, entries []Entry) error {
+	var b strings.Builder
+	t := html.EscapeString(title)
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n<body>\n<h1>%s</h1>\n", t, t)
+	if len(entries) > 0 {
+		b.WriteString("<ul>\n")
+		for _, e := range entries {
+			fmt.Fprintf(&b, "<li><a href=\"#%s\">%s</a></li>\n", html.EscapeString(e.Name), html.EscapeString(e.Name))
+		}
+		b.WriteString("</ul>\n")
+	}
+	for _, e := range entries {
+		name := html.EscapeString(e.Name)
+		fmt.Fprintf(&b, "<h2 id=\"%s\">%s</h2>\n", name, name)
+		fmt.Fprintf(&b, "<pre><code>%s</code></pre>\n", html.EscapeString(e.Signature))
+		for _, p := range paragraphs(e.Text) {
+			fmt.Fprintf(&b, "<p>%s</p>\n", html.EscapeString(p))
+		}
+		for _, ex := range e.Examples {
+			fmt.Fprintf(&b, "<p>Example:</p>\n<pre><code>%s</code></pre>\n", html.EscapeString(ex))
+		}
+	}
+	b.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
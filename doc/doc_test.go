@@ -0,0 +1,53 @@
+package doc
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/parser"
+)
+
+func TestMarkdown(t *testing.T) {
+	input := `/// Adds two numbers.
+///
+/// Works on floats too.
+///
+///     add(1, 2)  // 3
+fn add(a, b) => a + b
+
+// An ordinary comment.
+fn _helper() => none
+
+/// The largest size.
+let limit = 10
+let hidden = 1
+
+/// Squares x.
+let sq = fn(x) => x * x
+`
+	prog, diags := parser.ParseSource(input, "math.glace")
+	if len(diags) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	expected := "# math\n" +
+		"\n## add\n\n```glace\nfn add(a, b)\n```\n" +
+		"\nAdds two numbers.\n\nWorks on floats too.\n" +
+		"\nExample:\n\n```glace\nadd(1, 2)  // 3\n```\n" +
+		"\n## limit\n\n```glace\nlet limit\n```\n\nThe largest size.\n" +
+		"\n## sq\n\n```glace\nlet sq = fn(x)\n```\n\nSquares x.\n"
+	var b strings.Builder
+	if err := Markdown(&b, "math", Entries(prog)); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, b.String())
+	}
+
+	b.Reset()
+	HTML(&b, "math", Entries(prog))
+	for _, want := range []string{`<h2 id="add">add</h2>`, "<p>Works on floats too.</p>", "<pre><code>add(1, 2)  // 3</code></pre>"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("HTML output is missing %q:\n%s", want, b.String())
+		}
+	}
+}
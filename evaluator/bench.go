@@ -0,0 +1,125 @@
+package evaluator
+
+import (
+	"io"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
+	"github.com/glace-lang/glace/lexer"
+)
+
+// ---------------------------------------------------------------------------
+// Benchmark Runner (used by `glace bench` command)
+// ---------------------------------------------------------------------------
+
+// BenchOptions controls which benchmarks run and for how long.
+type BenchOptions struct {
+	Run       *regexp.Regexp // only run benchmarks whose name matches; nil runs all
+	Benchtime time.Duration  // how long to run each benchmark; 0 means one second
+}
+
+// BenchResult holds the measurements of a single bench block.
+type BenchResult struct {
+	Name        string
+	Pos         lexer.Position
+	N           int   // iterations in the final, measured run
+	NsPerOp     int64 // wall time per iteration
+	AllocsPerOp int64 // Go heap allocations per iteration
+	BytesPerOp  int64 // Go heap bytes allocated per iteration
+	Error       string
+	Diagnostic  *diag.Diagnostic // the failure as a diagnostic; nil when it ran
+}
+
+// maxBenchN bounds the iteration count, as in Go's testing package.
+const maxBenchN = 1_000_000_000
+
+// RunBenchmarks evaluates a program and runs its bench blocks. Like Go's
+// testing.B, each body first runs once, then with more iterations
+// predicted from the previous run until a run lasts opts.Benchtime.
+// Output printed by a body is discarded. If the program's own code
+// fails, no bench runs and its error is returned.
+func RunBenchmarks(program *ast.Program, env *Environment, opts BenchOptions) ([]BenchResult, error) {
+	benchtime := opts.Benchtime
+	if benchtime <= 0 {
+		benchtime = time.Second
+	}
+
+	rt := env.Runtime()
+	for _, stmt := range program.Statements {
+		err := rt.onStatement(stmt, env)
+		if err == nil {
+			_, err = Eval(stmt, env)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]BenchResult, 0)
+	for _, stmt := range program.Statements {
+		bb, ok := stmt.(*ast.BenchBlock)
+		if !ok || (opts.Run != nil && !opts.Run.MatchString(bb.Description)) {
+			continue
+		}
+		result := BenchResult{Name: bb.Description, Pos: bb.Pos}
+		n := 1
+		for {
+			elapsed, mallocs, bytes, err := runBench(bb, env, n)
+			if err != nil {
+				result.Error = err.Error()
+				result.Diagnostic = ErrorDiagnostic(err, bb.Pos)
+				break
+			}
+			if elapsed >= benchtime || n >= maxBenchN {
+				result.N = n
+				result.NsPerOp = elapsed.Nanoseconds() / int64(n)
+				result.AllocsPerOp = int64(mallocs) / int64(n)
+				result.BytesPerOp = int64(bytes) / int64(n)
+				break
+			}
+			n = predictN(benchtime, n, elapsed)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// runBench runs bb's body n times, each in a fresh scope, and measures
+// the time taken and the Go heap allocations made.
+func runBench(bb *ast.BenchBlock, env *Environment, n int) (elapsed time.Duration, mallocs, bytes uint64, err error) {
+	rt := env.Runtime()
+	saved := rt.stdout
+	rt.stdout = io.Discard
+	defer func() { rt.stdout = saved }()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		if _, err := Eval(bb.Body, NewEnclosedEnvironment(env)); err != nil {
+			return 0, 0, 0, err
+		}
+	}
+	elapsed = time.Since(start)
+	runtime.ReadMemStats(&after)
+	return elapsed, after.Mallocs - before.Mallocs, after.TotalAlloc - before.TotalAlloc, nil
+}
+
+// predictN returns the iteration count for the next run: enough to fill
+// benchtime at the last run's rate, with 20% headroom, growing at most
+// 100-fold and at least by one.
+func predictN(benchtime time.Duration, last int, elapsed time.Duration) int {
+	ns := elapsed.Nanoseconds()
+	if ns <= 0 {
+		ns = 1
+	}
+	n := int64(float64(last) * float64(benchtime.Nanoseconds()) / float64(ns))
+	n += n / 5
+	n = min(n, 100*int64(last))
+	n = max(n, int64(last)+1)
+	return int(min(n, maxBenchN))
+}
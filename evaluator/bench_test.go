@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glace-lang/glace/parser"
+)
+
+func TestRunBenchmarks(t *testing.T) {
+	input := `mut calls = 0
+bench "count" {
+    calls = calls + 1
+    print("noise")
+}
+bench "fails" {
+    nope()
+}
+bench "skipped" {
+    calls = -1000000
+}
+test "not a bench" { calls = -1000000 }`
+	program, errs := parser.ParseSource(input, "t.glace")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+	var out strings.Builder
+	env.Runtime().SetStdout(&out)
+
+	opts := BenchOptions{Run: regexp.MustCompile("count|fails"), Benchtime: 10 * time.Millisecond}
+	results, err := RunBenchmarks(program, env, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	count := results[0]
+	if count.Name != "count" || count.Diagnostic != nil || count.N < 2 || count.NsPerOp <= 0 {
+		t.Errorf("bad measurement: %+v", count)
+	}
+	// Every run counts, from the single first iteration to the measured one.
+	if calls, _ := env.Get("calls"); calls.(*IntValue).Value < int64(count.N) {
+		t.Errorf("body ran %s times, want at least %d", calls, count.N)
+	}
+	if fails := results[1]; fails.Diagnostic == nil || !strings.Contains(fails.Error, "undefined variable 'nope'") {
+		t.Errorf("expected a failure, got %+v", fails)
+	}
+	if out.Len() != 0 {
+		t.Errorf("bench output leaked: %q", out.String())
+	}
+}
+
+func TestRunBenchmarksSetupError(t *testing.T) {
+	input := `let data = undefined_fn(3)
+bench "uses data" {
+    len(data)
+}`
+	program, errs := parser.ParseSource(input, "t.glace")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+
+	results, err := RunBenchmarks(program, env, BenchOptions{Benchtime: time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "undefined variable 'undefined_fn'") {
+		t.Errorf("expected the setup error, got %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("benches ran after a setup error: %+v", results)
+	}
+}
+
+func TestPredictN(t *testing.T) {
+	tests := []struct {
+		last    int
+		elapsed time.Duration
+		want    int
+	}{
+		{1, time.Millisecond, 100},         // growth is capped at 100x
+		{100, 500 * time.Millisecond, 240}, // fills the rest with 20% headroom
+		{100, 2 * time.Second, 101},        // always grows
+		{maxBenchN, time.Nanosecond, maxBenchN},
+	}
+	for _, tt := range tests {
+		if got := predictN(time.Second, tt.last, tt.elapsed); got != tt.want {
+			t.Errorf("predictN(1s, %d, %s) = %d, want %d", tt.last, tt.elapsed, got, tt.want)
+		}
+	}
+}
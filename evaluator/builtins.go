@@ -7,8 +7,9 @@ import (
 
 // RegisterBuiltins populates the given environment with all built-in functions.
 func RegisterBuiltins(env *Environment) {
+	rt := env.Runtime()
 	builtins := []*BuiltinFn{
-		builtinPrint(),
+		builtinPrint(rt),
 		builtinLen(),
 		builtinPush(),
 		builtinPop(),
@@ -16,27 +17,33 @@ func RegisterBuiltins(env *Environment) {
 		builtinStr(),
 		builtinInt(),
 		builtinFloat(),
-		builtinInput(),
+		builtinInput(rt),
 		builtinAssert(),
 		builtinArray(),
+		builtinHelp(rt),
+		builtinSnapshot(rt),
 	}
+	builtins = append(builtins, assertBuiltins()...)
 
 	for _, b := range builtins {
+		b.runtime = rt
 		env.Define(b.Name, b, false)
 	}
 }
 
 // ---------------- Built-in Implementations ----------------
 
-func builtinPrint() *BuiltinFn {
+func builtinPrint(rt *Runtime) *BuiltinFn {
 	return &BuiltinFn{
-		Name: "print",
+		Name:      "print",
+		Signature: "print(args...)",
+		Doc:       "Print values to stdout, separated by spaces and newline-terminated.",
 		Fn: func(args []Value) (Value, error) {
 			parts := make([]string, len(args))
 			for i, a := range args {
 				parts[i] = a.String()
 			}
-			fmt.Println(strings.Join(parts, " "))
+			fmt.Fprintln(rt.Stdout(), strings.Join(parts, " "))
 			return NONE, nil
 		},
 	}
@@ -44,7 +51,9 @@ func builtinPrint() *BuiltinFn {
 
 func builtinLen() *BuiltinFn {
 	return &BuiltinFn{
-		Name: "len",
+		Name:      "len",
+		Signature: "len(v)",
+		Doc:       "Length of a string, array, map or range.",
 		Fn: func(args []Value) (Value, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("len() takes exactly 1 argument, got %d", len(args))
@@ -67,7 +76,9 @@ func builtinLen() *BuiltinFn {
 
 func builtinPush() *BuiltinFn {
 	return &BuiltinFn{
-		Name: "push",
+		Name:      "push",
+		Signature: "push(arr, val)",
+		Doc:       "Append val to arr.",
 		Fn: func(args []Value) (Value, error) {
 			if len(args) != 2 {
 				return nil, fmt.Errorf("push() takes 2 arguments (array, value), got %d", len(args))
@@ -84,7 +95,9 @@ func builtinPush() *BuiltinFn {
 
 func builtinPop() *BuiltinFn {
 	return &BuiltinFn{
-		Name: "pop",
+		Name:      "pop",
+		Signature: "pop(arr)",
+		Doc:       "Remove and return the last element of arr.",
 		Fn: func(args []Value) (Value, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("pop() takes 1 argument, got %d", len(args))
@@ -105,7 +118,9 @@ func builtinPop() *BuiltinFn {
 
 func builtinTypeOf() *BuiltinFn {
 	return &BuiltinFn{
-		Name: "type",
+		Name:      "type",
+		Signature: "type(v)",
+		Doc:       "Return the type name of v as a string.",
 		Fn: func(args []Value) (Value, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("type() takes 1 argument, got %d", len(args))
@@ -117,7 +132,9 @@ func builtinTypeOf() *BuiltinFn {
 
 func builtinStr() *BuiltinFn {
 	return &BuiltinFn{
-		Name: "str",
+		Name:      "str",
+		Signature: "str(v)",
+		Doc:       "Convert v to a string.",
 		Fn: func(args []Value) (Value, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("str() takes 1 argument, got %d", len(args))
@@ -129,7 +146,9 @@ func builtinStr() *BuiltinFn {
 
 func builtinInt() *BuiltinFn {
 	return &BuiltinFn{
-		Name: "int",
+		Name:      "int",
+		Signature: "int(v)",
+		Doc:       "Convert v to an integer.",
 		Fn: func(args []Value) (Value, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("int() takes 1 argument, got %d", len(args))
@@ -160,7 +179,9 @@ func builtinInt() *BuiltinFn {
 
 func builtinFloat() *BuiltinFn {
 	return &BuiltinFn{
-		Name: "float",
+		Name:      "float",
+		Signature: "float(v)",
+		Doc:       "Convert v to a float.",
 		Fn: func(args []Value) (Value, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("float() takes 1 argument, got %d", len(args))
@@ -184,16 +205,18 @@ func builtinFloat() *BuiltinFn {
 	}
 }
 
-func builtinInput() *BuiltinFn {
+func builtinInput(rt *Runtime) *BuiltinFn {
 	return &BuiltinFn{
-		Name: "input",
+		Name:      "input",
+		Signature: "input(prompt?)",
+		Doc:       "Print prompt, then read a line from stdin.",
 		Fn: func(args []Value) (Value, error) {
 			// Optional prompt
 			if len(args) > 0 {
-				fmt.Print(args[0].String())
+				fmt.Fprint(rt.Stdout(), args[0].String())
 			}
-			var line string
-			fmt.Scanln(&line)
+			// At end of input the line is empty, as it always has been.
+			line, _ := rt.readLine()
 			return NewString(line), nil
 		},
 	}
@@ -201,7 +224,9 @@ func builtinInput() *BuiltinFn {
 
 func builtinAssert() *BuiltinFn {
 	return &BuiltinFn{
-		Name: "assert",
+		Name:      "assert",
+		Signature: "assert(cond, msg?)",
+		Doc:       "Raise an error with msg if cond is falsy.",
 		Fn: func(args []Value) (Value, error) {
 			if len(args) < 1 {
 				return nil, fmt.Errorf("assert() takes at least 1 argument")
@@ -220,7 +245,9 @@ func builtinAssert() *BuiltinFn {
 
 func builtinArray() *BuiltinFn {
 	return &BuiltinFn{
-		Name: "array",
+		Name:      "array",
+		Signature: "array(range)",
+		Doc:       "Convert a range to an array.",
 		Fn: func(args []Value) (Value, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("array() takes 1 argument, got %d", len(args))
@@ -237,3 +264,41 @@ func builtinArray() *BuiltinFn {
 		},
 	}
 }
+
+// FnDoc returns the signature and documentation of a function value, as
+// shown by help and the REPL's :doc. ok is false if v is not a function.
+func FnDoc(v Value) (signature, doc string, ok bool) {
+	switch fn := v.(type) {
+	case *BuiltinFn:
+		return fn.Signature, fn.Doc, true
+	case *FnValue:
+		name := fn.Name
+		if name == "" {
+			name = "fn"
+		}
+		return name + "(" + strings.Join(fn.Params, ", ") + ")", fn.Doc, true
+	}
+	return "", "", false
+}
+
+func builtinHelp(rt *Runtime) *BuiltinFn {
+	return &BuiltinFn{
+		Name:      "help",
+		Signature: "help(fn)",
+		Doc:       "Print the signature and documentation of fn.",
+		Fn: func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("help expects 1 argument (fn), got %d", len(args))
+			}
+			sig, doc, ok := FnDoc(args[0])
+			if !ok {
+				return nil, fmt.Errorf("help: argument must be a function, got %s", args[0].Type())
+			}
+			fmt.Fprintln(rt.Stdout(), sig)
+			if doc != "" {
+				fmt.Fprintln(rt.Stdout(), doc)
+			}
+			return NONE, nil
+		},
+	}
+}
@@ -0,0 +1,312 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/glace-lang/glace/lexer"
+)
+
+// Assertions for tests. Each fails with a message that says what was
+// expected; assert_eq points at the first difference inside arrays and
+// maps.
+
+func assertBuiltins() []*BuiltinFn {
+	return []*BuiltinFn{
+		builtinAssertEq(),
+		builtinAssertNe(),
+		builtinAssertApprox(),
+		builtinAssertContains(),
+		builtinAssertRaises(),
+	}
+}
+
+// assertFailure builds the error for a failed assertion, prefixed by the
+// caller's message when one was passed at index at.
+func assertFailure(name string, args []Value, at int, format string, a ...interface{}) error {
+	msg := name + " failed"
+	if len(args) > at {
+		msg = args[at].String()
+	}
+	return fmt.Errorf("%s: %s", msg, fmt.Sprintf(format, a...))
+}
+
+func builtinAssertEq() *BuiltinFn {
+	return &BuiltinFn{
+		Name:      "assert_eq",
+		Signature: "assert_eq(actual, expected, msg?)",
+		Doc:       "Raise an error showing the first difference unless actual equals expected.",
+		Fn: func(args []Value) (Value, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, fmt.Errorf("assert_eq() takes 2 or 3 arguments, got %d", len(args))
+			}
+			actual, expected := args[0], args[1]
+			if equals(actual, expected) {
+				return NONE, nil
+			}
+			return nil, assertFailure("assert_eq", args, 2, "%s", Diff(expected, actual))
+		},
+	}
+}
+
+func builtinAssertNe() *BuiltinFn {
+	return &BuiltinFn{
+		Name:      "assert_ne",
+		Signature: "assert_ne(actual, unexpected, msg?)",
+		Doc:       "Raise an error if actual equals unexpected.",
+		Fn: func(args []Value) (Value, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, fmt.Errorf("assert_ne() takes 2 or 3 arguments, got %d", len(args))
+			}
+			if !equals(args[0], args[1]) {
+				return NONE, nil
+			}
+			return nil, assertFailure("assert_ne", args, 2, "expected a value other than %s", Inspect(args[1]))
+		},
+	}
+}
+
+func builtinAssertApprox() *BuiltinFn {
+	return &BuiltinFn{
+		Name:      "assert_approx",
+		Signature: "assert_approx(actual, expected, tolerance?, msg?)",
+		Doc:       "Raise an error unless two numbers differ by at most tolerance (default 1e-9).",
+		Fn: func(args []Value) (Value, error) {
+			if len(args) < 2 || len(args) > 4 {
+				return nil, fmt.Errorf("assert_approx() takes 2 to 4 arguments, got %d", len(args))
+			}
+			nums := make([]float64, 0, 3)
+			for i, name := range []string{"actual", "expected", "tolerance"} {
+				if i >= len(args) {
+					break
+				}
+				n, ok := toFloat(args[i])
+				if !ok {
+					return nil, fmt.Errorf("assert_approx() %s must be a number, got '%s'", name, args[i].Type())
+				}
+				nums = append(nums, n)
+			}
+			tolerance := 1e-9
+			if len(nums) == 3 {
+				tolerance = nums[2]
+			}
+			if off := math.Abs(nums[0] - nums[1]); off > tolerance || math.IsNaN(off) {
+				return nil, assertFailure("assert_approx", args, 3, "expected %s ± %g, got %s (off by %g)",
+					args[1], tolerance, args[0], off)
+			}
+			return NONE, nil
+		},
+	}
+}
+
+// equals reports whether actual == expected holds in Glace: an int and
+// a float compare by value, and everything else compares with Equals.
+func equals(actual, expected Value) bool {
+	_, aInt := actual.(*IntValue)
+	_, eInt := expected.(*IntValue)
+	if !aInt || !eInt {
+		a, aNum := toFloat(actual)
+		e, eNum := toFloat(expected)
+		if aNum && eNum {
+			return a == e
+		}
+	}
+	return actual.Equals(expected)
+}
+
+func toFloat(v Value) (float64, bool) {
+	switch n := v.(type) {
+	case *IntValue:
+		return float64(n.Value), true
+	case *FloatValue:
+		return n.Value, true
+	}
+	return 0, false
+}
+
+func builtinAssertContains() *BuiltinFn {
+	return &BuiltinFn{
+		Name:      "assert_contains",
+		Signature: "assert_contains(container, item, msg?)",
+		Doc:       "Raise an error unless a string has a substring, an array or range an element, or a map a key.",
+		Fn: func(args []Value) (Value, error) {
+			if len(args) < 2 || len(args) > 3 {
+				return nil, fmt.Errorf("assert_contains() takes 2 or 3 arguments, got %d", len(args))
+			}
+			found := false
+			switch c := args[0].(type) {
+			case *StringValue:
+				sub, ok := args[1].(*StringValue)
+				if !ok {
+					return nil, fmt.Errorf("assert_contains() can only look for a string in a string, got '%s'", args[1].Type())
+				}
+				found = strings.Contains(c.Value, sub.Value)
+			case *ArrayValue:
+				for _, e := range c.Elements {
+					if e.Equals(args[1]) {
+						found = true
+						break
+					}
+				}
+			case *MapValue:
+				key, ok := args[1].(*StringValue)
+				if !ok {
+					return nil, fmt.Errorf("assert_contains() map keys are strings, got '%s'", args[1].Type())
+				}
+				_, found = c.Pairs[key.Value]
+			case *RangeValue:
+				if n, ok := args[1].(*IntValue); ok {
+					for i := int64(0); i < c.Len(); i++ {
+						if c.At(i) == n.Value {
+							found = true
+							break
+						}
+					}
+				}
+			default:
+				return nil, fmt.Errorf("assert_contains() needs a string, array, map or range, got '%s'", args[0].Type())
+			}
+			if !found {
+				return nil, assertFailure("assert_contains", args, 2, "%s does not contain %s", Inspect(args[0]), Inspect(args[1]))
+			}
+			return NONE, nil
+		},
+	}
+}
+
+func builtinAssertRaises() *BuiltinFn {
+	return &BuiltinFn{
+		Name:      "assert_raises",
+		Signature: "assert_raises(fn, pattern?)",
+		Doc:       "Call fn, a function of no arguments, and raise an error unless it fails with a message matching the regexp pattern. Returns the message.",
+		Fn: func(args []Value) (Value, error) {
+			if len(args) < 1 || len(args) > 2 {
+				return nil, fmt.Errorf("assert_raises() takes 1 or 2 arguments, got %d", len(args))
+			}
+			// A call with the wrong number of arguments would fail and
+			// pass as raised, so only functions of no arguments are taken.
+			// Builtins do not declare their arity and must be wrapped.
+			switch f := args[0].(type) {
+			case *FnValue:
+				if len(f.Params) != 0 {
+					return nil, fmt.Errorf("assert_raises() needs a function of no arguments, got one taking %d", len(f.Params))
+				}
+			case *BuiltinFn:
+				return nil, fmt.Errorf("assert_raises() cannot check the builtin %s directly; wrap the call, as in fn() => %s(...)", f.Name, f.Name)
+			default:
+				return nil, fmt.Errorf("assert_raises() argument must be a function, got '%s'", args[0].Type())
+			}
+			var pattern *regexp.Regexp
+			if len(args) == 2 {
+				s, ok := args[1].(*StringValue)
+				if !ok {
+					return nil, fmt.Errorf("assert_raises() pattern must be a string, got '%s'", args[1].Type())
+				}
+				var err error
+				if pattern, err = regexp.Compile(s.Value); err != nil {
+					return nil, fmt.Errorf("assert_raises() pattern: %s", err)
+				}
+			}
+			_, err := callFunction(args[0], nil, lexer.Position{})
+			if err == nil {
+				return nil, fmt.Errorf("assert_raises failed: the function returned without an error")
+			}
+			msg := err.Error()
+			if re, ok := err.(*RuntimeError); ok {
+				msg = re.Message
+			}
+			if pattern != nil && !pattern.MatchString(msg) {
+				return nil, fmt.Errorf("assert_raises failed: error %q does not match %q", msg, pattern)
+			}
+			return NewString(msg), nil
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Structural diff
+// ---------------------------------------------------------------------------
+
+// Diff describes how actual differs from expected. Inside arrays and
+// maps it names the first differing index or key, as a path such as
+// [2]["name"], and ends with both values in full.
+func Diff(expected, actual Value) string {
+	path, what := firstDifference(expected, actual, "")
+	var b strings.Builder
+	if path != "" {
+		fmt.Fprintf(&b, "at %s: ", path)
+	}
+	b.WriteString(what)
+	if isContainer(expected) || isContainer(actual) {
+		fmt.Fprintf(&b, "\n  expected: %s\n  actual:   %s", Inspect(expected), Inspect(actual))
+	}
+	return b.String()
+}
+
+func isContainer(v Value) bool {
+	switch v.(type) {
+	case *ArrayValue, *MapValue:
+		return true
+	}
+	return false
+}
+
+// firstDifference walks arrays in index order and maps in key order and
+// returns the path of the first difference and what it is.
+func firstDifference(expected, actual Value, path string) (string, string) {
+	switch e := expected.(type) {
+	case *ArrayValue:
+		a, ok := actual.(*ArrayValue)
+		if !ok {
+			break
+		}
+		for i := 0; i < len(e.Elements) || i < len(a.Elements); i++ {
+			at := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(a.Elements):
+				return at, fmt.Sprintf("missing element, expected %s (expected length %d, got %d)",
+					Inspect(e.Elements[i]), len(e.Elements), len(a.Elements))
+			case i >= len(e.Elements):
+				return at, fmt.Sprintf("unexpected element %s (expected length %d, got %d)",
+					Inspect(a.Elements[i]), len(e.Elements), len(a.Elements))
+			case !e.Elements[i].Equals(a.Elements[i]):
+				return firstDifference(e.Elements[i], a.Elements[i], at)
+			}
+		}
+	case *MapValue:
+		a, ok := actual.(*MapValue)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(e.Pairs)+len(a.Pairs))
+		for k := range e.Pairs {
+			keys = append(keys, k)
+		}
+		for k := range a.Pairs {
+			if _, ok := e.Pairs[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			at := fmt.Sprintf("%s[%q]", path, k)
+			ev, inExpected := e.Pairs[k]
+			av, inActual := a.Pairs[k]
+			switch {
+			case !inActual:
+				return at, fmt.Sprintf("missing key, expected %s", Inspect(ev))
+			case !inExpected:
+				return at, fmt.Sprintf("unexpected key with %s", Inspect(av))
+			case !ev.Equals(av):
+				return firstDifference(ev, av, at)
+			}
+		}
+	}
+	if expected.Type() != actual.Type() {
+		return path, fmt.Sprintf("expected %s %s, got %s %s", expected.Type(), Inspect(expected), actual.Type(), Inspect(actual))
+	}
+	return path, fmt.Sprintf("expected %s, got %s", Inspect(expected), Inspect(actual))
+}
@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/parser"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		expected, actual string
+		want             string
+	}{
+		{`3`, `4`, `expected 3, got 4`},
+		{`1.0`, `1`, `expected float 1, got int 1`},
+		{`[1, 2, 3]`, `[1, 9, 3]`, "at [1]: expected 2, got 9\n  expected: [1, 2, 3]\n  actual:   [1, 9, 3]"},
+		{`[1, 2]`, `[1, 2, 3]`, "at [2]: unexpected element 3 (expected length 2, got 3)"},
+		{`[1, 2, 3]`, `[1]`, "at [1]: missing element, expected 2 (expected length 3, got 1)"},
+		{`{"a": 1, "b": [1, {"c": "x"}]}`, `{"a": 1, "b": [1, {"c": "y"}]}`, `at ["b"][1]["c"]: expected "x", got "y"`},
+		{`{"a": 1, "c": 3}`, `{"b": 2, "c": 3}`, `at ["a"]: missing key, expected 1`},
+		{`{"c": 3}`, `{"b": 2, "c": 3}`, `at ["b"]: unexpected key with 2`},
+		{`[1]`, `{"a": 1}`, `expected array [1], got map {"a": 1}`},
+	}
+	for _, tt := range tests {
+		env := NewEnvironment()
+		expected := evalSource(t, tt.expected, env)
+		actual := evalSource(t, tt.actual, env)
+		if got := Diff(expected, actual); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("Diff(%s, %s):\n got %q\nwant %q", tt.expected, tt.actual, got, tt.want)
+		}
+	}
+}
+
+func evalSource(t *testing.T, src string, env *Environment) Value {
+	t.Helper()
+	program, errs := parser.ParseSource(src, "t.glace")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors in %s: %v", src, errs)
+	}
+	v, err := Eval(program, env)
+	if err != nil {
+		t.Fatalf("%s: %s", src, err)
+	}
+	return v
+}
+
+func TestAssertions(t *testing.T) {
+	tests := []struct {
+		src  string
+		fail string // "" when the assertion passes
+	}{
+		{`assert_eq([1, 2], [1, 2])`, ""},
+		{`assert_eq([1, 2], [1, 3], "pair")`, "pair: at [1]: expected 3, got 2"},
+		{`assert_eq(1, 1.0)`, ""},
+		{`assert_eq(0.5 * 2, 1)`, ""},
+		{`assert_eq(1, 1.5)`, "assert_eq failed: expected float 1.5, got int 1"},
+		{`assert_eq([1], [1.0])`, "at [0]: expected float 1, got int 1"},
+		{`assert_ne(2, 2.0)`, "assert_ne failed: expected a value other than 2"},
+		{`assert_ne(1, 2)`, ""},
+		{`assert_ne("a", "a")`, `assert_ne failed: expected a value other than "a"`},
+		{`assert_approx(0.1 + 0.2, 0.3)`, ""},
+		{`assert_approx(10, 10.5, 1)`, ""},
+		{`assert_approx(3.5, 3, 0.1)`, "assert_approx failed: expected 3 ± 0.1, got 3.5 (off by 0.5)"},
+		{`assert_approx("1", 1)`, "actual must be a number"},
+		{`assert_contains("haystack", "st")`, ""},
+		{`assert_contains([1, [2]], [2])`, ""},
+		{`assert_contains({"k": 1}, "k")`, ""},
+		{`assert_contains(0..10 step 2, 4)`, ""},
+		{`assert_contains(0..10 step 2, 5)`, "assert_contains failed: 0..10 step 2 does not contain 5"},
+		{`assert_contains([1, 2], 3)`, "assert_contains failed: [1, 2] does not contain 3"},
+		{`assert_raises(fn() => 1 / 0)`, ""},
+		{`assert_eq(assert_raises(fn() => assert(false, "boom"), "^bo+m$"), "boom")`, ""},
+		{`assert_raises(fn() => 1)`, "assert_raises failed: the function returned without an error"},
+		{`assert_raises(fn(x) => x * 2)`, "assert_raises() needs a function of no arguments, got one taking 1"},
+		{`assert_raises(len)`, "assert_raises() cannot check the builtin len directly; wrap the call, as in fn() => len(...)"},
+		{`assert_raises(fn() => len(1, 2))`, ""},
+		{`assert_raises(fn() => assert(false, "boom"), "bang")`, `assert_raises failed: error "boom" does not match "bang"`},
+	}
+	for _, tt := range tests {
+		program, errs := parser.ParseSource(tt.src, "t.glace")
+		if len(errs) > 0 {
+			t.Fatalf("parse errors in %s: %v", tt.src, errs)
+		}
+		env := NewEnvironment()
+		RegisterBuiltins(env)
+		_, err := Eval(program, env)
+		switch {
+		case tt.fail == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", tt.src, err)
+		case tt.fail != "" && err == nil:
+			t.Errorf("%s: expected an error containing %q", tt.src, tt.fail)
+		case tt.fail != "" && !strings.Contains(err.Error(), tt.fail):
+			t.Errorf("%s: error %q does not contain %q", tt.src, err, tt.fail)
+		}
+	}
+}
@@ -3,12 +3,17 @@ package evaluator
 import (
     "fmt"
     "sort"
+    "strings"
+
+    "github.com/glace-lang/glace/lexer"
 )
 
 // builtinFilter returns a new array containing only elements for which fn(elem) is truthy.
 func builtinFilter() *BuiltinFn {
     return &BuiltinFn{
-        Name: "filter",
+        Name:      "filter",
+        Signature: "filter(arr, fn)",
+        Doc:       "Return the elements of arr for which fn returns true.",
         Fn: func(args []Value) (Value, error) {
             if len(args) != 2 {
                 return nil, fmt.Errorf("filter expects 2 arguments (array, fn), got %d", len(args))
@@ -25,7 +30,7 @@ func builtinFilter() *BuiltinFn {
             }
             result := make([]Value, 0)
             for _, elem := range arr.Elements {
-                res, err := callFunction(fn, []Value{elem}, "filter")
+                res, err := callFunction(fn, []Value{elem}, lexer.Position{})
                 if err != nil {
                     return nil, err
                 }
@@ -40,7 +45,9 @@ func builtinFilter() *BuiltinFn {
 
 func builtinMap() *BuiltinFn {
     return &BuiltinFn{
-        Name: "map",
+        Name:      "map",
+        Signature: "map(arr, fn)",
+        Doc:       "Return the results of calling fn on each element of arr.",
         Fn: func(args []Value) (Value, error) {
             if len(args) != 2 {
                 return nil, fmt.Errorf("map expects 2 arguments (array, fn), got %d", len(args))
@@ -57,7 +64,7 @@ func builtinMap() *BuiltinFn {
             }
             result := make([]Value, len(arr.Elements))
             for i, elem := range arr.Elements {
-                res, err := callFunction(fn, []Value{elem}, "map")
+                res, err := callFunction(fn, []Value{elem}, lexer.Position{})
                 if err != nil {
                     return nil, err
                 }
@@ -70,7 +77,9 @@ func builtinMap() *BuiltinFn {
 
 func builtinReduce() *BuiltinFn {
     return &BuiltinFn{
-        Name: "reduce",
+        Name:      "reduce",
+        Signature: "reduce(arr, initial, fn)",
+        Doc:       "Fold arr into one value, starting from initial and calling fn(acc, x).",
         Fn: func(args []Value) (Value, error) {
             if len(args) != 3 {
                 return nil, fmt.Errorf("reduce expects 3 arguments (array, initial, fn), got %d", len(args))
@@ -88,7 +97,7 @@ func builtinReduce() *BuiltinFn {
             }
             var err error
             for _, elem := range arr.Elements {
-                acc, err = callFunction(fn, []Value{acc, elem}, "reduce")
+                acc, err = callFunction(fn, []Value{acc, elem}, lexer.Position{})
                 if err != nil {
                     return nil, err
                 }
@@ -100,7 +109,9 @@ func builtinReduce() *BuiltinFn {
 
 func builtinSort() *BuiltinFn {
     return &BuiltinFn{
-        Name: "sort",
+        Name:      "sort",
+        Signature: "sort(arr, fn?)",
+        Doc:       "Return a sorted copy of arr. fn(a, b), if given, returns a negative number when a sorts first.",
         Fn: func(args []Value) (Value, error) {
             if len(args) < 1 || len(args) > 2 {
                 return nil, fmt.Errorf("sort expects 1 or 2 arguments (array [, fn]), got %d", len(args))
@@ -125,7 +136,7 @@ func builtinSort() *BuiltinFn {
                     if sortErr != nil {
                         return false
                     }
-                    res, err := callFunction(fn, []Value{copied[i], copied[j]}, "sort")
+                    res, err := callFunction(fn, []Value{copied[i], copied[j]}, lexer.Position{})
                     if err != nil {
                         sortErr = err
                         return false
@@ -180,7 +191,9 @@ func builtinSort() *BuiltinFn {
 
 func builtinKeys() *BuiltinFn {
     return &BuiltinFn{
-        Name: "keys",
+        Name:      "keys",
+        Signature: "keys(m)",
+        Doc:       "Return the keys of map m, sorted.",
         Fn: func(args []Value) (Value, error) {
             if len(args) != 1 {
                 return nil, fmt.Errorf("keys expects 1 argument (map), got %d", len(args))
@@ -203,7 +216,9 @@ func builtinKeys() *BuiltinFn {
 
 func builtinValues() *BuiltinFn {
     return &BuiltinFn{
-        Name: "values",
+        Name:      "values",
+        Signature: "values(m)",
+        Doc:       "Return the values of map m, in key order.",
         Fn: func(args []Value) (Value, error) {
             if len(args) != 1 {
                 return nil, fmt.Errorf("values expects 1 argument (map), got %d", len(args))
@@ -228,7 +243,9 @@ func builtinValues() *BuiltinFn {
 
 func builtinHas() *BuiltinFn {
     return &BuiltinFn{
-        Name: "has",
+        Name:      "has",
+        Signature: "has(m, key)",
+        Doc:       "Report whether map m contains key.",
         Fn: func(args []Value) (Value, error) {
             if len(args) != 2 {
                 return nil, fmt.Errorf("has expects 2 arguments (map, key), got %d", len(args))
@@ -249,7 +266,9 @@ func builtinHas() *BuiltinFn {
 
 func builtinReverse() *BuiltinFn {
     return &BuiltinFn{
-        Name: "reverse",
+        Name:      "reverse",
+        Signature: "reverse(arr)",
+        Doc:       "Return a reversed copy of arr.",
         Fn: func(args []Value) (Value, error) {
             if len(args) != 1 {
                 return nil, fmt.Errorf("reverse expects 1 argument (array), got %d", len(args))
@@ -268,14 +287,81 @@ func builtinReverse() *BuiltinFn {
     }
 }
 
+// builtinCapture calls fn with no arguments and returns what it printed.
+func builtinCapture(rt *Runtime) *BuiltinFn {
+    return &BuiltinFn{
+        Name:      "capture",
+        Signature: "capture(fn)",
+        Doc:       "Call fn() and return what it printed.",
+        Fn: func(args []Value) (Value, error) {
+            if len(args) != 1 {
+                return nil, fmt.Errorf("capture expects 1 argument (fn), got %d", len(args))
+            }
+            output, err := captureCall(rt, "capture", args[0])
+            if err != nil {
+                return nil, err
+            }
+            return NewString(output), nil
+        },
+    }
+}
+
+// builtinExpectOutput calls fn with no arguments and fails unless it
+// printed exactly expected. A final newline is optional on either side.
+func builtinExpectOutput(rt *Runtime) *BuiltinFn {
+    return &BuiltinFn{
+        Name:      "expect_output",
+        Signature: "expect_output(text, fn)",
+        Doc:       "Assert that fn() prints exactly text.",
+        Fn: func(args []Value) (Value, error) {
+            if len(args) != 2 {
+                return nil, fmt.Errorf("expect_output expects 2 arguments (expected, fn), got %d", len(args))
+            }
+            expected, ok := args[0].(*StringValue)
+            if !ok {
+                return nil, fmt.Errorf("expect_output: first argument must be a string, got %s", args[0].Type())
+            }
+            output, err := captureCall(rt, "expect_output", args[1])
+            if err != nil {
+                return nil, err
+            }
+            if strings.TrimSuffix(output, "\n") != strings.TrimSuffix(expected.Value, "\n") {
+                return nil, fmt.Errorf("expect_output: expected %q, got %q", expected.Value, output)
+            }
+            return NONE, nil
+        },
+    }
+}
+
+func captureCall(rt *Runtime, name string, fn Value) (string, error) {
+    if _, ok1 := fn.(*FnValue); !ok1 {
+        if _, ok2 := fn.(*BuiltinFn); !ok2 {
+            return "", fmt.Errorf("%s: argument must be a function, got %s", name, fn.Type())
+        }
+    }
+    return rt.Capture(func() error {
+        _, err := callFunction(fn, nil, lexer.Position{})
+        return err
+    })
+}
+
 // RegisterHOBuiltins registers all higher-order built-in functions into the environment.
 func RegisterHOBuiltins(env *Environment) {
-    env.Define("filter", builtinFilter(), false)
-    env.Define("map", builtinMap(), false)
-    env.Define("reduce", builtinReduce(), false)
-    env.Define("sort", builtinSort(), false)
-    env.Define("keys", builtinKeys(), false)
-    env.Define("values", builtinValues(), false)
-    env.Define("has", builtinHas(), false)
-    env.Define("reverse", builtinReverse(), false)
+    rt := env.Runtime()
+    builtins := []*BuiltinFn{
+        builtinFilter(),
+        builtinMap(),
+        builtinReduce(),
+        builtinSort(),
+        builtinKeys(),
+        builtinValues(),
+        builtinHas(),
+        builtinReverse(),
+        builtinCapture(rt),
+        builtinExpectOutput(rt),
+    }
+    for _, b := range builtins {
+        b.runtime = env.Runtime()
+        env.Define(b.Name, b, false)
+    }
 }
\ No newline at end of file
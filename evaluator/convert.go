@@ -0,0 +1,437 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/glace-lang/glace/diag"
+)
+
+// ---------------------------------------------------------------------------
+// Go -> Glace
+// ---------------------------------------------------------------------------
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// ToValue converts a Go value to a Glace value:
+//
+//	nil, nil pointers      none
+//	bool                   bool
+//	signed/unsigned ints   int
+//	float32, float64       float
+//	string                 string
+//	slices, arrays         array
+//	maps with string keys  map
+//	structs                map of exported fields (see below)
+//	funcs                  builtin, as with RegisterFunc
+//	Value                  itself
+//
+// Struct fields are named by a `glace:"name"` tag, falling back to the Go
+// field name; a tag of "-" skips the field. Pointers are followed.
+func ToValue(x interface{}) (Value, error) {
+	if x == nil {
+		return NONE, nil
+	}
+	if v, ok := x.(Value); ok {
+		return v, nil
+	}
+	return toValue(reflect.ValueOf(x))
+}
+
+func toValue(rv reflect.Value) (Value, error) {
+	if !rv.IsValid() {
+		return NONE, nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Func:
+		if rv.IsNil() {
+			return NONE, nil
+		}
+	}
+	if v, ok := rv.Interface().(Value); ok {
+		return v, nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return NewBool(rv.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInt(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return nil, fmt.Errorf("%d overflows int", u)
+		}
+		return NewInt(int64(u)), nil
+	case reflect.Float32, reflect.Float64:
+		return NewFloat(rv.Float()), nil
+	case reflect.String:
+		return NewString(rv.String()), nil
+	case reflect.Ptr, reflect.Interface:
+		return toValue(rv.Elem())
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return NewArray([]Value{}), nil
+		}
+		elements := make([]Value, rv.Len())
+		for i := range elements {
+			e, err := toValue(rv.Index(i))
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %s", i, err)
+			}
+			elements[i] = e
+		}
+		return NewArray(elements), nil
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("cannot convert %s: map keys must be strings", rv.Type())
+		}
+		pairs := make(map[string]Value, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			val, err := toValue(iter.Value())
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %s", iter.Key().String(), err)
+			}
+			pairs[iter.Key().String()] = val
+		}
+		return NewMap(pairs), nil
+	case reflect.Struct:
+		pairs := make(map[string]Value)
+		for _, f := range structFields(rv.Type()) {
+			val, err := toValue(rv.Field(f.index))
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %s", f.name, err)
+			}
+			pairs[f.name] = val
+		}
+		return NewMap(pairs), nil
+	case reflect.Func:
+		return wrapGoFunc("<go>", rv)
+	}
+	return nil, fmt.Errorf("cannot convert Go type %s to a Glace value", rv.Type())
+}
+
+type structField struct {
+	name  string
+	index int
+}
+
+// structFields lists the exported fields of t under their Glace names.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("glace"); ok {
+			tag = strings.Split(tag, ",")[0]
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			}
+		}
+		fields = append(fields, structField{name: name, index: i})
+	}
+	return fields
+}
+
+// ---------------------------------------------------------------------------
+// Glace -> Go
+// ---------------------------------------------------------------------------
+
+// FromValue stores v in the Go value target points to, converting as the
+// reverse of ToValue. Ints convert to floats but not the other way round,
+// and an int that does not fit the target type is an error. Into an
+// interface{} target, values become int64, float64, string, bool, nil,
+// []interface{} and map[string]interface{}; functions stay Glace values.
+// A Glace function converted to a Go func type calls back into Glace. A
+// nil v converts as none.
+func FromValue(v Value, target interface{}) error {
+	rv := reflect.ValueOf(target)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("FromValue target must be a non-nil pointer, got %T", target)
+	}
+	return fromValue(v, rv.Elem())
+}
+
+func fromValue(v Value, rv reflect.Value) error {
+	if v == nil {
+		v = NONE
+	}
+	t := rv.Type()
+	// An interface{} takes the natural Go value; other interfaces, such
+	// as Value, take v itself if it implements them.
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		x := natural(v)
+		if x == nil {
+			rv.Set(reflect.Zero(t))
+		} else {
+			rv.Set(reflect.ValueOf(x))
+		}
+		return nil
+	}
+	if reflect.TypeOf(v).AssignableTo(t) {
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := v.(*BoolValue); ok {
+			rv.SetBool(b.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := v.(*IntValue); ok {
+			if rv.OverflowInt(i.Value) {
+				return fmt.Errorf("%d overflows %s", i.Value, t)
+			}
+			rv.SetInt(i.Value)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := v.(*IntValue); ok {
+			if i.Value < 0 || rv.OverflowUint(uint64(i.Value)) {
+				return fmt.Errorf("%d overflows %s", i.Value, t)
+			}
+			rv.SetUint(uint64(i.Value))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := v.(type) {
+		case *FloatValue:
+			rv.SetFloat(n.Value)
+			return nil
+		case *IntValue:
+			rv.SetFloat(float64(n.Value))
+			return nil
+		}
+	case reflect.String:
+		if s, ok := v.(*StringValue); ok {
+			rv.SetString(s.Value)
+			return nil
+		}
+	case reflect.Ptr:
+		if _, ok := v.(*NoneValue); ok {
+			rv.Set(reflect.Zero(t))
+			return nil
+		}
+		p := reflect.New(t.Elem())
+		if err := fromValue(v, p.Elem()); err != nil {
+			return err
+		}
+		rv.Set(p)
+		return nil
+	case reflect.Slice:
+		if a, ok := v.(*ArrayValue); ok {
+			s := reflect.MakeSlice(t, len(a.Elements), len(a.Elements))
+			for i, e := range a.Elements {
+				if err := fromValue(e, s.Index(i)); err != nil {
+					return fmt.Errorf("index %d: %s", i, err)
+				}
+			}
+			rv.Set(s)
+			return nil
+		}
+	case reflect.Array:
+		if a, ok := v.(*ArrayValue); ok {
+			if len(a.Elements) != t.Len() {
+				return fmt.Errorf("cannot convert array of length %d to %s", len(a.Elements), t)
+			}
+			for i, e := range a.Elements {
+				if err := fromValue(e, rv.Index(i)); err != nil {
+					return fmt.Errorf("index %d: %s", i, err)
+				}
+			}
+			return nil
+		}
+	case reflect.Map:
+		if m, ok := v.(*MapValue); ok && t.Key().Kind() == reflect.String {
+			out := reflect.MakeMapWithSize(t, len(m.Pairs))
+			for k, val := range m.Pairs {
+				elem := reflect.New(t.Elem()).Elem()
+				if err := fromValue(val, elem); err != nil {
+					return fmt.Errorf("key %q: %s", k, err)
+				}
+				out.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), elem)
+			}
+			rv.Set(out)
+			return nil
+		}
+	case reflect.Struct:
+		if m, ok := v.(*MapValue); ok {
+			for _, f := range structFields(t) {
+				val, exists := m.Pairs[f.name]
+				if !exists {
+					continue
+				}
+				if err := fromValue(val, rv.Field(f.index)); err != nil {
+					return fmt.Errorf("field %s: %s", f.name, err)
+				}
+			}
+			return nil
+		}
+	case reflect.Func:
+		switch v.(type) {
+		case *FnValue, *BuiltinFn:
+			rv.Set(makeGoFunc(v, t))
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot convert '%s' to Go type %s", v.Type(), t)
+}
+
+// natural converts v to the Go value it most naturally corresponds to.
+func natural(v Value) interface{} {
+	switch x := v.(type) {
+	case *IntValue:
+		return x.Value
+	case *FloatValue:
+		return x.Value
+	case *StringValue:
+		return x.Value
+	case *BoolValue:
+		return x.Value
+	case *NoneValue:
+		return nil
+	case *ArrayValue:
+		out := make([]interface{}, len(x.Elements))
+		for i, e := range x.Elements {
+			out[i] = natural(e)
+		}
+		return out
+	case *MapValue:
+		out := make(map[string]interface{}, len(x.Pairs))
+		for k, e := range x.Pairs {
+			out[k] = natural(e)
+		}
+		return out
+	}
+	return v
+}
+
+// makeGoFunc returns a Go function of type t that calls the Glace
+// function fn. If fn fails and t has no error result, the call panics
+// with the error.
+func makeGoFunc(fn Value, t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		results := make([]reflect.Value, t.NumOut())
+		for i := range results {
+			results[i] = reflect.New(t.Out(i)).Elem()
+		}
+		fail := func(err error) []reflect.Value {
+			if n := t.NumOut(); n > 0 && t.Out(n-1) == errorType {
+				results[n-1] = reflect.ValueOf(&err).Elem()
+				return results
+			}
+			panic(err)
+		}
+
+		args := make([]Value, len(in))
+		for i, a := range in {
+			v, err := toValue(a)
+			if err != nil {
+				return fail(err)
+			}
+			args[i] = v
+		}
+		out, err := CallFunction(fn, args...)
+		if err != nil {
+			return fail(err)
+		}
+		if t.NumOut() > 0 && t.Out(0) != errorType {
+			if err := fromValue(out, results[0]); err != nil {
+				return fail(err)
+			}
+		}
+		return results
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Registering Go functions
+// ---------------------------------------------------------------------------
+
+// RegisterFunc defines name in env as a builtin that calls the Go
+// function fn. Arguments are converted with FromValue to fn's parameter
+// types, and variadic functions accept any number of trailing arguments.
+// fn may return nothing, a value, an error, or a value and an error; a
+// non-nil error becomes a Glace runtime error at the call site.
+func RegisterFunc(env *Environment, name string, fn interface{}) error {
+	b, err := wrapGoFunc(name, reflect.ValueOf(fn))
+	if err != nil {
+		return err
+	}
+	b.runtime = env.Runtime()
+	return env.Define(name, b, false)
+}
+
+func wrapGoFunc(name string, fv reflect.Value) (*BuiltinFn, error) {
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		return nil, fmt.Errorf("%s: expected a function, got %s", name, fv.Kind())
+	}
+	t := fv.Type()
+	returnsErr := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	switch {
+	case t.NumOut() > 2,
+		t.NumOut() == 2 && !returnsErr:
+		return nil, fmt.Errorf("%s: function must return at most a value and an error", name)
+	}
+
+	return &BuiltinFn{
+		Name: name,
+		Fn: func(args []Value) (Value, error) {
+			fixed := t.NumIn()
+			if t.IsVariadic() {
+				fixed--
+			}
+			if len(args) < fixed || (!t.IsVariadic() && len(args) > fixed) {
+				want := fmt.Sprintf("%d", fixed)
+				if t.IsVariadic() {
+					want = fmt.Sprintf("at least %d", fixed)
+				}
+				return nil, &RuntimeError{
+					Message: fmt.Sprintf("%s() takes %s arguments, got %d", name, want, len(args)),
+					Code:    diag.CodeArity,
+				}
+			}
+
+			in := make([]reflect.Value, len(args))
+			for i, a := range args {
+				var pt reflect.Type
+				if i < fixed {
+					pt = t.In(i)
+				} else {
+					pt = t.In(fixed).Elem() // variadic slice element
+				}
+				arg := reflect.New(pt).Elem()
+				if err := fromValue(a, arg); err != nil {
+					return nil, &RuntimeError{
+						Message: fmt.Sprintf("%s() argument %d: %s", name, i+1, err),
+						Code:    diag.CodeTypeMismatch,
+					}
+				}
+				in[i] = arg
+			}
+
+			out := fv.Call(in)
+			if returnsErr {
+				if err := out[len(out)-1].Interface(); err != nil {
+					return nil, err.(error)
+				}
+				out = out[:len(out)-1]
+			}
+			if len(out) == 0 {
+				return NONE, nil
+			}
+			return toValue(out[0])
+		},
+	}, nil
+}
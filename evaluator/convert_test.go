@@ -0,0 +1,124 @@
+package evaluator
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/parser"
+)
+
+type point struct {
+	X      int     `glace:"x"`
+	Y      float64 `glace:"y"`
+	Label  string
+	Secret string `glace:"-"`
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	in := map[string][]point{"path": {{X: 1, Y: 2.5, Label: "a", Secret: "s"}}}
+	v, err := ToValue(in)
+	if err != nil {
+		t.Fatalf("ToValue: %s", err)
+	}
+	if got := Inspect(v); got != `{"path": [{"Label": "a", "x": 1, "y": 2.5}]}` {
+		t.Errorf("ToValue = %s", got)
+	}
+
+	var out map[string][]point
+	if err := FromValue(v, &out); err != nil {
+		t.Fatalf("FromValue: %s", err)
+	}
+	in["path"][0].Secret = ""
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip: got %+v, expected %+v", out, in)
+	}
+
+	var small int8
+	if err := FromValue(NewInt(300), &small); err == nil {
+		t.Errorf("expected overflow error")
+	}
+}
+
+func TestFromValueIntoInterface(t *testing.T) {
+	v := NewMap(map[string]Value{
+		"n":    NewInt(1),
+		"list": NewArray([]Value{NewFloat(2.5), NewString("s"), TRUE, NONE}),
+	})
+	var x interface{}
+	if err := FromValue(v, &x); err != nil {
+		t.Fatalf("FromValue: %s", err)
+	}
+	want := map[string]interface{}{"n": int64(1), "list": []interface{}{2.5, "s", true, nil}}
+	if !reflect.DeepEqual(x, want) {
+		t.Errorf("interface{}: got %#v, expected %#v", x, want)
+	}
+
+	var list []interface{}
+	if err := FromValue(NewArray([]Value{NewInt(1), NewString("a")}), &list); err != nil ||
+		!reflect.DeepEqual(list, []interface{}{int64(1), "a"}) {
+		t.Errorf("[]interface{}: got %#v (err %v)", list, err)
+	}
+
+	var m map[string]interface{}
+	if err := FromValue(NewMap(map[string]Value{"k": NewFloat(1.5)}), &m); err != nil ||
+		!reflect.DeepEqual(m, map[string]interface{}{"k": 1.5}) {
+		t.Errorf("map[string]interface{}: got %#v (err %v)", m, err)
+	}
+
+	var val Value
+	if err := FromValue(NewInt(7), &val); err != nil || !val.Equals(NewInt(7)) {
+		t.Errorf("Value: got %v (err %v)", val, err)
+	}
+
+	x = "stale"
+	if err := FromValue(nil, &x); err != nil || x != nil {
+		t.Errorf("nil: got %#v (err %v)", x, err)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+	RegisterHOBuiltins(env)
+	RegisterFunc(env, "join", func(sep string, parts ...string) string {
+		return strings.Join(parts, sep)
+	})
+	RegisterFunc(env, "check", func(n int) (int, error) {
+		if n < 0 {
+			return 0, errors.New("negative")
+		}
+		return n * 2, nil
+	})
+
+	input := `let a = join("-", "x", "y", "z")
+let b = check(21)
+fn add(x, y) => x + y
+check(-1)`
+	program, errs := parser.Parse(lexer.New(input, "t.glace").Tokenize())
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	_, err := Eval(program, env)
+	re, ok := err.(*RuntimeError)
+	if !ok || re.Message != "negative" || re.Pos.Line != 4 {
+		t.Errorf("expected runtime error 'negative' on line 4, got %v", err)
+	}
+
+	a, _ := env.Get("a")
+	b, _ := env.Get("b")
+	if a.String() != "x-y-z" || b.String() != "42" {
+		t.Errorf("a = %s, b = %s", a, b)
+	}
+
+	var add func(int, int) (int, error)
+	fn, _ := env.Get("add")
+	if err := FromValue(fn, &add); err != nil {
+		t.Fatalf("FromValue(fn): %s", err)
+	}
+	if n, err := add(2, 3); n != 5 || err != nil {
+		t.Errorf("add(2, 3) = %d, %v", n, err)
+	}
+}
@@ -1,6 +1,9 @@
 package evaluator
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 // Environment represents a scope in the Glace runtime.
 // Each environment has a reference to its parent (enclosing) scope,
@@ -8,6 +11,7 @@ import "fmt"
 type Environment struct {
 	store   map[string]binding
 	parent  *Environment
+	runtime *Runtime
 }
 
 // binding holds a value and its mutability flag.
@@ -19,7 +23,8 @@ type binding struct {
 // NewEnvironment creates a new root environment with no parent.
 func NewEnvironment() *Environment {
 	return &Environment{
-		store: make(map[string]binding),
+		store:   make(map[string]binding),
+		runtime: &Runtime{},
 	}
 }
 
@@ -27,11 +32,17 @@ func NewEnvironment() *Environment {
 // Used for function scopes, block scopes, and loop scopes.
 func NewEnclosedEnvironment(parent *Environment) *Environment {
 	return &Environment{
-		store:  make(map[string]binding),
-		parent: parent,
+		store:   make(map[string]binding),
+		parent:  parent,
+		runtime: parent.runtime,
 	}
 }
 
+// Runtime returns the per-program state shared by this scope chain.
+func (e *Environment) Runtime() *Runtime {
+	return e.runtime
+}
+
 // Define creates a new binding in the CURRENT scope.
 // Returns an error if the variable is already defined in this scope.
 func (e *Environment) Define(name string, val Value, mutable bool) error {
@@ -73,6 +84,13 @@ func (e *Environment) Set(name string, val Value) error {
 	return fmt.Errorf("undefined variable '%s'", name)
 }
 
+// Bind defines name in the CURRENT scope, replacing any existing binding
+// regardless of its mutability. It is meant for hosts that update values
+// between runs; scripts go through Define and Set.
+func (e *Environment) Bind(name string, val Value, mutable bool) {
+	e.store[name] = binding{value: val, mutable: mutable}
+}
+
 // IsMutable returns whether a variable is declared as mutable.
 func (e *Environment) IsMutable(name string) bool {
 	b, ok := e.store[name]
@@ -84,3 +102,26 @@ func (e *Environment) IsMutable(name string) bool {
 	}
 	return false
 }
+
+// Binding describes one name defined directly in a scope.
+type Binding struct {
+	Name    string
+	Value   Value
+	Mutable bool
+}
+
+// Bindings returns the names defined directly in this scope, sorted by name.
+// Enclosing scopes are not included; walk Parent for those.
+func (e *Environment) Bindings() []Binding {
+	out := make([]Binding, 0, len(e.store))
+	for name, b := range e.store {
+		out = append(out, Binding{Name: name, Value: b.value, Mutable: b.mutable})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Parent returns the enclosing scope, or nil for the root environment.
+func (e *Environment) Parent() *Environment {
+	return e.parent
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
+	"github.com/glace-lang/glace/lexer"
 )
 
 // ---------------------------------------------------------------------------
@@ -30,16 +32,55 @@ func (c *ContinueSignal) Error() string { return "continue signal" }
 // RuntimeError represents a user-facing runtime error.
 type RuntimeError struct {
 	Message string
-	Pos     string
+	Pos     lexer.Position // zero when the position is unknown
+	Code    string         // diagnostic code; empty means diag.CodeRuntime
+	Trace   []Frame        // call stack when the error was raised, outermost first
 }
 
 func (e *RuntimeError) Error() string {
-	if e.Pos != "" {
+	if e.Pos.Line > 0 {
 		return fmt.Sprintf("runtime error at %s: %s", e.Pos, e.Message)
 	}
 	return fmt.Sprintf("runtime error: %s", e.Message)
 }
 
+// Diagnostic converts the error to a diag.Diagnostic. Each active Glace
+// call becomes a note, innermost first.
+func (e *RuntimeError) Diagnostic() *diag.Diagnostic {
+	d := &diag.Diagnostic{
+		Start:    e.Pos,
+		End:      e.Pos,
+		Code:     e.Code,
+		Severity: diag.Error,
+		Message:  e.Message,
+	}
+	if d.Code == "" {
+		d.Code = diag.CodeRuntime
+	}
+	for i := len(e.Trace) - 1; i >= 0; i-- {
+		f := e.Trace[i]
+		if f.Builtin && i == len(e.Trace)-1 {
+			continue
+		}
+		if f.CallPos.Line == 0 {
+			d.Notes = append(d.Notes, fmt.Sprintf("in %s, called from a builtin", f.Function))
+		} else {
+			d.Notes = append(d.Notes, fmt.Sprintf("in %s, called at %s", f.Function, f.CallPos))
+		}
+	}
+	return d
+}
+
+// ErrorDiagnostic converts any error returned by Eval to a diagnostic.
+// Errors other than RuntimeError, such as a break outside a loop, are
+// reported at pos.
+func ErrorDiagnostic(err error, pos lexer.Position) *diag.Diagnostic {
+	if re, ok := err.(*RuntimeError); ok {
+		return re.Diagnostic()
+	}
+	return &diag.Diagnostic{Start: pos, End: pos, Code: diag.CodeRuntime, Severity: diag.Error, Message: err.Error()}
+}
+
 // ---------------------------------------------------------------------------
 // Evaluator
 // ---------------------------------------------------------------------------
@@ -65,6 +106,8 @@ func Eval(node ast.Node, env *Environment) (Value, error) {
 		return evalAssignStatement(n, env)
 	case *ast.IndexAssignStatement:
 		return evalIndexAssignStatement(n, env)
+	case *ast.FieldAssignStatement:
+		return evalFieldAssignStatement(n, env)
 	case *ast.ExpressionStatement:
 		return Eval(n.Expression, env)
 	case *ast.BlockStatement:
@@ -83,9 +126,11 @@ func Eval(node ast.Node, env *Environment) (Value, error) {
 		return evalFnDeclaration(n, env)
 	case *ast.MatchStatement:
 		return evalMatchStatement(n, env)
-	case *ast.TestBlock:
-		// Test blocks are skipped in normal execution
+	case *ast.TestBlock, *ast.BenchBlock, *ast.DescribeBlock, *ast.HookBlock:
+		// Test and bench blocks are skipped in normal execution
 		return NONE, nil
+	case *ast.BadStatement:
+		return nil, &RuntimeError{Message: "cannot run a statement with syntax errors", Pos: n.Pos}
 
 	// --- Expressions ---
 	case *ast.IntegerLiteral:
@@ -98,6 +143,8 @@ func Eval(node ast.Node, env *Environment) (Value, error) {
 		return NewBool(n.Value), nil
 	case *ast.NoneLiteral:
 		return NONE, nil
+	case *ast.BadExpression:
+		return nil, &RuntimeError{Message: "cannot evaluate an expression with syntax errors", Pos: n.Pos}
 	case *ast.Identifier:
 		return evalIdentifier(n, env)
 	case *ast.BinaryExpression:
@@ -138,7 +185,11 @@ func Eval(node ast.Node, env *Environment) (Value, error) {
 
 func evalProgram(program *ast.Program, env *Environment) (Value, error) {
 	var result Value = NONE
+	rt := env.Runtime()
 	for _, stmt := range program.Statements {
+		if err := rt.onStatement(stmt, env); err != nil {
+			return nil, err
+		}
 		val, err := Eval(stmt, env)
 		if err != nil {
 			return nil, err
@@ -153,8 +204,13 @@ func evalLetStatement(stmt *ast.LetStatement, env *Environment) (Value, error) {
 	if err != nil {
 		return nil, err
 	}
+	if fn, ok := val.(*FnValue); ok && stmt.Doc != "" {
+		if _, literal := stmt.Value.(*ast.FnLiteral); literal {
+			fn.Doc = stmt.Doc
+		}
+	}
 	if err := env.Define(stmt.Name, val, false); err != nil {
-		return nil, &RuntimeError{Message: err.Error(), Pos: stmt.Pos.String()}
+		return nil, &RuntimeError{Message: err.Error(), Pos: stmt.Pos}
 	}
 	return NONE, nil
 }
@@ -165,7 +221,7 @@ func evalMutStatement(stmt *ast.MutStatement, env *Environment) (Value, error) {
 		return nil, err
 	}
 	if err := env.Define(stmt.Name, val, true); err != nil {
-		return nil, &RuntimeError{Message: err.Error(), Pos: stmt.Pos.String()}
+		return nil, &RuntimeError{Message: err.Error(), Pos: stmt.Pos}
 	}
 	return NONE, nil
 }
@@ -176,7 +232,11 @@ func evalAssignStatement(stmt *ast.AssignStatement, env *Environment) (Value, er
 		return nil, err
 	}
 	if err := env.Set(stmt.Name, val); err != nil {
-		return nil, &RuntimeError{Message: err.Error(), Pos: stmt.Pos.String()}
+		code := diag.CodeImmutable
+		if _, ok := env.Get(stmt.Name); !ok {
+			code = diag.CodeUndefinedVariable
+		}
+		return nil, &RuntimeError{Message: err.Error(), Pos: stmt.Pos, Code: code}
 	}
 	return NONE, nil
 }
@@ -199,21 +259,45 @@ func evalIndexAssignStatement(stmt *ast.IndexAssignStatement, env *Environment)
 	case *ArrayValue:
 		idx, ok := index.(*IntValue)
 		if !ok {
-			return nil, &RuntimeError{Message: "array index must be an integer", Pos: stmt.Pos.String()}
+			return nil, &RuntimeError{Message: "array index must be an integer", Pos: stmt.Pos, Code: diag.CodeTypeMismatch}
 		}
 		i := int(idx.Value)
 		if i < 0 || i >= len(target.Elements) {
-			return nil, &RuntimeError{Message: fmt.Sprintf("index %d out of bounds (len %d)", i, len(target.Elements)), Pos: stmt.Pos.String()}
+			return nil, &RuntimeError{Message: fmt.Sprintf("index %d out of bounds (len %d)", i, len(target.Elements)), Pos: stmt.Pos, Code: diag.CodeIndexOutOfRange}
 		}
 		target.Elements[i] = val
 	case *MapValue:
 		key, ok := index.(*StringValue)
 		if !ok {
-			return nil, &RuntimeError{Message: "map key must be a string", Pos: stmt.Pos.String()}
+			return nil, &RuntimeError{Message: "map key must be a string", Pos: stmt.Pos, Code: diag.CodeTypeMismatch}
 		}
 		target.Pairs[key.Value] = val
 	default:
-		return nil, &RuntimeError{Message: fmt.Sprintf("cannot index into '%s'", left.Type()), Pos: stmt.Pos.String()}
+		return nil, &RuntimeError{Message: fmt.Sprintf("cannot index into '%s'", left.Type()), Pos: stmt.Pos, Code: diag.CodeTypeMismatch}
+	}
+
+	return NONE, nil
+}
+
+func evalFieldAssignStatement(stmt *ast.FieldAssignStatement, env *Environment) (Value, error) {
+	left, err := Eval(stmt.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	val, err := Eval(stmt.Value, env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch target := left.(type) {
+	case HostObject:
+		if err := target.SetField(stmt.Field, val); err != nil {
+			return nil, wrapBuiltinError(err, stmt.Pos)
+		}
+	case *MapValue:
+		target.Pairs[stmt.Field] = val
+	default:
+		return nil, &RuntimeError{Message: fmt.Sprintf("cannot assign field '%s' on type '%s'", stmt.Field, left.Type()), Pos: stmt.Pos, Code: diag.CodeTypeMismatch}
 	}
 
 	return NONE, nil
@@ -221,7 +305,11 @@ func evalIndexAssignStatement(stmt *ast.IndexAssignStatement, env *Environment)
 
 func evalBlockStatement(block *ast.BlockStatement, env *Environment) (Value, error) {
 	var result Value = NONE
+	rt := env.Runtime()
 	for _, stmt := range block.Statements {
+		if err := rt.onStatement(stmt, env); err != nil {
+			return nil, err
+		}
 		val, err := Eval(stmt, env)
 		if err != nil {
 			return nil, err // propagate return/break/continue signals
@@ -249,20 +337,24 @@ func evalIfStatement(stmt *ast.IfStatement, env *Environment) (Value, error) {
 		return nil, err
 	}
 
+	rt := env.Runtime()
 	if IsTruthy(cond) {
+		rt.onBranch(stmt, 0)
 		return Eval(stmt.Consequence, env)
 	}
 
-	for _, elif := range stmt.ElifClauses {
+	for i, elif := range stmt.ElifClauses {
 		cond, err := Eval(elif.Condition, env)
 		if err != nil {
 			return nil, err
 		}
 		if IsTruthy(cond) {
+			rt.onBranch(stmt, i+1)
 			return Eval(elif.Consequence, env)
 		}
 	}
 
+	rt.onBranch(stmt, len(stmt.ElifClauses)+1)
 	if stmt.Alternative != nil {
 		return Eval(stmt.Alternative, env)
 	}
@@ -342,7 +434,8 @@ func evalForInLoop(stmt *ast.LoopStatement, iterable Value, env *Environment) (V
 	default:
 		return nil, &RuntimeError{
 			Message: fmt.Sprintf("cannot iterate over '%s'", iterable.Type()),
-			Pos:     stmt.Pos.String(),
+			Pos:     stmt.Pos,
+			Code:    diag.CodeTypeMismatch,
 		}
 	}
 	return NONE, nil
@@ -351,6 +444,7 @@ func evalForInLoop(stmt *ast.LoopStatement, iterable Value, env *Environment) (V
 func evalFnDeclaration(stmt *ast.FnDeclaration, env *Environment) (Value, error) {
 	fn := &FnValue{
 		Name:   stmt.Name,
+		Doc:    stmt.Doc,
 		Params: stmt.Params,
 		Body:   stmt.Body,
 		Env:    env,
@@ -365,7 +459,8 @@ func evalMatchStatement(stmt *ast.MatchStatement, env *Environment) (Value, erro
 		return nil, err
 	}
 
-	for _, arm := range stmt.Arms {
+	rt := env.Runtime()
+	for i, arm := range stmt.Arms {
 		matched, err := matchPattern(arm.Pattern, subject, env)
 		if err != nil {
 			return nil, err
@@ -385,9 +480,11 @@ func evalMatchStatement(stmt *ast.MatchStatement, env *Environment) (Value, erro
 			}
 		}
 
+		rt.onBranch(stmt, i)
 		return Eval(arm.Body, env)
 	}
 
+	rt.onBranch(stmt, len(stmt.Arms))
 	return NONE, nil
 }
 
@@ -451,7 +548,8 @@ func evalIdentifier(node *ast.Identifier, env *Environment) (Value, error) {
 	if !ok {
 		return nil, &RuntimeError{
 			Message: fmt.Sprintf("undefined variable '%s'", node.Name),
-			Pos:     node.Pos.String(),
+			Pos:     node.Pos,
+			Code:    diag.CodeUndefinedVariable,
 		}
 	}
 	return val, nil
@@ -485,11 +583,11 @@ func evalBinaryExpression(node *ast.BinaryExpression, env *Environment) (Value,
 	// Integer arithmetic
 	if lv, ok := left.(*IntValue); ok {
 		if rv, ok := right.(*IntValue); ok {
-			return evalIntBinaryOp(node.Operator, lv.Value, rv.Value, node.Pos.String())
+			return evalIntBinaryOp(node.Operator, lv.Value, rv.Value, node.Pos)
 		}
 		// Int + Float → promote to Float
 		if rv, ok := right.(*FloatValue); ok {
-			return evalFloatBinaryOp(node.Operator, float64(lv.Value), rv.Value, node.Pos.String())
+			return evalFloatBinaryOp(node.Operator, float64(lv.Value), rv.Value, node.Pos)
 		}
 	}
 
@@ -502,9 +600,9 @@ func evalBinaryExpression(node *ast.BinaryExpression, env *Environment) (Value,
 		case *IntValue:
 			rv_f = float64(rv.Value)
 		default:
-			return nil, &RuntimeError{Message: fmt.Sprintf("cannot apply '%s' to float and %s", node.Operator, right.Type()), Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: fmt.Sprintf("cannot apply '%s' to float and %s", node.Operator, right.Type()), Pos: node.Pos, Code: diag.CodeTypeMismatch}
 		}
-		return evalFloatBinaryOp(node.Operator, lv.Value, rv_f, node.Pos.String())
+		return evalFloatBinaryOp(node.Operator, lv.Value, rv_f, node.Pos)
 	}
 
 	// String concatenation
@@ -526,11 +624,12 @@ func evalBinaryExpression(node *ast.BinaryExpression, env *Environment) (Value,
 
 	return nil, &RuntimeError{
 		Message: fmt.Sprintf("unsupported operator '%s' for types '%s' and '%s'", node.Operator, left.Type(), right.Type()),
-		Pos:     node.Pos.String(),
+		Pos:     node.Pos,
+		Code:    diag.CodeTypeMismatch,
 	}
 }
 
-func evalIntBinaryOp(op string, left, right int64, pos string) (Value, error) {
+func evalIntBinaryOp(op string, left, right int64, pos lexer.Position) (Value, error) {
 	switch op {
 	case "+":
 		return NewInt(left + right), nil
@@ -540,12 +639,12 @@ func evalIntBinaryOp(op string, left, right int64, pos string) (Value, error) {
 		return NewInt(left * right), nil
 	case "/":
 		if right == 0 {
-			return nil, &RuntimeError{Message: "division by zero", Pos: pos}
+			return nil, &RuntimeError{Message: "division by zero", Pos: pos, Code: diag.CodeDivisionByZero}
 		}
 		return NewInt(left / right), nil
 	case "%":
 		if right == 0 {
-			return nil, &RuntimeError{Message: "modulo by zero", Pos: pos}
+			return nil, &RuntimeError{Message: "modulo by zero", Pos: pos, Code: diag.CodeDivisionByZero}
 		}
 		return NewInt(left % right), nil
 	case "<":
@@ -565,7 +664,7 @@ func evalIntBinaryOp(op string, left, right int64, pos string) (Value, error) {
 	}
 }
 
-func evalFloatBinaryOp(op string, left, right float64, pos string) (Value, error) {
+func evalFloatBinaryOp(op string, left, right float64, pos lexer.Position) (Value, error) {
 	switch op {
 	case "+":
 		return NewFloat(left + right), nil
@@ -575,7 +674,7 @@ func evalFloatBinaryOp(op string, left, right float64, pos string) (Value, error
 		return NewFloat(left * right), nil
 	case "/":
 		if right == 0 {
-			return nil, &RuntimeError{Message: "division by zero", Pos: pos}
+			return nil, &RuntimeError{Message: "division by zero", Pos: pos, Code: diag.CodeDivisionByZero}
 		}
 		return NewFloat(left / right), nil
 	case "<":
@@ -609,40 +708,88 @@ func evalUnaryExpression(node *ast.UnaryExpression, env *Environment) (Value, er
 		case *FloatValue:
 			return NewFloat(-v.Value), nil
 		default:
-			return nil, &RuntimeError{Message: fmt.Sprintf("cannot negate '%s'", operand.Type()), Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: fmt.Sprintf("cannot negate '%s'", operand.Type()), Pos: node.Pos, Code: diag.CodeTypeMismatch}
 		}
 	case "!":
 		return NewBool(!IsTruthy(operand)), nil
 	default:
-		return nil, &RuntimeError{Message: fmt.Sprintf("unknown unary operator '%s'", node.Operator), Pos: node.Pos.String()}
+		return nil, &RuntimeError{Message: fmt.Sprintf("unknown unary operator '%s'", node.Operator), Pos: node.Pos}
 	}
 }
 
 func evalCallExpression(node *ast.CallExpression, env *Environment) (Value, error) {
-	fn, err := Eval(node.Function, env)
-	if err != nil {
-		return nil, err
+	return evalCall(node.Function, node.Arguments, nil, node.Pos, env)
+}
+
+// evalCall evaluates a callee and its arguments and calls it, with the
+// leading values (the piped value, for |>) before the written arguments.
+// A callee of the form obj.name on a HostObject is a method call.
+func evalCall(callee ast.Expression, argExprs []ast.Expression, leading []Value, pos lexer.Position, env *Environment) (Value, error) {
+	var fn Value
+	var host HostObject
+	var method string
+	if dot, ok := callee.(*ast.DotExpression); ok {
+		recv, err := Eval(dot.Left, env)
+		if err != nil {
+			return nil, err
+		}
+		if obj, ok := recv.(HostObject); ok {
+			host, method = obj, dot.Field
+		} else if fn, err = dotField(recv, dot); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if fn, err = Eval(callee, env); err != nil {
+			return nil, err
+		}
 	}
 
-	args := make([]Value, len(node.Arguments))
-	for i, arg := range node.Arguments {
+	args := make([]Value, 0, len(leading)+len(argExprs))
+	args = append(args, leading...)
+	for _, arg := range argExprs {
 		val, err := Eval(arg, env)
 		if err != nil {
 			return nil, err
 		}
-		args[i] = val
+		args = append(args, val)
 	}
 
-	return callFunction(fn, args, node.Pos.String())
+	if host != nil {
+		return callMethod(host, method, args, pos, env.Runtime())
+	}
+	return callFunction(fn, args, pos)
+}
+
+// callMethod calls a method of a host object, with a frame on the stack
+// like a builtin's.
+func callMethod(obj HostObject, name string, args []Value, pos lexer.Position, rt *Runtime) (Value, error) {
+	rt.pushFrame(obj.Type()+"."+name, pos, true)
+	result, err := obj.CallMethod(name, args)
+	err = rt.annotate(wrapBuiltinError(err, pos), pos)
+	rt.popFrame()
+	if err == nil && result == nil {
+		result = NONE
+	}
+	return result, err
+}
+
+// CallFunction invokes a Glace function or builtin with args from Go.
+// Errors raised inside it carry the Glace stack, but no call site.
+func CallFunction(fn Value, args ...Value) (Value, error) {
+	return callFunction(fn, args, lexer.Position{})
 }
 
-func callFunction(fn Value, args []Value, pos string) (Value, error) {
+// callFunction invokes fn with args. pos is the call site, or the zero
+// Position when the call comes from a builtin such as map or filter.
+func callFunction(fn Value, args []Value, pos lexer.Position) (Value, error) {
 	switch f := fn.(type) {
 	case *FnValue:
 		if len(args) != len(f.Params) {
 			return nil, &RuntimeError{
 				Message: fmt.Sprintf("%s() takes %d arguments, got %d", f.Name, len(f.Params), len(args)),
 				Pos:     pos,
+				Code:    diag.CodeArity,
 			}
 		}
 		fnEnv := NewEnclosedEnvironment(f.Env)
@@ -653,26 +800,58 @@ func callFunction(fn Value, args []Value, pos string) (Value, error) {
 		if !ok {
 			return nil, &RuntimeError{Message: "invalid function body", Pos: pos}
 		}
-		result, err := Eval(body, fnEnv)
-		if err != nil {
-			if rs, ok := err.(*ReturnSignal); ok {
-				if len(rs.Values) == 0 {
-					return NONE, nil
-				}
-				if len(rs.Values) == 1 {
-					return rs.Values[0], nil
-				}
-				// Multiple return values → return as array
-				return NewArray(rs.Values), nil
-			}
-			return nil, err
-		}
-		return result, nil
+		rt := f.Env.Runtime()
+		rt.pushFrame(fnName(f), pos, false)
+		rt.onCall(f, args, pos)
+		result, err := evalFunctionBody(body, fnEnv)
+		rt.onReturn(f, result, err)
+		err = rt.annotate(err, pos)
+		rt.popFrame()
+		return result, err
 	case *BuiltinFn:
-		return f.Fn(args)
+		rt := f.runtime
+		if rt == nil {
+			result, err := f.Fn(args)
+			return result, wrapBuiltinError(err, pos)
+		}
+		rt.pushFrame(f.Name, pos, true)
+		result, err := f.Fn(args)
+		err = rt.annotate(wrapBuiltinError(err, pos), pos)
+		rt.popFrame()
+		return result, err
 	default:
-		return nil, &RuntimeError{Message: fmt.Sprintf("'%s' is not callable", fn.Type()), Pos: pos}
+		return nil, &RuntimeError{Message: fmt.Sprintf("'%s' is not callable", fn.Type()), Pos: pos, Code: diag.CodeNotCallable}
+	}
+}
+
+// wrapBuiltinError turns a plain Go error from a builtin into a
+// RuntimeError at the call site. Control-flow signals and errors that are
+// already RuntimeErrors pass through unchanged.
+func wrapBuiltinError(err error, pos lexer.Position) error {
+	switch err.(type) {
+	case nil, *RuntimeError, *ReturnSignal, *BreakSignal, *ContinueSignal:
+		return err
 	}
+	return &RuntimeError{Message: err.Error(), Pos: pos}
+}
+
+// evalFunctionBody runs a function body and unwraps its return signal.
+func evalFunctionBody(body *ast.BlockStatement, env *Environment) (Value, error) {
+	result, err := Eval(body, env)
+	if err != nil {
+		if rs, ok := err.(*ReturnSignal); ok {
+			if len(rs.Values) == 0 {
+				return NONE, nil
+			}
+			if len(rs.Values) == 1 {
+				return rs.Values[0], nil
+			}
+			// Multiple return values → return as array
+			return NewArray(rs.Values), nil
+		}
+		return nil, err
+	}
+	return result, nil
 }
 
 func evalIndexExpression(node *ast.IndexExpression, env *Environment) (Value, error) {
@@ -689,17 +868,17 @@ func evalIndexExpression(node *ast.IndexExpression, env *Environment) (Value, er
 	case *ArrayValue:
 		idx, ok := index.(*IntValue)
 		if !ok {
-			return nil, &RuntimeError{Message: "array index must be an integer", Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: "array index must be an integer", Pos: node.Pos, Code: diag.CodeTypeMismatch}
 		}
 		i := int(idx.Value)
 		if i < 0 || i >= len(target.Elements) {
-			return nil, &RuntimeError{Message: fmt.Sprintf("index %d out of bounds (len %d)", i, len(target.Elements)), Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: fmt.Sprintf("index %d out of bounds (len %d)", i, len(target.Elements)), Pos: node.Pos, Code: diag.CodeIndexOutOfRange}
 		}
 		return target.Elements[i], nil
 	case *MapValue:
 		key, ok := index.(*StringValue)
 		if !ok {
-			return nil, &RuntimeError{Message: "map key must be a string", Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: "map key must be a string", Pos: node.Pos, Code: diag.CodeTypeMismatch}
 		}
 		val, exists := target.Pairs[key.Value]
 		if !exists {
@@ -709,25 +888,25 @@ func evalIndexExpression(node *ast.IndexExpression, env *Environment) (Value, er
 	case *RangeValue:
 		idx, ok := index.(*IntValue)
 		if !ok {
-			return nil, &RuntimeError{Message: "range index must be an integer", Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: "range index must be an integer", Pos: node.Pos, Code: diag.CodeTypeMismatch}
 		}
 		i := idx.Value
 		if i < 0 || i >= target.Len() {
-			return nil, &RuntimeError{Message: fmt.Sprintf("range index %d out of bounds", i), Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: fmt.Sprintf("range index %d out of bounds", i), Pos: node.Pos, Code: diag.CodeIndexOutOfRange}
 		}
 		return NewInt(target.At(i)), nil
 	case *StringValue:
 		idx, ok := index.(*IntValue)
 		if !ok {
-			return nil, &RuntimeError{Message: "string index must be an integer", Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: "string index must be an integer", Pos: node.Pos, Code: diag.CodeTypeMismatch}
 		}
 		i := int(idx.Value)
 		if i < 0 || i >= len(target.Value) {
-			return nil, &RuntimeError{Message: fmt.Sprintf("string index %d out of bounds", i), Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: fmt.Sprintf("string index %d out of bounds", i), Pos: node.Pos, Code: diag.CodeIndexOutOfRange}
 		}
 		return NewString(string(target.Value[i])), nil
 	default:
-		return nil, &RuntimeError{Message: fmt.Sprintf("cannot index into '%s'", left.Type()), Pos: node.Pos.String()}
+		return nil, &RuntimeError{Message: fmt.Sprintf("cannot index into '%s'", left.Type()), Pos: node.Pos, Code: diag.CodeTypeMismatch}
 	}
 }
 
@@ -736,6 +915,14 @@ func evalDotExpression(node *ast.DotExpression, env *Environment) (Value, error)
 	if err != nil {
 		return nil, err
 	}
+	return dotField(left, node)
+}
+
+// dotField reads node.Field from the already evaluated left operand.
+func dotField(left Value, node *ast.DotExpression) (Value, error) {
+	if obj, ok := left.(HostObject); ok {
+		return hostField(obj, node.Field, node.Pos)
+	}
 
 	if m, ok := left.(*MapValue); ok {
 		val, exists := m.Pairs[node.Field]
@@ -747,7 +934,8 @@ func evalDotExpression(node *ast.DotExpression, env *Environment) (Value, error)
 
 	return nil, &RuntimeError{
 		Message: fmt.Sprintf("cannot access field '%s' on type '%s'", node.Field, left.Type()),
-		Pos:     node.Pos.String(),
+		Pos:     node.Pos,
+		Code:    diag.CodeTypeMismatch,
 	}
 }
 
@@ -761,6 +949,10 @@ func evalSafeAccessExpression(node *ast.SafeAccessExpression, env *Environment)
 		return NONE, nil
 	}
 
+	if obj, ok := left.(HostObject); ok {
+		return hostField(obj, node.Field, node.Pos)
+	}
+
 	if m, ok := left.(*MapValue); ok {
 		val, exists := m.Pairs[node.Field]
 		if !exists {
@@ -771,8 +963,20 @@ func evalSafeAccessExpression(node *ast.SafeAccessExpression, env *Environment)
 
 	return nil, &RuntimeError{
 		Message: fmt.Sprintf("cannot safe-access field '%s' on type '%s'", node.Field, left.Type()),
-		Pos:     node.Pos.String(),
+		Pos:     node.Pos,
+		Code:    diag.CodeTypeMismatch,
+	}
+}
+
+func hostField(obj HostObject, name string, pos lexer.Position) (Value, error) {
+	val, err := obj.GetField(name)
+	if err != nil {
+		return nil, wrapBuiltinError(err, pos)
 	}
+	if val == nil {
+		return NONE, nil
+	}
+	return val, nil
 }
 
 func evalArrayLiteral(node *ast.ArrayLiteral, env *Environment) (Value, error) {
@@ -796,7 +1000,7 @@ func evalMapLiteral(node *ast.MapLiteral, env *Environment) (Value, error) {
 		}
 		key, ok := keyVal.(*StringValue)
 		if !ok {
-			return nil, &RuntimeError{Message: "map key must be a string", Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: "map key must be a string", Pos: node.Pos, Code: diag.CodeTypeMismatch}
 		}
 		val, err := Eval(node.Values[i], env)
 		if err != nil {
@@ -828,7 +1032,7 @@ func evalRangeExpression(node *ast.RangeExpression, env *Environment) (Value, er
 	start, ok1 := startVal.(*IntValue)
 	end, ok2 := endVal.(*IntValue)
 	if !ok1 || !ok2 {
-		return nil, &RuntimeError{Message: "range bounds must be integers", Pos: node.Pos.String()}
+		return nil, &RuntimeError{Message: "range bounds must be integers", Pos: node.Pos, Code: diag.CodeTypeMismatch}
 	}
 
 	step := int64(1)
@@ -839,7 +1043,7 @@ func evalRangeExpression(node *ast.RangeExpression, env *Environment) (Value, er
 		}
 		s, ok := stepVal.(*IntValue)
 		if !ok {
-			return nil, &RuntimeError{Message: "range step must be an integer", Pos: node.Pos.String()}
+			return nil, &RuntimeError{Message: "range step must be an integer", Pos: node.Pos, Code: diag.CodeTypeMismatch}
 		}
 		step = s.Value
 	}
@@ -853,24 +1057,8 @@ func evalPipelineExpression(node *ast.PipelineExpression, env *Environment) (Val
 		return nil, err
 	}
 
-	// Evaluate the right-hand call's function
-	fn, err := Eval(node.Right.Function, env)
-	if err != nil {
-		return nil, err
-	}
-
-	// Evaluate remaining args (the ones explicitly written)
-	args := make([]Value, 0, len(node.Right.Arguments)+1)
-	args = append(args, left) // pipe value is first arg
-	for _, arg := range node.Right.Arguments {
-		val, err := Eval(arg, env)
-		if err != nil {
-			return nil, err
-		}
-		args = append(args, val)
-	}
-
-	return callFunction(fn, args, node.Pos.String())
+	// The pipe value is the first argument, before the ones written.
+	return evalCall(node.Right.Function, node.Right.Arguments, []Value{left}, node.Pos, env)
 }
 
 func evalCoalesceExpression(node *ast.CoalesceExpression, env *Environment) (Value, error) {
@@ -895,44 +1083,3 @@ func evalStringInterpolation(node *ast.StringInterpolation, env *Environment) (V
 	}
 	return NewString(result), nil
 }
-
-// ---------------------------------------------------------------------------
-// Test Runner (used by `glace test` command)
-// ---------------------------------------------------------------------------
-
-// TestResult holds the outcome of a single test block.
-type TestResult struct {
-	Name   string
-	Passed bool
-	Error  string
-}
-
-// RunTests evaluates a program and runs only its test blocks.
-func RunTests(program *ast.Program, env *Environment) []TestResult {
-	results := make([]TestResult, 0)
-
-	// First evaluate all non-test statements (to define functions etc.)
-	for _, stmt := range program.Statements {
-		if _, isTest := stmt.(*ast.TestBlock); isTest {
-			continue
-		}
-		Eval(stmt, env)
-	}
-
-	// Then run test blocks
-	for _, stmt := range program.Statements {
-		tb, ok := stmt.(*ast.TestBlock)
-		if !ok {
-			continue
-		}
-		testEnv := NewEnclosedEnvironment(env)
-		_, err := Eval(tb.Body, testEnv)
-		result := TestResult{Name: tb.Description, Passed: err == nil}
-		if err != nil {
-			result.Error = err.Error()
-		}
-		results = append(results, result)
-	}
-
-	return results
-}
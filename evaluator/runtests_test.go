@@ -0,0 +1,236 @@
+package evaluator
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/parser"
+)
+
+func TestRunTestsCapturesOutput(t *testing.T) {
+	input := `fn greet(name) => print("hi " + name)
+test "quiet" {
+    expect_output("hi a", fn() => greet("a"))
+    assert(capture(fn() => greet("b")) == "hi b
+")
+}
+test "noisy" {
+    print("debug")
+    expect_output("x", fn() => greet("c"))
+}`
+	program, errs := parser.ParseSource(input, "t.glace")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+	RegisterHOBuiltins(env)
+	var out strings.Builder
+	env.Runtime().SetStdout(&out)
+
+	results := RunTests(program, env, TestOptions{})
+	if len(results) != 2 || !results[0].Passed || results[1].Passed {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	if results[0].Output != "" || results[1].Output != "debug\n" {
+		t.Errorf("wrong captured output: %q, %q", results[0].Output, results[1].Output)
+	}
+	if !strings.Contains(results[1].Error, `expected "x", got "hi c\n"`) {
+		t.Errorf("wrong error: %s", results[1].Error)
+	}
+	if out.Len() != 0 {
+		t.Errorf("test output leaked: %q", out.String())
+	}
+}
+
+func TestRunTestsFilterAndLocations(t *testing.T) {
+	input := `test "math adds" { assert(1 + 1 == 2) }
+test "math fails" {
+    let x = 1
+    assert(x == 2, "x is not 2")
+}
+test "strings" { assert(false) }`
+	program, errs := parser.ParseSource(input, "t.glace")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+
+	results := RunTests(program, env, TestOptions{Run: regexp.MustCompile("^math")})
+	if len(results) != 2 {
+		t.Fatalf("expected the 2 math tests, got %+v", results)
+	}
+	if results[1].Pos.Line != 2 || results[1].FailurePos.Line != 4 {
+		t.Errorf("wrong positions: test at %s, failure at %s", results[1].Pos, results[1].FailurePos)
+	}
+	if results[0].FailurePos != (lexer.Position{}) {
+		t.Errorf("unexpected passing result: %+v", results[0])
+	}
+}
+
+func TestRunTestsHooksAndGroups(t *testing.T) {
+	input := `mut log = []
+fn note(s) => push(log, s)
+before_all { note("all") }
+before_each { note("each") }
+after_each { note("/each") }
+test "first" { note("first") }
+describe "group" {
+    mut count = 0
+    before_each {
+        note("group each")
+        count = count + 1
+    }
+    after_each { note("/group each") }
+    describe "inner" {
+        test "counts" { assert(count == 1) }
+    }
+    test "fails in after_each" { note("second") }
+    after_each { assert(count < 2, "count is too big") }
+}
+describe "setup fails" {
+    let x = missing
+    test "skipped" { assert(true) }
+}
+describe "before_all fails" {
+    before_all { assert(false, "no database") }
+    test "skipped" { assert(true) }
+    after_all { note("cleanup") }
+}
+after_all { note("/all") }`
+	program, errs := parser.ParseSource(input, "t.glace")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+
+	results := RunTests(program, env, TestOptions{})
+	expected := []struct {
+		name   string
+		passed bool
+		error  string
+	}{
+		{"first", true, ""},
+		{"group > inner > counts", true, ""},
+		{"group > fails in after_each", false, "after_each: runtime error at t.glace:18:24: count is too big"},
+		{"setup fails > (setup)", false, "undefined variable 'missing'"},
+		{"before_all fails > (before_all)", false, "no database"},
+	}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %+v", len(expected), results)
+	}
+	for i, e := range expected {
+		r := results[i]
+		if r.Name != e.name || r.Passed != e.passed || !strings.Contains(r.Error, e.error) {
+			t.Errorf("results[%d] = %q passed=%t error=%q, want %q passed=%t error containing %q",
+				i, r.Name, r.Passed, r.Error, e.name, e.passed, e.error)
+		}
+	}
+
+	log, _ := env.Get("log")
+	want := `["all", "each", "first", "/each", ` +
+		`"each", "group each", "/group each", "/each", ` +
+		`"each", "group each", "second", "/group each", "/each", ` +
+		`"cleanup", "/all"]`
+	if got := Inspect(log); got != want {
+		t.Errorf("hooks ran in the wrong order:\n got %s\nwant %s", got, want)
+	}
+
+	env = NewEnvironment()
+	RegisterBuiltins(env)
+	results = RunTests(program, env, TestOptions{Run: regexp.MustCompile("inner")})
+	if len(results) != 1 || results[0].Name != "group > inner > counts" {
+		t.Errorf("-run should select the nested test only, got %+v", results)
+	}
+}
+
+func TestBeforeEachDefinesFixtures(t *testing.T) {
+	input := `describe "fixtures" {
+    before_each {
+        let fresh = 5
+        mut items = []
+    }
+    after_each { assert_eq(len(items), 1) }
+    test "sees them" {
+        assert_eq(fresh, 5)
+        push(items, fresh)
+    }
+    test "gets new ones" {
+        push(items, 1)
+        assert_eq(items, [1])
+    }
+}`
+	program, errs := parser.ParseSource(input, "t.glace")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+	for _, r := range RunTests(program, env, TestOptions{}) {
+		if !r.Passed {
+			t.Errorf("%s: %s", r.Name, r.Error)
+		}
+	}
+}
+
+func TestBeforeAllSharesSetup(t *testing.T) {
+	input := `describe "shared" {
+    before_all {
+        let shared = [1, 2]
+        mut runs = 0
+    }
+    test "first" {
+        assert_eq(shared, [1, 2])
+        runs = runs + 1
+    }
+    test "second" { assert_eq(runs, 1) }
+}
+describe "other" {
+    test "cannot see it" { shared }
+}`
+	program, errs := parser.ParseSource(input, "t.glace")
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+	results := RunTests(program, env, TestOptions{})
+	if len(results) != 3 {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	for _, r := range results[:2] {
+		if !r.Passed {
+			t.Errorf("%s: %s", r.Name, r.Error)
+		}
+	}
+	if r := results[2]; r.Passed || !strings.Contains(r.Error, "undefined variable 'shared'") {
+		t.Errorf("before_all setup leaked into another group: %+v", r)
+	}
+}
+
+func TestRunTestsReportsSetupFailure(t *testing.T) {
+	program, _ := parser.ParseSource("let a = 1\nlet b = a / 0\ntest \"t\" { assert(true) }", "t.glace")
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+	results := RunTests(program, env, TestOptions{})
+	if len(results) != 1 || results[0].Name != "(setup)" || results[0].Passed || results[0].FailurePos.Line != 2 {
+		t.Errorf("expected a failed setup result at line 2, got %+v", results)
+	}
+}
+
+func TestCaptureRestoresStdoutAfterPanic(t *testing.T) {
+	rt := NewEnvironment().Runtime()
+	var out strings.Builder
+	rt.SetStdout(&out)
+	func() {
+		defer func() { recover() }()
+		rt.Capture(func() error { panic("boom") })
+	}()
+	if rt.Stdout() != &out {
+		t.Errorf("stdout was left redirected after a panic")
+	}
+}
@@ -0,0 +1,200 @@
+package evaluator
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/lexer"
+)
+
+// Runtime holds the state shared by every scope of one running program.
+// The root environment owns it and enclosed environments inherit it, so
+// two interpreters never see each other's hooks or streams.
+type Runtime struct {
+	hooks  []*Hooks
+	frames []Frame
+
+	// Streams used by print, input and tracing. Nil means the process's
+	// standard streams, looked up at each use so that redirecting
+	// os.Stdout works.
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	snapshots *Snapshots // set by glace test for snapshot()
+}
+
+// Frame is one active call on the Glace call stack.
+type Frame struct {
+	Function string         // callee name, "<fn>" for anonymous functions
+	CallPos  lexer.Position // call site; zero when called from inside a builtin
+	Builtin  bool
+}
+
+// Hooks lets tools observe evaluation. Any field may be nil.
+type Hooks struct {
+	// Statement runs before each statement is evaluated. A non-nil error
+	// aborts evaluation and is returned from Eval.
+	Statement func(stmt ast.Statement, env *Environment) error
+
+	// Call runs when a Glace function is entered, after its arguments
+	// have been bound.
+	Call func(fn *FnValue, args []Value, pos lexer.Position)
+
+	// Return runs when a Glace function exits, normally or with an error.
+	Return func(fn *FnValue, result Value, err error)
+
+	// Branch runs when an if or match statement picks an arm. For if, arm
+	// 0 is the consequence, 1..n the elif clauses and n+1 the else (taken
+	// even when there is no else block). For match, arm i is Arms[i] and
+	// len(Arms) means no arm matched.
+	Branch func(stmt ast.Statement, arm int)
+}
+
+// AddHooks attaches h to the runtime. Hooks run in the order they were added.
+func (r *Runtime) AddHooks(h *Hooks) {
+	r.hooks = append(r.hooks, h)
+}
+
+func (r *Runtime) onStatement(stmt ast.Statement, env *Environment) error {
+	for _, h := range r.hooks {
+		if h.Statement != nil {
+			if err := h.Statement(stmt, env); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *Runtime) onCall(fn *FnValue, args []Value, pos lexer.Position) {
+	for _, h := range r.hooks {
+		if h.Call != nil {
+			h.Call(fn, args, pos)
+		}
+	}
+}
+
+func (r *Runtime) onReturn(fn *FnValue, result Value, err error) {
+	for _, h := range r.hooks {
+		if h.Return != nil {
+			h.Return(fn, result, err)
+		}
+	}
+}
+
+func (r *Runtime) onBranch(stmt ast.Statement, arm int) {
+	for _, h := range r.hooks {
+		if h.Branch != nil {
+			h.Branch(stmt, arm)
+		}
+	}
+}
+
+// Stack returns a copy of the active call stack, outermost call first.
+func (r *Runtime) Stack() []Frame {
+	return append([]Frame(nil), r.frames...)
+}
+
+func (r *Runtime) pushFrame(name string, pos lexer.Position, builtin bool) {
+	r.frames = append(r.frames, Frame{Function: name, CallPos: pos, Builtin: builtin})
+}
+
+func (r *Runtime) popFrame() {
+	r.frames = r.frames[:len(r.frames)-1]
+}
+
+// annotate fills in what a RuntimeError is missing as it leaves a call:
+// the stack at the innermost frame it passes through, and the call site
+// as its position when it was raised without one (as builtins do).
+func (r *Runtime) annotate(err error, pos lexer.Position) error {
+	re, ok := err.(*RuntimeError)
+	if !ok {
+		return err
+	}
+	if re.Trace == nil {
+		re.Trace = r.Stack()
+	}
+	if re.Pos.Line == 0 {
+		re.Pos = pos
+	}
+	return re
+}
+
+// SetStdin makes input read from r.
+func (r *Runtime) SetStdin(in io.Reader) {
+	r.stdin = bufio.NewReader(in)
+}
+
+// SetStdout makes print write to w.
+func (r *Runtime) SetStdout(w io.Writer) {
+	r.stdout = w
+}
+
+// SetStderr sets the stream for diagnostics written while running.
+func (r *Runtime) SetStderr(w io.Writer) {
+	r.stderr = w
+}
+
+// Stdout returns the stream print writes to.
+func (r *Runtime) Stdout() io.Writer {
+	if r.stdout == nil {
+		return os.Stdout
+	}
+	return r.stdout
+}
+
+// Capture runs f with print redirected to a buffer and returns what was
+// printed. Captures nest: the previous stream is restored afterwards.
+func (r *Runtime) Capture(f func() error) (string, error) {
+	var buf bytes.Buffer
+	saved := r.stdout
+	r.stdout = &buf
+	defer func() { r.stdout = saved }()
+	err := f()
+	return buf.String(), err
+}
+
+// Stderr returns the stream for diagnostics written while running.
+func (r *Runtime) Stderr() io.Writer {
+	if r.stderr == nil {
+		return os.Stderr
+	}
+	return r.stderr
+}
+
+// readLine reads one line from the runtime's stdin, without its line
+// ending. The process's stdin is read a byte at a time so that nothing
+// past the line is consumed from under a REPL sharing it.
+func (r *Runtime) readLine() (string, error) {
+	if br, ok := r.stdin.(*bufio.Reader); ok {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimRight(line, "\r\n"), err
+	}
+
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				break
+			}
+			return string(line), err
+		}
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
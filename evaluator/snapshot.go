@@ -0,0 +1,293 @@
+package evaluator
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ---------------------------------------------------------------------------
+// Snapshots (snapshot() inside `glace test`)
+// ---------------------------------------------------------------------------
+
+// Snapshots holds the values recorded by snapshot() for one test file.
+// They are stored in __snapshots__/<file>.snap next to the file: each
+// entry is a line `snapshot "<name>"` followed by the value, indented by
+// four spaces, in the layout `glace fmt` gives literals. Multi-line
+// strings are stored as their lines so that diffs stay readable.
+type Snapshots struct {
+	Path   string // the .snap file
+	Update bool   // overwrite stored values that differ instead of failing
+
+	stored  map[string]string
+	seen    map[string]bool
+	changed bool
+
+	test  string // name of the running test; "" outside tests
+	count int    // unnamed snapshots taken so far in the running test
+}
+
+// SnapshotPath returns where the snapshots of testFile are stored.
+func SnapshotPath(testFile string) string {
+	dir, base := filepath.Split(testFile)
+	return filepath.Join(dir, "__snapshots__", base+".snap")
+}
+
+// LoadSnapshots reads the snapshots stored for testFile. A missing file
+// means there are none yet.
+func LoadSnapshots(testFile string, update bool) (*Snapshots, error) {
+	s := &Snapshots{Path: SnapshotPath(testFile), Update: update, stored: map[string]string{}, seen: map[string]bool{}}
+	f, err := os.Open(s.Path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var name string
+	var lines []string
+	flush := func() {
+		if name != "" {
+			for len(lines) > 0 && lines[len(lines)-1] == "" {
+				lines = lines[:len(lines)-1]
+			}
+			s.stored[name] = strings.Join(lines, "\n")
+		}
+		lines = nil
+	}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<30)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "snapshot "):
+			flush()
+			if name, err = strconv.Unquote(line[len("snapshot "):]); err != nil || name == "" {
+				return nil, fmt.Errorf("%s:%d: bad snapshot name", s.Path, n)
+			}
+		case strings.HasPrefix(line, "    "):
+			lines = append(lines, line[4:])
+		case strings.TrimSpace(line) == "":
+			lines = append(lines, "")
+		case strings.HasPrefix(line, "//") && name == "":
+		default:
+			return nil, fmt.Errorf("%s:%d: expected a snapshot or an indented value", s.Path, n)
+		}
+	}
+	flush()
+	return s, scanner.Err()
+}
+
+// begin starts collecting the snapshots of the named test.
+func (s *Snapshots) begin(test string) {
+	s.test, s.count = test, 0
+}
+
+// check compares v with the stored snapshot called name, or with the
+// running test's next unnamed snapshot when name is "". A snapshot that
+// is not stored yet, or that differs in update mode, is recorded.
+func (s *Snapshots) check(name string, v Value) error {
+	if s.test == "" {
+		return fmt.Errorf("snapshot() can only be used inside a test")
+	}
+	if name == "" {
+		s.count++
+		name = fmt.Sprintf("%s %d", s.test, s.count)
+	} else {
+		name = s.test + ": " + name
+	}
+	if s.seen[name] {
+		return fmt.Errorf("snapshot %q is taken twice in this test", name)
+	}
+	s.seen[name] = true
+
+	text := snapshotText(v)
+	stored, ok := s.stored[name]
+	if ok && stored == text {
+		return nil
+	}
+	if ok && !s.Update {
+		return fmt.Errorf("snapshot %q does not match (- stored, + actual; update with glace test --update-snapshots)\n%s",
+			name, lineDiff(stored, text))
+	}
+	s.stored[name] = text
+	s.changed = true
+	return nil
+}
+
+// Save writes the snapshots back if any were added or updated. With
+// prune, those no test took this run are dropped, which is only right
+// when every test in the file ran and passed.
+func (s *Snapshots) Save(prune bool) error {
+	if prune {
+		for name := range s.stored {
+			if !s.seen[name] {
+				delete(s.stored, name)
+				s.changed = true
+			}
+		}
+	}
+	if !s.changed {
+		return nil
+	}
+	if len(s.stored) == 0 {
+		err := os.Remove(s.Path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	names := make([]string, 0, len(s.stored))
+	for name := range s.stored {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("// Snapshots written by glace test. Update with glace test --update-snapshots.\n")
+	for _, name := range names {
+		fmt.Fprintf(&b, "\nsnapshot %s\n", strconv.Quote(name))
+		for _, line := range strings.Split(s.stored[name], "\n") {
+			if line == "" {
+				b.WriteString("\n")
+			} else {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, []byte(b.String()), 0o644)
+}
+
+// SetSnapshots gives snapshot() the store of the file under test.
+func (r *Runtime) SetSnapshots(s *Snapshots) {
+	r.snapshots = s
+}
+
+func builtinSnapshot(rt *Runtime) *BuiltinFn {
+	return &BuiltinFn{
+		Name:      "snapshot",
+		Signature: "snapshot(name?, value)",
+		Doc:       "Compare value with the snapshot stored for this test, recording it on first use.",
+		Fn: func(args []Value) (Value, error) {
+			var name string
+			switch len(args) {
+			case 1:
+			case 2:
+				s, ok := args[0].(*StringValue)
+				if !ok || s.Value == "" {
+					return nil, fmt.Errorf("snapshot() name must be a non-empty string, got %s", Inspect(args[0]))
+				}
+				name = s.Value
+			default:
+				return nil, fmt.Errorf("snapshot() takes 1 or 2 arguments, got %d", len(args))
+			}
+			if rt.snapshots == nil {
+				return nil, fmt.Errorf("snapshot() only works under glace test")
+			}
+			return NONE, rt.snapshots.check(name, args[len(args)-1])
+		},
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Serialization and diffs
+// ---------------------------------------------------------------------------
+
+// snapshotWidth is the line length past which arrays and maps are split
+// one element per line, as glace fmt does.
+const snapshotWidth = 80
+
+// snapshotText renders v deterministically. Strings with line breaks
+// are stored as their text; other values as Glace literals.
+func snapshotText(v Value) string {
+	if s, ok := v.(*StringValue); ok && strings.Contains(s.Value, "\n") {
+		return strings.TrimRight(s.Value, "\n")
+	}
+	var b strings.Builder
+	writeSnapshotValue(&b, v, "")
+	return b.String()
+}
+
+func writeSnapshotValue(b *strings.Builder, v Value, indent string) {
+	inline := Inspect(v)
+	if len(indent)+len(inline) <= snapshotWidth {
+		b.WriteString(inline)
+		return
+	}
+	inner := indent + "    "
+	switch val := v.(type) {
+	case *ArrayValue:
+		b.WriteString("[\n")
+		for i, e := range val.Elements {
+			b.WriteString(inner)
+			writeSnapshotValue(b, e, inner)
+			if i < len(val.Elements)-1 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString(indent + "]")
+	case *MapValue:
+		keys := make([]string, 0, len(val.Pairs))
+		for k := range val.Pairs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteString("{\n")
+		for _, k := range keys {
+			fmt.Fprintf(b, "%s%q: ", inner, k)
+			writeSnapshotValue(b, val.Pairs[k], inner)
+			b.WriteString(",\n")
+		}
+		b.WriteString(indent + "}")
+	default:
+		b.WriteString(inline)
+	}
+}
+
+// lineDiff shows how the lines of a became those of b, marking removed
+// lines with - and added ones with +, with unchanged lines for context.
+func lineDiff(a, b string) string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// lcs[i][j] is the length of the longest common subsequence of
+	// x[i:] and y[j:].
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []string
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			out = append(out, "  "+x[i])
+			i++
+			j++
+		case i < len(x) && (j == len(y) || lcs[i+1][j] >= lcs[i][j+1]):
+			out = append(out, "- "+x[i])
+			i++
+		default:
+			out = append(out, "+ "+y[j])
+			j++
+		}
+	}
+	return strings.Join(out, "\n")
+}
@@ -0,0 +1,96 @@
+package evaluator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/parser"
+)
+
+func TestSnapshots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s_test.glace")
+	run := func(source string, update bool) []TestResult {
+		t.Helper()
+		program, errs := parser.ParseSource(source, path)
+		if len(errs) > 0 {
+			t.Fatalf("parse errors: %v", errs)
+		}
+		snapshots, err := LoadSnapshots(path, update)
+		if err != nil {
+			t.Fatal(err)
+		}
+		env := NewEnvironment()
+		RegisterBuiltins(env)
+		env.Runtime().SetSnapshots(snapshots)
+		results := RunTests(program, env, TestOptions{})
+		if err := snapshots.Save(update && AllPassed(results)); err != nil {
+			t.Fatal(err)
+		}
+		return results
+	}
+	source := `test "values" {
+    snapshot({"b": [1, 2], "a": "x"})
+    snapshot("text", "one
+two")
+}`
+
+	for i := 0; i < 2; i++ {
+		if results := run(source, false); !results[0].Passed {
+			t.Fatalf("run %d failed: %s", i+1, results[0].Error)
+		}
+	}
+	data, err := os.ReadFile(SnapshotPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `
+snapshot "values 1"
+    {"a": "x", "b": [1, 2]}
+
+snapshot "values: text"
+    one
+    two
+`
+	if !strings.HasSuffix(string(data), want) {
+		t.Errorf("wrong snapshot file:\n%s", data)
+	}
+
+	changed := strings.Replace(source, "two", "three", 1)
+	results := run(changed, false)
+	if results[0].Passed || !strings.Contains(results[0].Error, `snapshot "values: text" does not match`) ||
+		!strings.Contains(results[0].Error, "  one\n- two\n+ three") {
+		t.Errorf("expected a diff, got %q", results[0].Error)
+	}
+
+	if results := run(changed, true); !results[0].Passed {
+		t.Fatalf("update failed: %s", results[0].Error)
+	}
+	if results := run(changed, false); !results[0].Passed {
+		t.Errorf("updated snapshot does not match: %s", results[0].Error)
+	}
+
+	// A test that fails before its snapshots keeps them under update.
+	failing := strings.Replace(changed, `test "values" {`, `test "values" {
+    assert(false)`, 1)
+	if results := run(failing, true); results[0].Passed {
+		t.Fatal("expected the test to fail")
+	}
+	if data, err := os.ReadFile(SnapshotPath(path)); err != nil || !strings.Contains(string(data), "    three\n") {
+		t.Errorf("snapshots were dropped after a failing test: %q (%v)", data, err)
+	}
+}
+
+func TestSnapshotText(t *testing.T) {
+	long := NewArray(nil)
+	for i := 0; i < 20; i++ {
+		long.Elements = append(long.Elements, NewString("item"))
+	}
+	v := NewMap(map[string]Value{"short": NewInt(1), "long": long})
+	got := snapshotText(v)
+	want := "{\n    \"long\": [\n        \"item\",\n"
+	if !strings.HasPrefix(got, want) || !strings.HasSuffix(got, "\"item\"\n    ],\n    \"short\": 1,\n}") {
+		t.Errorf("wrong layout:\n%s", got)
+	}
+}
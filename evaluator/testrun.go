@@ -0,0 +1,256 @@
+package evaluator
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
+	"github.com/glace-lang/glace/lexer"
+)
+
+// ---------------------------------------------------------------------------
+// Test Runner (used by `glace test` command)
+// ---------------------------------------------------------------------------
+
+// TestOptions controls which tests RunTests runs.
+type TestOptions struct {
+	Run *regexp.Regexp // only run tests whose full name matches; nil runs all
+}
+
+// TestResult holds the outcome of a single test block, or of setup code
+// or a before_all or after_all hook that failed.
+type TestResult struct {
+	Name       string         // the test's description, after those of its describe groups
+	Pos        lexer.Position // where the test block starts
+	Passed     bool
+	Duration   time.Duration
+	Error      string
+	FailurePos lexer.Position   // where the failing statement is; zero when passed
+	Diagnostic *diag.Diagnostic // the failure as a diagnostic; nil when passed
+	Output     string           // everything the test printed
+}
+
+// GroupSeparator joins the descriptions of describe groups and their
+// tests into full test names, such as "parser > errors > recovers".
+const GroupSeparator = " > "
+
+// RunTests evaluates a program and runs its test blocks.
+//
+// The program and each describe block form a group. A group first runs
+// its other statements, then its before_all hooks, then its tests and
+// nested groups in order, then its after_all hooks. Each test runs in a
+// scope of its own, between the before_each hooks of its groups, from
+// the outermost in, and their after_each hooks, from the innermost out.
+// before_all hooks run in the group's own scope, like its statements.
+//
+// When setup code or a before_all hook fails, the failure is reported as
+// a result of its own, named like "group > (setup)", and the group's
+// tests are skipped. Groups with no test matching opts.Run do not run.
+func RunTests(program *ast.Program, env *Environment, opts TestOptions) []TestResult {
+	r := &testRunner{rt: env.Runtime(), opts: opts, results: make([]TestResult, 0)}
+	g := newTestGroup("", program.Statements)
+	if !r.setup(g, env) {
+		return r.results
+	}
+	if r.selected(g) {
+		r.run(g, env, nil, nil)
+	}
+	return r.results
+}
+
+// AllPassed reports whether every result passed, including those
+// recorded for failed setup code and hooks.
+func AllPassed(results []TestResult) bool {
+	for _, res := range results {
+		if !res.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// testGroup is the body of a program or describe block, sorted by role.
+type testGroup struct {
+	name  string // full name; "" for the program
+	setup []ast.Statement
+	hooks map[string][]*ast.HookBlock
+	items []ast.Statement // *ast.TestBlock and *ast.DescribeBlock, in order
+}
+
+func newTestGroup(name string, stmts []ast.Statement) *testGroup {
+	g := &testGroup{name: name, hooks: map[string][]*ast.HookBlock{}}
+	for _, stmt := range stmts {
+		switch n := stmt.(type) {
+		case *ast.TestBlock, *ast.DescribeBlock:
+			g.items = append(g.items, n)
+		case *ast.HookBlock:
+			g.hooks[n.Kind] = append(g.hooks[n.Kind], n)
+		default:
+			g.setup = append(g.setup, n)
+		}
+	}
+	return g
+}
+
+// qualify returns the full name of name inside group.
+func qualify(group, name string) string {
+	if group == "" {
+		return name
+	}
+	return group + GroupSeparator + name
+}
+
+type testRunner struct {
+	rt      *Runtime
+	opts    TestOptions
+	results []TestResult
+}
+
+// selected reports whether g holds a test that opts.Run selects.
+func (r *testRunner) selected(g *testGroup) bool {
+	for _, item := range g.items {
+		switch n := item.(type) {
+		case *ast.TestBlock:
+			if r.opts.Run == nil || r.opts.Run.MatchString(qualify(g.name, n.Description)) {
+				return true
+			}
+		case *ast.DescribeBlock:
+			if r.selected(newTestGroup(qualify(g.name, n.Description), n.Body.Statements)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setup runs g's statements other than tests and hooks in env. It
+// reports whether they all succeeded.
+func (r *testRunner) setup(g *testGroup, env *Environment) bool {
+	for _, stmt := range g.setup {
+		err := r.rt.onStatement(stmt, env)
+		if err == nil {
+			_, err = Eval(stmt, env)
+		}
+		if err != nil {
+			r.record(qualify(g.name, "(setup)"), stmt.TokenPos(), 0, "", err, "")
+			return false
+		}
+	}
+	return true
+}
+
+// run runs g's hooks, tests and nested groups, after its setup has run
+// in env. beforeEach and afterEach hold the hooks of the enclosing
+// groups, in the order they run.
+func (r *testRunner) run(g *testGroup, env *Environment, beforeEach, afterEach []*ast.HookBlock) {
+	if r.allHooks(g, "before_all", env) {
+		beforeEach = append(append([]*ast.HookBlock{}, beforeEach...), g.hooks["before_each"]...)
+		afterEach = append(append([]*ast.HookBlock{}, g.hooks["after_each"]...), afterEach...)
+		for _, item := range g.items {
+			switch n := item.(type) {
+			case *ast.TestBlock:
+				name := qualify(g.name, n.Description)
+				if r.opts.Run == nil || r.opts.Run.MatchString(name) {
+					r.test(name, n, env, beforeEach, afterEach)
+				}
+			case *ast.DescribeBlock:
+				sub := newTestGroup(qualify(g.name, n.Description), n.Body.Statements)
+				if !r.selected(sub) {
+					continue
+				}
+				subEnv := NewEnclosedEnvironment(env)
+				if r.setup(sub, subEnv) {
+					r.run(sub, subEnv, beforeEach, afterEach)
+				}
+			}
+		}
+	}
+	r.allHooks(g, "after_all", env)
+}
+
+// allHooks runs g's before_all or after_all hooks, stopping at the first
+// that fails. A failure is recorded as a result named after the hook.
+// before_all hooks run in the group's scope, so what they define is
+// shared by its tests; after_all hooks run in scopes of their own.
+func (r *testRunner) allHooks(g *testGroup, kind string, env *Environment) bool {
+	for _, h := range g.hooks[kind] {
+		scope := env
+		if kind == "after_all" {
+			scope = NewEnclosedEnvironment(env)
+		}
+		start := time.Now()
+		output, err := r.rt.Capture(func() error {
+			_, err := Eval(h.Body, scope)
+			return err
+		})
+		if err != nil {
+			r.record(qualify(g.name, "("+kind+")"), h.Pos, time.Since(start), output, err, "")
+			return false
+		}
+	}
+	return true
+}
+
+// test runs one test block in a scope of its own inside env. before_each
+// hooks run in that scope, so what they define is visible to the test
+// and to after_each hooks, which run in scopes of their own inside it.
+// The test fails if a before_each hook, the body or an after_each hook
+// fails. The first before_each failure skips the rest and the body;
+// after_each hooks all run regardless, so each can clean up.
+func (r *testRunner) test(name string, tb *ast.TestBlock, env *Environment, beforeEach, afterEach []*ast.HookBlock) {
+	testEnv := NewEnclosedEnvironment(env)
+	if s := r.rt.snapshots; s != nil {
+		s.begin(name)
+		defer s.begin("")
+	}
+	hooks := func(list []*ast.HookBlock, keepGoing bool) error {
+		var first error
+		for _, h := range list {
+			scope := testEnv
+			if keepGoing {
+				scope = NewEnclosedEnvironment(testEnv)
+			}
+			if _, err := Eval(h.Body, scope); err != nil && first == nil {
+				first = err
+				if !keepGoing {
+					break
+				}
+			}
+		}
+		return first
+	}
+	var stage string // the hook kind that failed, if one did
+	start := time.Now()
+	output, err := r.rt.Capture(func() error {
+		err := hooks(beforeEach, false)
+		if err != nil {
+			stage = "before_each"
+		} else {
+			_, err = Eval(tb.Body, testEnv)
+		}
+		if afterErr := hooks(afterEach, true); afterErr != nil && err == nil {
+			err, stage = afterErr, "after_each"
+		}
+		return err
+	})
+	r.record(name, tb.Pos, time.Since(start), output, err, stage)
+}
+
+// record adds a result for the code at pos that ended with err, which is
+// nil when it passed. stage names the hook that failed, if any.
+func (r *testRunner) record(name string, pos lexer.Position, took time.Duration, output string, err error, stage string) {
+	result := TestResult{Name: name, Pos: pos, Passed: err == nil, Duration: took, Output: output}
+	if err != nil {
+		result.Error = err.Error()
+		if stage != "" {
+			result.Error = stage + ": " + result.Error
+		}
+		result.Diagnostic = ErrorDiagnostic(err, pos)
+		result.FailurePos = result.Diagnostic.Start
+		if result.FailurePos.Line == 0 {
+			result.FailurePos = pos
+		}
+	}
+	r.results = append(r.results, result)
+}
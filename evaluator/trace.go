@@ -0,0 +1,215 @@
+package evaluator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/lexer"
+)
+
+// TraceOptions configures a Tracer.
+type TraceOptions struct {
+	Format   string // "text" (default) or "json"
+	File     string // only trace positions in this file; a path, base name or glob
+	Function string // only trace inside calls to this function (and what it calls)
+	Source   string // program source, echoed next to statements in text output
+}
+
+// TraceEvent is one line of trace output. In JSON mode each event is
+// written as a single object followed by a newline.
+type TraceEvent struct {
+	Event  string   `json:"event"` // "stmt", "call" or "return"
+	File   string   `json:"file,omitempty"`
+	Line   int      `json:"line"`
+	Column int      `json:"column"`
+	Depth  int      `json:"depth"`
+	Fn     string   `json:"fn,omitempty"`   // enclosing function for stmt, callee otherwise
+	Node   string   `json:"node,omitempty"` // statement kind, stmt events only
+	Args   []string `json:"args,omitempty"`
+	Value  string   `json:"value,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// Tracer logs statements and function calls as a program runs.
+type Tracer struct {
+	out    io.Writer
+	rt     *Runtime // supplies the stream when out is nil
+	opts   TraceOptions
+	lines  []string
+	frames []traceFrame
+}
+
+type traceFrame struct {
+	name string
+	pos  lexer.Position
+}
+
+// NewTracer creates a Tracer writing to out. Nil means os.Stderr, or the
+// Stderr of the runtime it was attached to with Runtime.Trace.
+func NewTracer(out io.Writer, opts TraceOptions) *Tracer {
+	t := &Tracer{out: out, opts: opts}
+	if opts.Source != "" {
+		t.lines = strings.Split(opts.Source, "\n")
+	}
+	return t
+}
+
+// Hooks returns the evaluator hooks that feed this tracer.
+func (t *Tracer) Hooks() *Hooks {
+	return &Hooks{
+		Statement: t.statement,
+		Call:      t.call,
+		Return:    t.ret,
+	}
+}
+
+// Trace attaches a new Tracer to the runtime. Nil out means the
+// runtime's Stderr.
+func (r *Runtime) Trace(out io.Writer, opts TraceOptions) *Tracer {
+	t := NewTracer(out, opts)
+	t.rt = r
+	r.AddHooks(t.Hooks())
+	return t
+}
+
+func (t *Tracer) statement(stmt ast.Statement, env *Environment) error {
+	pos := stmt.TokenPos()
+	if !t.inScope() || !t.matchFile(pos) {
+		return nil
+	}
+	t.emit(TraceEvent{
+		Event:  "stmt",
+		File:   pos.File,
+		Line:   pos.Line,
+		Column: pos.Column,
+		Depth:  len(t.frames),
+		Fn:     t.currentFn(),
+		Node:   stmt.String(),
+	})
+	return nil
+}
+
+func (t *Tracer) call(fn *FnValue, args []Value, pos lexer.Position) {
+	t.frames = append(t.frames, traceFrame{name: fnName(fn), pos: pos})
+	if !t.inScope() || !t.matchFile(pos) {
+		return
+	}
+	strs := make([]string, len(args))
+	for i, a := range args {
+		strs[i] = Inspect(a)
+	}
+	t.emit(TraceEvent{
+		Event:  "call",
+		File:   pos.File,
+		Line:   pos.Line,
+		Column: pos.Column,
+		Depth:  len(t.frames) - 1,
+		Fn:     fnName(fn),
+		Args:   strs,
+	})
+}
+
+func (t *Tracer) ret(fn *FnValue, result Value, err error) {
+	if len(t.frames) == 0 {
+		return
+	}
+	frame := t.frames[len(t.frames)-1]
+	show := t.inScope() && t.matchFile(frame.pos)
+	t.frames = t.frames[:len(t.frames)-1]
+	if !show {
+		return
+	}
+	ev := TraceEvent{
+		Event:  "return",
+		File:   frame.pos.File,
+		Line:   frame.pos.Line,
+		Column: frame.pos.Column,
+		Depth:  len(t.frames),
+		Fn:     frame.name,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+	} else if result != nil {
+		ev.Value = Inspect(result)
+	}
+	t.emit(ev)
+}
+
+// inScope reports whether the function filter admits the current frame.
+func (t *Tracer) inScope() bool {
+	if t.opts.Function == "" {
+		return true
+	}
+	for _, f := range t.frames {
+		if f.name == t.opts.Function {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tracer) matchFile(pos lexer.Position) bool {
+	want := t.opts.File
+	if want == "" || pos.File == want || filepath.Base(pos.File) == want {
+		return true
+	}
+	ok, _ := filepath.Match(want, pos.File)
+	return ok
+}
+
+func (t *Tracer) currentFn() string {
+	if len(t.frames) == 0 {
+		return ""
+	}
+	return t.frames[len(t.frames)-1].name
+}
+
+func (t *Tracer) emit(ev TraceEvent) {
+	out := t.out
+	if out == nil && t.rt != nil {
+		out = t.rt.Stderr()
+	} else if out == nil {
+		out = os.Stderr
+	}
+	if t.opts.Format == "json" {
+		data, _ := json.Marshal(ev)
+		fmt.Fprintf(out, "%s\n", data)
+		return
+	}
+
+	pos := lexer.Position{File: ev.File, Line: ev.Line, Column: ev.Column}
+	indent := strings.Repeat("  ", ev.Depth)
+	switch ev.Event {
+	case "stmt":
+		fmt.Fprintf(out, "[trace] %-20s %s%s\n", pos, indent, t.sourceLine(ev.Line, ev.Node))
+	case "call":
+		fmt.Fprintf(out, "[trace] %-20s %s→ %s(%s)\n", pos, indent, ev.Fn, strings.Join(ev.Args, ", "))
+	case "return":
+		if ev.Error != "" {
+			fmt.Fprintf(out, "[trace] %-20s %s← %s !! %s\n", pos, indent, ev.Fn, ev.Error)
+		} else {
+			fmt.Fprintf(out, "[trace] %-20s %s← %s = %s\n", pos, indent, ev.Fn, ev.Value)
+		}
+	}
+}
+
+// sourceLine returns the trimmed source text of line, or fallback when
+// the tracer has no source for it.
+func (t *Tracer) sourceLine(line int, fallback string) string {
+	if line < 1 || line > len(t.lines) {
+		return fallback
+	}
+	return strings.TrimSpace(t.lines[line-1])
+}
+
+func fnName(fn *FnValue) string {
+	if fn.Name == "" {
+		return "<fn>"
+	}
+	return fn.Name
+}
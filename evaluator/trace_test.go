@@ -0,0 +1,142 @@
+package evaluator
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/parser"
+)
+
+const traceLib = `fn double(n) {
+    return n * 2
+}`
+
+const traceMain = `fn quad(n) {
+    return double(double(n))
+}
+let a = double(1)
+quad(3)`
+
+// runTraced evaluates lib.glace and then main.glace in one environment
+// with a tracer installed, returning its output.
+func runTraced(t *testing.T, opts TraceOptions) string {
+	t.Helper()
+	var out strings.Builder
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+	env.Runtime().AddHooks(NewTracer(&out, opts).Hooks())
+	for _, f := range []struct{ name, src string }{{"lib.glace", traceLib}, {"main.glace", traceMain}} {
+		program, errs := parser.Parse(lexer.New(f.src, f.name).Tokenize())
+		if len(errs) > 0 {
+			t.Fatalf("parse errors: %v", errs)
+		}
+		if _, err := Eval(program, env); err != nil {
+			t.Fatalf("%s: %v", f.name, err)
+		}
+	}
+	return out.String()
+}
+
+// traceEvents decodes JSON trace output into a compact "event fn line"
+// form per line.
+func traceEvents(t *testing.T, out string) []string {
+	t.Helper()
+	var events []string
+	scanner := bufio.NewScanner(strings.NewReader(out))
+	for scanner.Scan() {
+		var ev TraceEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			t.Fatalf("bad JSON line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, fmt.Sprintf("%s %s %s:%d depth=%d", ev.Event, ev.Fn, ev.File, ev.Line, ev.Depth))
+	}
+	return events
+}
+
+func TestTraceText(t *testing.T) {
+	got := runTraced(t, TraceOptions{Source: traceMain, File: "main.glace"})
+	expected := `[trace] main.glace:1:1       fn quad(n) {
+[trace] main.glace:4:1       let a = double(1)
+[trace] main.glace:4:15      → double(1)
+[trace] main.glace:4:15      ← double = 2
+[trace] main.glace:5:1       quad(3)
+[trace] main.glace:5:5       → quad(3)
+[trace] main.glace:2:5         return double(double(n))
+[trace] main.glace:2:25        → double(3)
+[trace] main.glace:2:25        ← double = 6
+[trace] main.glace:2:18        → double(6)
+[trace] main.glace:2:18        ← double = 12
+[trace] main.glace:5:5       ← quad = 12
+`
+	if got != expected {
+		t.Errorf("trace wrong.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestTraceJSON(t *testing.T) {
+	out := runTraced(t, TraceOptions{Format: "json"})
+	first := strings.SplitN(out, "\n", 2)[0]
+	if first != `{"event":"stmt","file":"lib.glace","line":1,"column":1,"depth":0,"node":"FnDeclaration(double)"}` {
+		t.Errorf("first event wrong: %s", first)
+	}
+	checkEvents(t, traceEvents(t, out), []string{
+		"stmt  lib.glace:1 depth=0",
+		"stmt  main.glace:1 depth=0",
+		"stmt  main.glace:4 depth=0",
+		"call double main.glace:4 depth=0",
+		"stmt double lib.glace:2 depth=1",
+		"return double main.glace:4 depth=0",
+		"stmt  main.glace:5 depth=0",
+		"call quad main.glace:5 depth=0",
+		"stmt quad main.glace:2 depth=1",
+		"call double main.glace:2 depth=1",
+		"stmt double lib.glace:2 depth=2",
+		"return double main.glace:2 depth=1",
+		"call double main.glace:2 depth=1",
+		"stmt double lib.glace:2 depth=2",
+		"return double main.glace:2 depth=1",
+		"return quad main.glace:5 depth=0",
+	})
+}
+
+func TestTraceFunctionFilter(t *testing.T) {
+	// Only quad and what it calls; the top-level double(1) is left out.
+	checkEvents(t, traceEvents(t, runTraced(t, TraceOptions{Format: "json", Function: "quad"})), []string{
+		"call quad main.glace:5 depth=0",
+		"stmt quad main.glace:2 depth=1",
+		"call double main.glace:2 depth=1",
+		"stmt double lib.glace:2 depth=2",
+		"return double main.glace:2 depth=1",
+		"call double main.glace:2 depth=1",
+		"stmt double lib.glace:2 depth=2",
+		"return double main.glace:2 depth=1",
+		"return quad main.glace:5 depth=0",
+	})
+}
+
+func TestTraceFileFilter(t *testing.T) {
+	// Calls into lib.glace are made from main.glace, so only its
+	// statements are left.
+	expected := []string{
+		"stmt  lib.glace:1 depth=0",
+		"stmt double lib.glace:2 depth=1",
+		"stmt double lib.glace:2 depth=2",
+		"stmt double lib.glace:2 depth=2",
+	}
+	for _, file := range []string{"lib.glace", "lib.*"} {
+		checkEvents(t, traceEvents(t, runTraced(t, TraceOptions{Format: "json", File: file})), expected)
+	}
+	both := traceEvents(t, runTraced(t, TraceOptions{Format: "json", File: "lib.glace", Function: "quad"}))
+	checkEvents(t, both, expected[2:])
+}
+
+func checkEvents(t *testing.T, got, expected []string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("events wrong.\nexpected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
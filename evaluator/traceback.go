@@ -0,0 +1,63 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/glace-lang/glace/lexer"
+)
+
+// FormatTraceback renders err Python-style: one entry per active call,
+// most recent last, then the offending source line with a caret under
+// the error column. sources maps file names to their text; entries for
+// files missing from it are shown without a source line.
+func FormatTraceback(err *RuntimeError, sources map[string]string) string {
+	var b strings.Builder
+	b.WriteString("Traceback (most recent call last):\n")
+
+	// An error raised by a builtin is reported at the line that called it,
+	// so a builtin innermost frame adds nothing and is dropped.
+	trace := err.Trace
+	if n := len(trace); n > 0 && trace[n-1].Builtin {
+		trace = trace[:n-1]
+	}
+
+	// Each frame records where it was called from, so the position shown
+	// for a function is the call site of the next frame, and the innermost
+	// function is positioned at the error itself.
+	name := "<main>"
+	for _, f := range trace {
+		writeTraceEntry(&b, name, f.CallPos, sources, false)
+		name = f.Function
+	}
+	writeTraceEntry(&b, name, err.Pos, sources, true)
+
+	fmt.Fprintf(&b, "runtime error: %s", err.Message)
+	return b.String()
+}
+
+func writeTraceEntry(b *strings.Builder, fn string, pos lexer.Position, sources map[string]string, caret bool) {
+	if pos.Line == 0 {
+		fmt.Fprintf(b, "  File \"<builtin>\", in %s\n", fn)
+		return
+	}
+	fmt.Fprintf(b, "  File %q, line %d, in %s\n", pos.File, pos.Line, fn)
+
+	src, ok := sources[pos.File]
+	if !ok {
+		return
+	}
+	lines := strings.Split(src, "\n")
+	if pos.Line > len(lines) {
+		return
+	}
+	line := strings.TrimRight(lines[pos.Line-1], " \t\r")
+	trimmed := strings.TrimLeft(line, " \t")
+	fmt.Fprintf(b, "    %s\n", trimmed)
+	if caret {
+		col := pos.Column - 1 - (len(line) - len(trimmed))
+		if col >= 0 && col <= len(trimmed) {
+			fmt.Fprintf(b, "    %s^\n", strings.Repeat(" ", col))
+		}
+	}
+}
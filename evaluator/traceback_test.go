@@ -0,0 +1,62 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/parser"
+)
+
+func TestRuntimeErrorTraceback(t *testing.T) {
+	input := `fn inner(arr) {
+    return arr[5]
+}
+fn outer() {
+    return inner([1, 2, 3])
+}
+outer()`
+
+	program, errs := parser.Parse(lexer.New(input, "t.glace").Tokenize())
+	if len(errs) > 0 {
+		t.Fatalf("parse errors: %v", errs)
+	}
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+
+	_, err := Eval(program, env)
+	re, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected *RuntimeError, got %T (%v)", err, err)
+	}
+
+	expected := `Traceback (most recent call last):
+  File "t.glace", line 7, in <main>
+    outer()
+  File "t.glace", line 5, in outer
+    return inner([1, 2, 3])
+  File "t.glace", line 2, in inner
+    return arr[5]
+              ^
+runtime error: index 5 out of bounds (len 3)`
+	if got := FormatTraceback(re, map[string]string{"t.glace": input}); got != expected {
+		t.Errorf("traceback wrong.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+	if len(env.Runtime().Stack()) != 0 {
+		t.Errorf("call stack not unwound: %v", env.Runtime().Stack())
+	}
+}
+
+func TestBuiltinErrorHasCallSite(t *testing.T) {
+	program, _ := parser.Parse(lexer.New(`let n = len(1, 2)`, "t.glace").Tokenize())
+	env := NewEnvironment()
+	RegisterBuiltins(env)
+
+	_, err := Eval(program, env)
+	re, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected *RuntimeError, got %T (%v)", err, err)
+	}
+	if re.Pos.Line != 1 || re.Pos.Column != 12 {
+		t.Errorf("expected position 1:12, got %s", re.Pos)
+	}
+}
@@ -1,6 +1,10 @@
 package evaluator
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
 
 // ---------------------------------------------------------------------------
 // Value Interface
@@ -32,6 +36,34 @@ func IsTruthy(v Value) bool {
 	}
 }
 
+// Inspect renders v the way it would be written in source: strings are
+// quoted and map keys are sorted so the output is deterministic.
+func Inspect(v Value) string {
+	switch val := v.(type) {
+	case *StringValue:
+		return fmt.Sprintf("%q", val.Value)
+	case *ArrayValue:
+		parts := make([]string, len(val.Elements))
+		for i, e := range val.Elements {
+			parts[i] = Inspect(e)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *MapValue:
+		keys := make([]string, 0, len(val.Pairs))
+		for k := range val.Pairs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = fmt.Sprintf("%q: %s", k, Inspect(val.Pairs[k]))
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return v.String()
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Concrete Value Types
 // ---------------------------------------------------------------------------
@@ -166,6 +198,7 @@ func (v *MapValue) Equals(other Value) bool {
 // FnValue represents a function (named or anonymous).
 type FnValue struct {
 	Name   string   // "" for anonymous functions
+	Doc    string   // from /// comments on its declaration
 	Params []string
 	Body   interface{} // *ast.BlockStatement — kept as interface to avoid import cycle
 	Env    *Environment
@@ -221,12 +254,31 @@ func (v *RangeValue) At(i int64) int64 {
 type BuiltinFn struct {
 	Name string
 	Fn   func(args []Value) (Value, error)
+
+	// Signature documents the parameters for tools, e.g. "push(arr, val)".
+	// A trailing ? marks an optional parameter and ... a variadic one.
+	Signature string
+	// Doc is a one-line description shown by editors and help.
+	Doc string
+
+	runtime *Runtime // set on registration so calls show up on the stack
 }
 
 func (v *BuiltinFn) Type() string          { return "builtin" }
 func (v *BuiltinFn) String() string        { return fmt.Sprintf("<builtin %s>", v.Name) }
 func (v *BuiltinFn) Equals(other Value) bool { return v == other }
 
+// HostObject is implemented by Go values handed to scripts as objects
+// with fields and methods, such as a request or a database handle.
+// Type names the object for type() and error messages. Errors returned
+// by the methods become runtime errors at the script's access or call.
+type HostObject interface {
+	Value
+	GetField(name string) (Value, error)
+	SetField(name string, v Value) error
+	CallMethod(name string, args []Value) (Value, error)
+}
+
 // ---------------------------------------------------------------------------
 // Convenience Constructors
 // ---------------------------------------------------------------------------
@@ -0,0 +1,580 @@
+// Package format prints Glace programs in canonical form, the way
+// `glace fmt` writes them: four-space indentation, single spaces around
+// binary operators, at most one blank line between statements, aligned
+// trailing comments, and long pipelines and literals split across lines.
+package format
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/parser"
+)
+
+const (
+	indentUnit = "    "
+	maxWidth   = 80
+
+	// commentMark separates a line's code from its trailing comment until
+	// the comments of neighbouring lines are aligned.
+	commentMark = "\x00"
+)
+
+// Source formats src. Source with syntax errors is not formatted; it is
+// returned unchanged with the diagnostics.
+func Source(src, file string) (string, []*diag.Diagnostic) {
+	prog, diags := parser.ParseSource(src, file)
+	if len(diags) > 0 {
+		return src, diags
+	}
+	return Program(prog, src), nil
+}
+
+// Program prints prog. source is the text prog was parsed from; it is only
+// consulted to keep blank lines between statements and may be empty.
+func Program(prog *ast.Program, source string) string {
+	p := &printer{
+		buf:        &strings.Builder{},
+		lines:      strings.Split(source, "\n"),
+		comments:   prog.Comments,
+		blockStart: true,
+	}
+	p.statements(prog.Statements)
+	p.flushComments(math.MaxInt)
+	return alignComments(p.buf.String())
+}
+
+type printer struct {
+	buf        *strings.Builder
+	indent     int
+	lines      []string        // source lines, for blank line detection
+	comments   []lexer.Comment // all comments, in source order
+	next       int             // index of the first comment not yet printed
+	blockStart bool            // nothing printed yet in the current block
+}
+
+// ---------------------------------------------------------------------------
+// Lines and comments
+// ---------------------------------------------------------------------------
+
+func (p *printer) ind() string {
+	return strings.Repeat(indentUnit, p.indent)
+}
+
+// startLine prepares for an item that started on source line srcLine,
+// keeping one blank line before it if the source had any.
+func (p *printer) startLine(srcLine int) {
+	if !p.blockStart && srcLine >= 2 && srcLine-2 < len(p.lines) && strings.TrimSpace(p.lines[srcLine-2]) == "" {
+		p.buf.WriteString("\n")
+	}
+	p.blockStart = false
+}
+
+// line writes text at the current indentation. Lines embedded in text
+// (block bodies of function literals) carry their own indentation.
+func (p *printer) line(text string) {
+	p.buf.WriteString(p.ind() + text + "\n")
+}
+
+// flushComments prints the comments that come before source line line.
+// A trailing comment goes at the end of the last line printed.
+func (p *printer) flushComments(line int) {
+	for p.next < len(p.comments) && p.comments[p.next].Pos.Line < line {
+		c := p.comments[p.next]
+		p.next++
+		text := strings.TrimRight(c.Text, "\r \t")
+		out := p.buf.String()
+		if c.Trailing && strings.HasSuffix(out, "\n") {
+			last := out[strings.LastIndex(out[:len(out)-1], "\n")+1:]
+			sep := commentMark
+			if strings.Contains(last, commentMark) {
+				sep = " "
+			}
+			p.buf.Reset()
+			p.buf.WriteString(out[:len(out)-1] + sep + text + "\n")
+			continue
+		}
+		p.startLine(c.Pos.Line)
+		p.line(text)
+	}
+}
+
+// alignComments lines up the trailing comments of consecutive lines one
+// space after the longest code among them.
+func alignComments(s string) string {
+	lines := strings.Split(s, "\n")
+	for i := 0; i < len(lines); {
+		if !strings.Contains(lines[i], commentMark) {
+			i++
+			continue
+		}
+		j, width := i, 0
+		for ; j < len(lines) && strings.Contains(lines[j], commentMark); j++ {
+			code, _, _ := strings.Cut(lines[j], commentMark)
+			if n := utf8.RuneCountInString(code); n > width {
+				width = n
+			}
+		}
+		for k := i; k < j; k++ {
+			code, text, _ := strings.Cut(lines[k], commentMark)
+			pad := width - utf8.RuneCountInString(code) + 1
+			lines[k] = code + strings.Repeat(" ", pad) + text
+		}
+		i = j
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ---------------------------------------------------------------------------
+// Statements
+// ---------------------------------------------------------------------------
+
+func (p *printer) statements(list []ast.Statement) {
+	for _, s := range list {
+		line := s.TokenPos().Line
+		p.flushComments(line)
+		p.startLine(line)
+		p.statement(s)
+	}
+}
+
+// body prints the statements of b one level deeper, with the comments
+// before its closing brace.
+func (p *printer) body(b *ast.BlockStatement) {
+	p.indent++
+	p.blockStart = true
+	p.statements(b.Statements)
+	if b.End.Line > 0 {
+		p.flushComments(b.End.Line)
+	}
+	p.indent--
+	p.blockStart = false
+}
+
+func (p *printer) statement(s ast.Statement) {
+	switch n := s.(type) {
+	case *ast.LetStatement:
+		p.assignment("let "+n.Name+" = ", n.Value)
+	case *ast.MutStatement:
+		p.assignment("mut "+n.Name+" = ", n.Value)
+	case *ast.AssignStatement:
+		p.assignment(n.Name+" = ", n.Value)
+	case *ast.IndexAssignStatement:
+		target := p.expr(n.Left, parser.PREC_CALL) + "[" + p.expr(n.Index, parser.PREC_LOWEST) + "]"
+		p.assignment(target+" = ", n.Value)
+	case *ast.FieldAssignStatement:
+		p.assignment(p.expr(n.Left, parser.PREC_CALL)+"."+n.Field+" = ", n.Value)
+	case *ast.ExpressionStatement:
+		p.assignment("", n.Expression)
+	case *ast.ReturnStatement:
+		if len(n.Values) == 1 {
+			p.assignment("return ", n.Values[0])
+			return
+		}
+		p.line(strings.TrimSpace("return " + p.exprList(n.Values)))
+	case *ast.BreakStatement:
+		p.line("break")
+	case *ast.ContinueStatement:
+		p.line("continue")
+	case *ast.IfStatement:
+		head := "if " + p.expr(n.Condition, parser.PREC_LOWEST)
+		if len(n.ElifClauses) == 0 && n.Alternative == nil {
+			p.block(head, n.Consequence)
+			return
+		}
+		p.line(head + " {")
+		p.body(n.Consequence)
+		for _, elif := range n.ElifClauses {
+			p.line("} elif " + p.expr(elif.Condition, parser.PREC_LOWEST) + " {")
+			p.body(elif.Consequence)
+		}
+		if n.Alternative != nil {
+			p.line("} else {")
+			p.body(n.Alternative)
+		}
+		p.line("}")
+	case *ast.LoopStatement:
+		switch {
+		case n.Iterable != nil:
+			p.block("loop "+n.Iterator+" in "+p.expr(n.Iterable, parser.PREC_LOWEST), n.Body)
+		case n.Condition != nil:
+			p.block("loop "+p.expr(n.Condition, parser.PREC_LOWEST), n.Body)
+		default:
+			p.block("loop", n.Body)
+		}
+	case *ast.FnDeclaration:
+		head := "fn " + n.Name + "(" + strings.Join(n.Params, ", ") + ")"
+		if n.Arrow {
+			p.assignment(head+" => ", arrowValue(n.Body))
+			return
+		}
+		p.block(head, n.Body)
+	case *ast.MatchStatement:
+		p.line("match " + p.expr(n.Subject, parser.PREC_LOWEST) + " {")
+		p.indent++
+		p.blockStart = true
+		for _, arm := range n.Arms {
+			line := arm.Pattern.TokenPos().Line
+			p.flushComments(line)
+			p.startLine(line)
+			head := p.expr(arm.Pattern, parser.PREC_LOWEST)
+			if arm.Guard != nil {
+				head += " if " + p.expr(arm.Guard, parser.PREC_LOWEST)
+			}
+			if arm.Arrow {
+				value := arrowValue(arm.Body)
+				if _, isMap := value.(*ast.MapLiteral); isMap {
+					// A bare { after => would start a block.
+					p.line(head + " => (" + p.expr(value, parser.PREC_LOWEST) + ")")
+				} else {
+					p.assignment(head+" => ", value)
+				}
+				continue
+			}
+			p.block(head+" =>", arm.Body)
+		}
+		p.flushComments(n.End.Line)
+		p.indent--
+		p.line("}")
+	case *ast.TestBlock:
+		p.block("test "+quote(n.Description), n.Body)
+	case *ast.BenchBlock:
+		p.block("bench "+quote(n.Description), n.Body)
+	case *ast.DescribeBlock:
+		p.block("describe "+quote(n.Description), n.Body)
+	case *ast.HookBlock:
+		p.block(n.Kind, n.Body)
+	}
+}
+
+// block prints head followed by the block b.
+func (p *printer) block(head string, b *ast.BlockStatement) {
+	if inline, ok := p.inlineBlock(b); ok {
+		p.line(head + " " + inline)
+		return
+	}
+	p.line(head + " {")
+	p.body(b)
+	p.line("}")
+}
+
+// inlineBlock prints b as "{ stmt }" if it was written on one line and
+// holds a single statement that fits on one line.
+func (p *printer) inlineBlock(b *ast.BlockStatement) (string, bool) {
+	if len(b.Statements) != 1 || b.End.Line != b.Pos.Line {
+		return "", false
+	}
+	switch b.Statements[0].(type) {
+	case *ast.IfStatement, *ast.LoopStatement, *ast.MatchStatement, *ast.FnDeclaration, *ast.TestBlock, *ast.BenchBlock, *ast.DescribeBlock:
+		return "", false
+	}
+	if p.next < len(p.comments) && p.comments[p.next].Pos.Line == b.Pos.Line && p.comments[p.next].Pos.Column < b.End.Column {
+		return "", false
+	}
+
+	saved, savedIndent, savedNext := p.buf, p.indent, p.next
+	p.buf, p.indent = &strings.Builder{}, 0
+	p.statement(b.Statements[0])
+	text := strings.TrimSuffix(p.buf.String(), "\n")
+	p.buf, p.indent = saved, savedIndent
+	if strings.Contains(text, "\n") {
+		p.next = savedNext
+		return "", false
+	}
+	return "{ " + text + " }", true
+}
+
+// assignment prints prefix followed by value, splitting value over
+// several lines if the statement would otherwise be too long.
+func (p *printer) assignment(prefix string, value ast.Expression) {
+	saved := p.next
+	text := p.expr(value, parser.PREC_LOWEST)
+	if p.fits(prefix + text) {
+		p.line(prefix + text)
+		return
+	}
+	switch v := value.(type) {
+	case *ast.PipelineExpression:
+		p.next = saved
+		text = p.brokenPipeline(v)
+	case *ast.ArrayLiteral:
+		if !p.hasComments(v.Pos, v.End) {
+			p.next = saved
+			text = p.brokenList("[", p.exprs(v.Elements), "]", false)
+		}
+	case *ast.MapLiteral:
+		if !p.hasComments(v.Pos, v.End) {
+			p.next = saved
+			text = p.brokenList("{", p.entries(v), "}", true)
+		}
+	}
+	p.line(prefix + text)
+}
+
+// fits reports whether the first line of text fits at the current
+// indentation.
+func (p *printer) fits(text string) bool {
+	first, _, _ := strings.Cut(text, "\n")
+	return len(indentUnit)*p.indent+utf8.RuneCountInString(first) <= maxWidth
+}
+
+// brokenPipeline prints a pipeline with each |> stage on its own line:
+//
+//	orders
+//	    |> filter(fn(o) => o.paid)
+//	    |> map(fn(o) => o.amount)
+func (p *printer) brokenPipeline(e *ast.PipelineExpression) string {
+	var stages []*ast.CallExpression
+	var base ast.Expression = e
+	for {
+		pipe, ok := base.(*ast.PipelineExpression)
+		if !ok {
+			break
+		}
+		stages = append(stages, pipe.Right)
+		base = pipe.Left
+	}
+
+	var b strings.Builder
+	b.WriteString(p.expr(base, parser.PREC_PIPELINE))
+	p.indent++
+	for i := len(stages) - 1; i >= 0; i-- {
+		b.WriteString("\n" + p.ind() + "|> " + p.expr(stages[i], parser.PREC_CALL))
+	}
+	p.indent--
+	return b.String()
+}
+
+// brokenList prints one item per line between open and close. Map
+// literals need a comma after the last entry; arrays do not allow one.
+func (p *printer) brokenList(open string, items []string, close string, trailingComma bool) string {
+	var b strings.Builder
+	b.WriteString(open + "\n")
+	inner := p.ind() + indentUnit
+	for i, item := range items {
+		b.WriteString(inner + item)
+		if i < len(items)-1 || trailingComma {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(p.ind() + close)
+	return b.String()
+}
+
+// hasComments reports whether a comment lies between start and end.
+func (p *printer) hasComments(start, end lexer.Position) bool {
+	for _, c := range p.comments {
+		if before(start, c.Pos) && before(c.Pos, end) {
+			return true
+		}
+	}
+	return false
+}
+
+func before(a, b lexer.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// commentedList prints a literal with comments between its brackets one
+// item per line, like brokenList, keeping each comment by the item it was
+// written next to. item prints the i-th item, which starts at starts[i].
+func (p *printer) commentedList(open string, starts []lexer.Position, item func(i int) string, close string, end lexer.Position, trailingComma bool) string {
+	lines := []string{open}
+	p.indent++
+	comments := func(upTo lexer.Position) {
+		for p.next < len(p.comments) && before(p.comments[p.next].Pos, upTo) {
+			c := p.comments[p.next]
+			p.next++
+			text := strings.TrimRight(c.Text, "\r \t")
+			if !c.Trailing {
+				lines = append(lines, p.ind()+text)
+				continue
+			}
+			last := lines[len(lines)-1]
+			sep := commentMark
+			if strings.Contains(last[strings.LastIndex(last, "\n")+1:], commentMark) {
+				sep = " "
+			}
+			lines[len(lines)-1] = last + sep + text
+		}
+	}
+	for i, start := range starts {
+		comments(start)
+		text := item(i)
+		if i < len(starts)-1 || trailingComma {
+			text += ","
+		}
+		lines = append(lines, p.ind()+text)
+	}
+	comments(end)
+	p.indent--
+	lines = append(lines, p.ind()+close)
+	return strings.Join(lines, "\n")
+}
+
+// arrowValue returns the expression of a => body, which the parser wraps
+// in a block holding a single return.
+func arrowValue(b *ast.BlockStatement) ast.Expression {
+	return b.Statements[0].(*ast.ReturnStatement).Values[0]
+}
+
+// ---------------------------------------------------------------------------
+// Expressions
+// ---------------------------------------------------------------------------
+
+// precAtom is the precedence of literals and identifiers, which never
+// need parentheses.
+const precAtom = parser.PREC_CALL + 1
+
+var binaryPrec = map[string]parser.Precedence{
+	"||": parser.PREC_OR,
+	"&&": parser.PREC_AND,
+	"==": parser.PREC_EQUALITY, "!=": parser.PREC_EQUALITY,
+	"<": parser.PREC_COMPARISON, ">": parser.PREC_COMPARISON,
+	"<=": parser.PREC_COMPARISON, ">=": parser.PREC_COMPARISON,
+	"+": parser.PREC_ADDITION, "-": parser.PREC_ADDITION,
+	"*": parser.PREC_MULTIPLY, "/": parser.PREC_MULTIPLY, "%": parser.PREC_MULTIPLY,
+}
+
+// expr prints e, parenthesized if it binds more loosely than min. The
+// parser drops parentheses, so they are put back only where needed.
+func (p *printer) expr(e ast.Expression, min parser.Precedence) string {
+	text, prec := p.exprPrec(e)
+	if prec < min {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+func (p *printer) exprPrec(e ast.Expression) (string, parser.Precedence) {
+	switch n := e.(type) {
+	case *ast.IntegerLiteral:
+		return strconv.FormatInt(n.Value, 10), precAtom
+	case *ast.FloatLiteral:
+		s := strconv.FormatFloat(n.Value, 'f', -1, 64)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s, precAtom
+	case *ast.StringLiteral:
+		return quote(n.Value), precAtom
+	case *ast.StringInterpolation:
+		var b strings.Builder
+		b.WriteString(`"`)
+		for _, part := range n.Parts {
+			if s, ok := part.(*ast.StringLiteral); ok {
+				b.WriteString(s.Value)
+			} else {
+				b.WriteString("${" + p.expr(part, parser.PREC_LOWEST) + "}")
+			}
+		}
+		b.WriteString(`"`)
+		return b.String(), precAtom
+	case *ast.BooleanLiteral:
+		return strconv.FormatBool(n.Value), precAtom
+	case *ast.NoneLiteral:
+		return "none", precAtom
+	case *ast.Identifier:
+		return n.Name, precAtom
+	case *ast.WildcardExpression:
+		return "_", precAtom
+	case *ast.ArrayLiteral:
+		if p.hasComments(n.Pos, n.End) {
+			starts := make([]lexer.Position, len(n.Elements))
+			for i, e := range n.Elements {
+				starts[i] = e.TokenPos()
+			}
+			return p.commentedList("[", starts, func(i int) string {
+				return p.expr(n.Elements[i], parser.PREC_LOWEST)
+			}, "]", n.End, false), precAtom
+		}
+		return "[" + strings.Join(p.exprs(n.Elements), ", ") + "]", precAtom
+	case *ast.MapLiteral:
+		if p.hasComments(n.Pos, n.End) {
+			starts := make([]lexer.Position, len(n.Keys))
+			for i, k := range n.Keys {
+				starts[i] = k.TokenPos()
+			}
+			return p.commentedList("{", starts, func(i int) string {
+				return p.expr(n.Keys[i], parser.PREC_LOWEST) + ": " + p.expr(n.Values[i], parser.PREC_LOWEST)
+			}, "}", n.End, true), precAtom
+		}
+		return "{" + strings.Join(p.entries(n), ", ") + "}", precAtom
+	case *ast.FnLiteral:
+		head := "fn(" + strings.Join(n.Params, ", ") + ")"
+		if n.Arrow {
+			// The body extends as far right as it can.
+			return head + " => " + p.expr(arrowValue(n.Body), parser.PREC_LOWEST), parser.PREC_LOWEST
+		}
+		return head + " " + p.blockText(n.Body), precAtom
+	case *ast.BinaryExpression:
+		prec := binaryPrec[n.Operator]
+		return p.expr(n.Left, prec) + " " + n.Operator + " " + p.expr(n.Right, prec+1), prec
+	case *ast.UnaryExpression:
+		return n.Operator + p.expr(n.Operand, parser.PREC_UNARY), parser.PREC_UNARY
+	case *ast.CallExpression:
+		return p.expr(n.Function, parser.PREC_CALL) + "(" + p.exprList(n.Arguments) + ")", parser.PREC_CALL
+	case *ast.IndexExpression:
+		return p.expr(n.Left, parser.PREC_CALL) + "[" + p.expr(n.Index, parser.PREC_LOWEST) + "]", parser.PREC_CALL
+	case *ast.DotExpression:
+		return p.expr(n.Left, parser.PREC_CALL) + "." + n.Field, parser.PREC_CALL
+	case *ast.SafeAccessExpression:
+		return p.expr(n.Left, parser.PREC_CALL) + "?." + n.Field, parser.PREC_CALL
+	case *ast.RangeExpression:
+		s := p.expr(n.Start, parser.PREC_RANGE) + ".." + p.expr(n.End, parser.PREC_RANGE+1)
+		if n.Step != nil {
+			s += " step " + p.expr(n.Step, parser.PREC_RANGE+1)
+		}
+		return s, parser.PREC_RANGE
+	case *ast.CoalesceExpression:
+		return p.expr(n.Left, parser.PREC_COALESCE) + " ?? " + p.expr(n.Right, parser.PREC_COALESCE+1), parser.PREC_COALESCE
+	case *ast.PipelineExpression:
+		return p.expr(n.Left, parser.PREC_PIPELINE) + " |> " + p.expr(n.Right, parser.PREC_CALL), parser.PREC_PIPELINE
+	}
+	return "", precAtom
+}
+
+func (p *printer) exprs(list []ast.Expression) []string {
+	out := make([]string, len(list))
+	for i, e := range list {
+		out[i] = p.expr(e, parser.PREC_LOWEST)
+	}
+	return out
+}
+
+func (p *printer) exprList(list []ast.Expression) string {
+	return strings.Join(p.exprs(list), ", ")
+}
+
+func (p *printer) entries(m *ast.MapLiteral) []string {
+	out := make([]string, len(m.Keys))
+	for i := range m.Keys {
+		out[i] = p.expr(m.Keys[i], parser.PREC_LOWEST) + ": " + p.expr(m.Values[i], parser.PREC_LOWEST)
+	}
+	return out
+}
+
+// blockText prints a block inside an expression, such as the body of a
+// function literal. Its lines are indented one level past the statement
+// being printed.
+func (p *printer) blockText(b *ast.BlockStatement) string {
+	if inline, ok := p.inlineBlock(b); ok {
+		return inline
+	}
+	saved, savedStart := p.buf, p.blockStart
+	p.buf = &strings.Builder{}
+	p.body(b)
+	text := "{\n" + p.buf.String() + p.ind() + "}"
+	p.buf, p.blockStart = saved, savedStart
+	return text
+}
+
+func quote(s string) string {
+	return `"` + s + `"`
+}
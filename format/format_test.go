@@ -0,0 +1,92 @@
+package format
+
+import "testing"
+
+func TestSource(t *testing.T) {
+	input := `// header
+
+
+let   x=(1+2)*3   // trailing
+let long = [1,2,3,4,5,6,7,8,9,10] |> filter(fn(x) => x % 2 == 0) |> map(fn(x) => x * x)
+fn f(a,b) {
+  let z = -(a - b) - (a - b) // diff
+  let ok = a ?? (b ?? 0)    // right-nested
+
+  match z {
+      1 => "one"
+      _ => { print(z) }
+      // fallthrough
+  }
+}
+let sq = fn(x) { return x * x }`
+
+	expected := `// header
+
+let x = (1 + 2) * 3 // trailing
+let long = [1, 2, 3, 4, 5, 6, 7, 8, 9, 10]
+    |> filter(fn(x) => x % 2 == 0)
+    |> map(fn(x) => x * x)
+fn f(a, b) {
+    let z = -(a - b) - (a - b) // diff
+    let ok = a ?? (b ?? 0)     // right-nested
+
+    match z {
+        1 => "one"
+        _ => { print(z) }
+        // fallthrough
+    }
+}
+let sq = fn(x) { return x * x }
+`
+	got, diags := Source(input, "t.glace")
+	if len(diags) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if got != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+	if again, _ := Source(got, "t.glace"); again != got {
+		t.Errorf("formatting is not idempotent:\n%s", again)
+	}
+}
+
+func TestCommentsInsideLiterals(t *testing.T) {
+	input := `let xs = [
+ 1, // one
+ 2,
+ // before three
+ 3
+]
+let m = { // config
+  "a": [1, // inner
+    2],
+  "b": 2, // last
+  // end of map
+}
+`
+	expected := `let xs = [
+    1, // one
+    2,
+    // before three
+    3
+]
+let m = { // config
+    "a": [
+        1, // inner
+        2
+    ],
+    "b": 2, // last
+    // end of map
+}
+`
+	got, diags := Source(input, "t.glace")
+	if len(diags) > 0 {
+		t.Fatalf("unexpected diagnostics: %v", diags)
+	}
+	if got != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+	if again, _ := Source(got, "t.glace"); again != got {
+		t.Errorf("formatting is not idempotent:\n%s", again)
+	}
+}
@@ -0,0 +1,158 @@
+// Package glace embeds the Glace interpreter in Go programs.
+//
+//	interp := glace.New(&glace.Options{Stdout: &buf})
+//	if err := interp.Exec(`fn double(x) => x * 2`); err != nil {
+//		return err
+//	}
+//	v, err := interp.Call("double", evaluator.NewInt(21))
+//
+// Each Interpreter owns its globals, builtins and I/O streams, so any
+// number of them can run in one process. An Interpreter is not safe for
+// concurrent use.
+package glace
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/parser"
+)
+
+// Options configures a new Interpreter. The zero value is usable.
+type Options struct {
+	// Stdin is read by input(). Nil means os.Stdin.
+	Stdin io.Reader
+	// Stdout is written by print(). Nil means os.Stdout.
+	Stdout io.Writer
+	// Stderr receives diagnostics written while running, such as trace
+	// output. Nil means os.Stderr.
+	Stderr io.Writer
+	// Trace, when set, logs statements and calls to Stderr as they run.
+	Trace *evaluator.TraceOptions
+	// File names the source in positions and diagnostics. It defaults
+	// to "<input>".
+	File string
+}
+
+// Interpreter runs Glace code against a persistent global environment.
+type Interpreter struct {
+	env  *evaluator.Environment
+	file string
+}
+
+// SyntaxError is returned when source fails to lex or parse. Nothing in
+// the source is run.
+type SyntaxError struct {
+	Diagnostics []*diag.Diagnostic
+}
+
+func (e *SyntaxError) Error() string {
+	msgs := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		msgs[i] = d.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// New creates an Interpreter with the builtins registered. opts may be nil.
+func New(opts *Options) *Interpreter {
+	if opts == nil {
+		opts = &Options{}
+	}
+	env := evaluator.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	evaluator.RegisterHOBuiltins(env)
+
+	rt := env.Runtime()
+	if opts.Stdin != nil {
+		rt.SetStdin(opts.Stdin)
+	}
+	if opts.Stdout != nil {
+		rt.SetStdout(opts.Stdout)
+	}
+	if opts.Stderr != nil {
+		rt.SetStderr(opts.Stderr)
+	}
+	if opts.Trace != nil {
+		rt.Trace(nil, *opts.Trace)
+	}
+
+	file := opts.File
+	if file == "" {
+		file = "<input>"
+	}
+	return &Interpreter{env: env, file: file}
+}
+
+// Exec parses and runs src as a program. Its top-level definitions stay
+// in the interpreter's globals for later calls. Errors are a *SyntaxError
+// or an *evaluator.RuntimeError.
+func (in *Interpreter) Exec(src string) error {
+	program, err := in.parse(src)
+	if err != nil {
+		return err
+	}
+	_, err = evaluator.Eval(program, in.env)
+	return err
+}
+
+// EvalExpr evaluates src, which must be a single expression, and returns
+// its value.
+func (in *Interpreter) EvalExpr(src string) (evaluator.Value, error) {
+	program, err := in.parse(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(program.Statements) != 1 {
+		return nil, fmt.Errorf("expected a single expression, got %d statements", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		return nil, fmt.Errorf("expected an expression, got %s", program.Statements[0])
+	}
+	return evaluator.Eval(stmt.Expression, in.env)
+}
+
+// Call calls the global function name with args and returns its result.
+func (in *Interpreter) Call(name string, args ...evaluator.Value) (evaluator.Value, error) {
+	fn, ok := in.env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("undefined function '%s'", name)
+	}
+	return evaluator.CallFunction(fn, args...)
+}
+
+// SetGlobal binds name to v in the global scope, replacing any existing
+// binding. Scripts see it as an immutable variable.
+func (in *Interpreter) SetGlobal(name string, v evaluator.Value) {
+	in.env.Bind(name, v, false)
+}
+
+// RegisterFunc exposes the Go function fn to scripts as the global name.
+// See evaluator.RegisterFunc for how arguments and results convert.
+func (in *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	return evaluator.RegisterFunc(in.env, name, fn)
+}
+
+// Global returns the value of the global name.
+func (in *Interpreter) Global(name string) (evaluator.Value, bool) {
+	return in.env.Get(name)
+}
+
+// Env returns the global environment, for registering builtins or
+// attaching evaluation hooks through its Runtime.
+func (in *Interpreter) Env() *evaluator.Environment {
+	return in.env
+}
+
+func (in *Interpreter) parse(src string) (*ast.Program, error) {
+	program, diags := parser.ParseSource(src, in.file)
+	if len(diags) > 0 {
+		return nil, &SyntaxError{Diagnostics: diags}
+	}
+	return program, nil
+}
@@ -0,0 +1,121 @@
+package glace
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/evaluator"
+)
+
+func TestInterpreter(t *testing.T) {
+	var out1, out2 strings.Builder
+	a := New(&Options{Stdout: &out1, Stdin: strings.NewReader("Ada\n")})
+	b := New(&Options{Stdout: &out2})
+
+	a.SetGlobal("greeting", evaluator.NewString("hello"))
+	if err := a.Exec(`fn greet() {
+    let name = input("name? ")
+    print(greeting + ", " + name)
+    return len(name)
+}`); err != nil {
+		t.Fatalf("Exec: %s", err)
+	}
+	n, err := a.Call("greet")
+	if err != nil {
+		t.Fatalf("Call: %s", err)
+	}
+	if !n.Equals(evaluator.NewInt(3)) {
+		t.Errorf("greet() = %s, expected 3", n)
+	}
+	if out1.String() != "name? hello, Ada\n" {
+		t.Errorf("wrong output %q", out1.String())
+	}
+
+	if _, ok := b.Global("greet"); ok {
+		t.Errorf("globals leaked between interpreters")
+	}
+	if err := b.Exec(`print(1 + 1)`); err != nil || out2.String() != "2\n" {
+		t.Errorf("second interpreter: err=%v output=%q", err, out2.String())
+	}
+
+	v, err := a.EvalExpr(`greeting + "!"`)
+	if err != nil || v.String() != "hello!" {
+		t.Errorf("EvalExpr = %v, %v", v, err)
+	}
+
+	err = a.Exec("let x = (1 +\nlet y = 2 2")
+	syn, ok := err.(*SyntaxError)
+	if !ok || len(syn.Diagnostics) != 2 {
+		t.Errorf("expected a SyntaxError with 2 diagnostics, got %v", err)
+	}
+}
+
+type counter struct{ n int64 }
+
+func (c *counter) Type() string                  { return "Counter" }
+func (c *counter) String() string                { return "<Counter>" }
+func (c *counter) IsTruthy() bool                { return true }
+func (c *counter) Equals(o evaluator.Value) bool { return c == o }
+
+func (c *counter) GetField(name string) (evaluator.Value, error) {
+	if name != "n" {
+		return nil, fmt.Errorf("Counter has no field '%s'", name)
+	}
+	return evaluator.NewInt(c.n), nil
+}
+
+func (c *counter) SetField(name string, v evaluator.Value) error {
+	return evaluator.FromValue(v, &c.n)
+}
+
+func (c *counter) CallMethod(name string, args []evaluator.Value) (evaluator.Value, error) {
+	if name != "add" || len(args) != 1 {
+		return nil, fmt.Errorf("Counter has no method '%s/%d'", name, len(args))
+	}
+	var k int64
+	if err := evaluator.FromValue(args[0], &k); err != nil {
+		return nil, err
+	}
+	c.n += k
+	return c, nil
+}
+
+func TestHostObject(t *testing.T) {
+	c := &counter{}
+	in := New(nil)
+	in.SetGlobal("c", c)
+
+	if err := in.Exec("c.n = 5\nc.add(2).add(3)\n4 |> c.add()"); err != nil {
+		t.Fatalf("Exec: %s", err)
+	}
+	v, err := in.EvalExpr(`[c.n, type(c), c?.n]`)
+	if err != nil || v.String() != "[14, Counter, 14]" {
+		t.Errorf("got %v, %v", v, err)
+	}
+
+	_, err = in.EvalExpr(`c.missing`)
+	if re, ok := err.(*evaluator.RuntimeError); !ok || !strings.Contains(re.Message, "no field 'missing'") {
+		t.Errorf("expected a runtime error, got %v", err)
+	}
+}
+
+func TestStderrReceivesTrace(t *testing.T) {
+	var out, errOut strings.Builder
+	in := New(&Options{
+		Stdout: &out,
+		Stderr: &errOut,
+		File:   "t.glace",
+		Trace:  &evaluator.TraceOptions{Format: "json"},
+	})
+	if err := in.Exec("fn id(x) => x\nprint(id(1))"); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "1\n" {
+		t.Errorf("wrong output %q", out.String())
+	}
+	want := `{"event":"call","file":"t.glace","line":2,"column":9,"depth":0,"fn":"id","args":["1"]}`
+	if !strings.Contains(errOut.String(), want) || strings.Contains(out.String(), "event") {
+		t.Errorf("trace = %q, expected it on Stderr with %s", errOut.String(), want)
+	}
+}
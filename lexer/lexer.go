@@ -1,14 +1,23 @@
 package lexer
 
+import (
+	"fmt"
+
+	"github.com/glace-lang/glace/diag"
+)
+
 // Lexer performs lexical analysis on Glace source code.
 type Lexer struct {
-	source  string
-	file    string
-	tokens  []Token
-	start   int
-	current int
-	line    int
-	column  int
+	source      string
+	file        string
+	tokens      []Token
+	diagnostics []*diag.Diagnostic
+	comments    []Comment
+	start       int
+	current     int
+	line        int
+	column      int
+	startPos    Position // position of source[start]
 }
 
 // New creates a new Lexer for the given source code.
@@ -26,6 +35,7 @@ func New(source string, file string) *Lexer {
 func (l *Lexer) Tokenize() []Token {
 	for !l.isAtEnd() {
 		l.start = l.current
+		l.startPos = Position{File: l.file, Line: l.line, Column: l.column}
 		l.scanToken()
 	}
 
@@ -33,6 +43,18 @@ func (l *Lexer) Tokenize() []Token {
 	return l.tokens
 }
 
+// Comments returns the comments found by Tokenize, in source order.
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
+// Diagnostics returns the lexical errors found by Tokenize. Each one is
+// also present in the token stream as a TOKEN_ILLEGAL token, which the
+// parser skips without reporting again.
+func (l *Lexer) Diagnostics() []*diag.Diagnostic {
+	return l.diagnostics
+}
+
 func (l *Lexer) scanToken() {
 	ch := l.advance()
 
@@ -40,9 +62,9 @@ func (l *Lexer) scanToken() {
 	case ' ', '\t', '\r':
 		return
 	case '\n':
+		l.addToken(TOKEN_NEWLINE, "\n")
 		l.line++
 		l.column = 1
-		l.addToken(TOKEN_NEWLINE, "\n")
 	case '(': l.addToken(TOKEN_LPAREN, "(")
 	case ')': l.addToken(TOKEN_RPAREN, ")")
 	case '{': l.addToken(TOKEN_LBRACE, "{")
@@ -74,6 +96,12 @@ func (l *Lexer) scanToken() {
 			for l.peek() != '\n' && !l.isAtEnd() {
 				l.advance()
 			}
+			trailing := false
+			if n := len(l.tokens); n > 0 {
+				last := l.tokens[n-1]
+				trailing = last.Type != TOKEN_NEWLINE && last.Pos.Line == l.startPos.Line
+			}
+			l.comments = append(l.comments, Comment{Pos: l.startPos, Text: l.source[l.start:l.current], Trailing: trailing})
 		} else {
 			l.addToken(TOKEN_SLASH, "/")
 		}
@@ -107,7 +135,7 @@ func (l *Lexer) scanToken() {
 		if l.match('>') {
 			l.addToken(TOKEN_PIPE, "|>") // Pipeline operator 
 		} else {
-			l.addToken(TOKEN_ILLEGAL, "|")
+			l.illegal('|')
 		}
 	case '?':
 		if l.match('.') {
@@ -115,7 +143,7 @@ func (l *Lexer) scanToken() {
 		} else if l.match('?') {
 			l.addToken(TOKEN_COALESCE, "??") // Coalesce [cite: 402]
 		} else {
-			l.addToken(TOKEN_ILLEGAL, "?")
+			l.illegal('?')
 		}
 	case '"':
 		l.scanString()
@@ -126,7 +154,7 @@ func (l *Lexer) scanToken() {
 		} else if isAlpha(ch) {
 			l.scanIdentifier()
 		} else {
-			l.addToken(TOKEN_ILLEGAL, string(ch))
+			l.illegal(ch)
 		}
 	}
 }
@@ -176,6 +204,7 @@ func (l *Lexer) scanString() {
 	}
 
 	if l.isAtEnd() {
+		l.report(diag.CodeUnterminatedString, "unterminated string", l.startPos)
 		l.addToken(TOKEN_ILLEGAL, "unterminated string")
 		return
 	}
@@ -228,6 +257,24 @@ func (l *Lexer) addToken(tokenType TokenType, literal string) {
 	})
 }
 
+// illegal emits a TOKEN_ILLEGAL for a character that starts no token.
+func (l *Lexer) illegal(ch byte) {
+	l.report(diag.CodeIllegalCharacter, fmt.Sprintf("unexpected character %q", ch), l.startPos)
+	l.addToken(TOKEN_ILLEGAL, string(ch))
+}
+
+// report records a lexical error spanning from start to the current
+// position.
+func (l *Lexer) report(code, msg string, start Position) {
+	l.diagnostics = append(l.diagnostics, &diag.Diagnostic{
+		Start:    start,
+		End:      Position{File: l.file, Line: l.line, Column: l.column},
+		Code:     code,
+		Severity: diag.Error,
+		Message:  msg,
+	})
+}
+
 func isDigit(ch byte) bool { return ch >= '0' && ch <= '9' }
 func isAlpha(ch byte) bool { return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_' }
 func isAlphaNumeric(ch byte) bool { return isAlpha(ch) || isDigit(ch) }
\ No newline at end of file
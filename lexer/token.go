@@ -1,6 +1,11 @@
 package lexer
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"github.com/glace-lang/glace/diag"
+)
 
 // TokenType represents the type of a lexical token.
 type TokenType int
@@ -74,6 +79,12 @@ const (
 	TOKEN_TEST
 	TOKEN_STEP
 	TOKEN_IMPORT
+	TOKEN_BENCH
+	TOKEN_DESCRIBE
+	TOKEN_BEFORE_EACH
+	TOKEN_AFTER_EACH
+	TOKEN_BEFORE_ALL
+	TOKEN_AFTER_ALL
 )
 
 // tokenNames maps TokenType to a human-readable name.
@@ -135,6 +146,12 @@ var tokenNames = map[TokenType]string{
 	TOKEN_TEST:         "test",
 	TOKEN_STEP:         "step",
 	TOKEN_IMPORT:       "import",
+	TOKEN_BENCH:        "bench",
+	TOKEN_DESCRIBE:     "describe",
+	TOKEN_BEFORE_EACH:  "before_each",
+	TOKEN_AFTER_EACH:   "after_each",
+	TOKEN_BEFORE_ALL:   "before_all",
+	TOKEN_AFTER_ALL:    "after_all",
 }
 
 // Keywords maps keyword strings to their TokenType.
@@ -157,6 +174,13 @@ var Keywords = map[string]TokenType{
 	"test":     TOKEN_TEST,
 	"step":     TOKEN_STEP,
 	"import":   TOKEN_IMPORT,
+	"bench":    TOKEN_BENCH,
+	"describe": TOKEN_DESCRIBE,
+
+	"before_each": TOKEN_BEFORE_EACH,
+	"after_each":  TOKEN_AFTER_EACH,
+	"before_all":  TOKEN_BEFORE_ALL,
+	"after_all":   TOKEN_AFTER_ALL,
 }
 
 // LookupIdent returns the TokenType for an identifier string.
@@ -167,19 +191,9 @@ func LookupIdent(ident string) TokenType {
 	return TOKEN_IDENT
 }
 
-// Position represents a location in the source code.
-type Position struct {
-	File   string
-	Line   int
-	Column int
-}
-
-func (p Position) String() string {
-	if p.File != "" {
-		return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
-	}
-	return fmt.Sprintf("%d:%d", p.Line, p.Column)
-}
+// Position represents a location in the source code. It is defined in
+// package diag so diagnostics can refer to it without importing the lexer.
+type Position = diag.Position
 
 // Token represents a single lexical token.
 type Token struct {
@@ -188,10 +202,28 @@ type Token struct {
 	Pos     Position
 }
 
-func (t Token) String() string {
-	name, ok := tokenNames[t.Type]
+// Comment is a // comment. Comments are not tokens; the lexer records
+// them separately so tools such as the formatter can put them back.
+type Comment struct {
+	Pos      Position
+	Text     string // including the leading //
+	Trailing bool   // follows code on the same line
+}
+
+// IsDoc reports whether c is a /// doc comment. Four or more slashes make
+// an ordinary comment, so a line of slashes is not documentation.
+func (c Comment) IsDoc() bool {
+	return strings.HasPrefix(c.Text, "///") && !strings.HasPrefix(c.Text, "////")
+}
+
+func (t TokenType) String() string {
+	name, ok := tokenNames[t]
 	if !ok {
-		name = "UNKNOWN"
+		return "UNKNOWN"
 	}
-	return fmt.Sprintf("%s(%q at %s)", name, t.Literal, t.Pos)
+	return name
+}
+
+func (t Token) String() string {
+	return fmt.Sprintf("%s(%q at %s)", t.Type, t.Literal, t.Pos)
 }
\ No newline at end of file
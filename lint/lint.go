@@ -0,0 +1,436 @@
+// Package lint finds likely mistakes in Glace programs without running
+// them. It walks the AST with the same scoping rules as the evaluator:
+// functions, loop bodies and test blocks open a scope, while if and match
+// bodies define into the enclosing one.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/lexer"
+)
+
+type symbolKind int
+
+const (
+	symLet symbolKind = iota
+	symMut
+	symFn
+	symParam
+	symIterator
+	symMatch
+	symBuiltin
+)
+
+var kindNames = [...]string{"let", "mut", "fn", "parameter", "loop variable", "match binding", "builtin"}
+
+// symbol is one declared name.
+type symbol struct {
+	name string
+	kind symbolKind
+	pos  lexer.Position
+	used bool
+
+	// Arity bounds for functions; max < 0 means no upper bound.
+	callable bool
+	min, max int
+
+	binding *Binding // set when resolving
+}
+
+// scope mirrors an evaluator Environment.
+type scope struct {
+	parent  *scope
+	names   map[string]*symbol
+	order   []*symbol
+	bodies  []func() // function bodies, checked once the scope is complete
+	isLocal bool     // unused variables are reported (not at top level)
+	extent  Range    // the block the scope covers; zero at top level
+}
+
+type checker struct {
+	diags    []*diag.Diagnostic
+	universe *scope
+
+	resolve  bool // record bindings for Resolve
+	bindings []*Binding
+}
+
+// Check reports problems in prog: undefined names, assignments to
+// immutable bindings and wrong argument counts as errors; unreachable
+// code, unused variables and parameters, and names that shadow builtins
+// as warnings.
+//
+// Function bodies are checked once the scope they are declared in is
+// complete, since a function may refer to names defined after it as long
+// as it is called later.
+func Check(prog *ast.Program) []*diag.Diagnostic {
+	c := &checker{universe: builtins()}
+	top := c.open(c.universe, false, Range{})
+	c.block(prog.Statements, top)
+	c.close(top)
+	sort.SliceStable(c.diags, func(i, j int) bool { return before(c.diags[i].Start, c.diags[j].Start) })
+	return c.diags
+}
+
+// builtins returns the scope of the names RegisterBuiltins and
+// RegisterHOBuiltins define.
+func builtins() *scope {
+	env := evaluator.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	evaluator.RegisterHOBuiltins(env)
+	s := &scope{names: map[string]*symbol{}}
+	for _, b := range env.Bindings() {
+		sym := &symbol{name: b.Name, kind: symBuiltin, used: true}
+		if fn, ok := b.Value.(*evaluator.BuiltinFn); ok && fn.Signature != "" {
+			sym.callable = true
+			sym.min, sym.max = signatureArity(fn.Signature)
+		}
+		s.names[b.Name] = sym
+	}
+	return s
+}
+
+// signatureArity reads the argument bounds from a builtin signature such
+// as "sort(arr, fn?)" or "print(args...)".
+func signatureArity(sig string) (min, max int) {
+	open, close := strings.Index(sig, "("), strings.LastIndex(sig, ")")
+	if open < 0 || close < open {
+		return 0, -1
+	}
+	params := strings.TrimSpace(sig[open+1 : close])
+	if params == "" {
+		return 0, 0
+	}
+	for _, p := range strings.Split(params, ",") {
+		p = strings.TrimSpace(p)
+		switch {
+		case strings.HasSuffix(p, "..."):
+			return min, -1
+		case strings.HasSuffix(p, "?"):
+			max++
+		default:
+			min++
+			max++
+		}
+	}
+	return min, max
+}
+
+// ---------------------------------------------------------------------------
+// Scopes
+// ---------------------------------------------------------------------------
+
+func (c *checker) open(parent *scope, local bool, extent Range) *scope {
+	return &scope{parent: parent, names: map[string]*symbol{}, isLocal: local, extent: extent}
+}
+
+// close checks the function bodies declared in s, then reports its
+// unused variables and parameters.
+func (c *checker) close(s *scope) {
+	for len(s.bodies) > 0 {
+		body := s.bodies[0]
+		s.bodies = s.bodies[1:]
+		body()
+	}
+	for _, sym := range s.order {
+		if sym.used || strings.HasPrefix(sym.name, "_") {
+			continue
+		}
+		switch {
+		case sym.kind == symParam:
+			c.report(diag.Warning, diag.CodeUnusedParameter, sym.pos, sym.pos, "parameter '%s' is never used", sym.name)
+		case s.isLocal && (sym.kind == symLet || sym.kind == symMut):
+			c.report(diag.Warning, diag.CodeUnusedVariable, sym.pos, sym.pos, "variable '%s' is never used", sym.name)
+		}
+	}
+}
+
+func (c *checker) declare(s *scope, sym *symbol) {
+	if b, ok := c.universe.names[sym.name]; ok && b.kind == symBuiltin {
+		c.report(diag.Warning, diag.CodeShadowsBuiltin, sym.pos, sym.pos, "%s '%s' shadows a builtin", kindNames[sym.kind], sym.name)
+	}
+	if _, exists := s.names[sym.name]; !exists {
+		s.order = append(s.order, sym)
+	}
+	s.names[sym.name] = sym
+	if c.resolve {
+		sym.binding = &Binding{Name: sym.name, Kind: kindNames[sym.kind], Pos: sym.pos, Scope: s.extent}
+		c.bindings = append(c.bindings, sym.binding)
+	}
+}
+
+func (s *scope) lookup(name string) *symbol {
+	for ; s != nil; s = s.parent {
+		if sym, ok := s.names[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// function queues a function body to be checked when s closes, in a new
+// scope holding params.
+func (c *checker) function(s *scope, params []string, pos lexer.Position, body *ast.BlockStatement) {
+	s.bodies = append(s.bodies, func() {
+		fs := c.open(s, true, Range{body.Pos, body.End})
+		for _, p := range params {
+			c.declare(fs, &symbol{name: p, kind: symParam, pos: pos})
+		}
+		c.block(body.Statements, fs)
+		c.close(fs)
+	})
+}
+
+// ---------------------------------------------------------------------------
+// Statements
+// ---------------------------------------------------------------------------
+
+// block checks a statement list in scope s, reporting the first statement
+// after a return, break or continue as unreachable.
+func (c *checker) block(stmts []ast.Statement, s *scope) {
+	terminated := false
+	for _, stmt := range stmts {
+		if terminated {
+			c.report(diag.Warning, diag.CodeUnreachable, stmt.TokenPos(), stmt.TokenPos(), "unreachable code")
+			terminated = false
+		}
+		c.statement(stmt, s)
+		switch stmt.(type) {
+		case *ast.ReturnStatement, *ast.BreakStatement, *ast.ContinueStatement:
+			terminated = true
+		}
+	}
+}
+
+// nested checks a block that runs in a scope of its own.
+func (c *checker) nested(b *ast.BlockStatement, parent *scope, declare ...*symbol) {
+	s := c.open(parent, true, Range{b.Pos, b.End})
+	for _, sym := range declare {
+		c.declare(s, sym)
+	}
+	c.block(b.Statements, s)
+	c.close(s)
+}
+
+func (c *checker) statement(stmt ast.Statement, s *scope) {
+	switch n := stmt.(type) {
+	case *ast.LetStatement:
+		c.expr(n.Value, s)
+		c.declare(s, &symbol{name: n.Name, kind: symLet, pos: n.Pos})
+		if c.resolve {
+			s.names[n.Name].binding.Doc = n.Doc
+			if fn, ok := n.Value.(*ast.FnLiteral); ok {
+				s.names[n.Name].binding.Params = fn.Params
+			}
+		}
+	case *ast.MutStatement:
+		c.expr(n.Value, s)
+		c.declare(s, &symbol{name: n.Name, kind: symMut, pos: n.Pos})
+	case *ast.AssignStatement:
+		c.expr(n.Value, s)
+		sym := s.lookup(n.Name)
+		if sym != nil {
+			sym.ref(n.Pos)
+		}
+		switch {
+		case sym == nil:
+			c.report(diag.Error, diag.CodeUndefinedVariable, n.Pos, nameEnd(n.Pos, n.Name), "undefined variable '%s'", n.Name)
+		case sym.kind != symMut:
+			d := c.report(diag.Error, diag.CodeImmutable, n.Pos, nameEnd(n.Pos, n.Name), "cannot assign to immutable variable '%s'", n.Name)
+			if sym.kind == symBuiltin {
+				d.Notes = append(d.Notes, fmt.Sprintf("'%s' is a builtin", n.Name))
+			} else {
+				d.Notes = append(d.Notes, fmt.Sprintf("'%s' is declared as a %s at %s", n.Name, kindNames[sym.kind], sym.pos))
+			}
+		}
+	case *ast.IndexAssignStatement:
+		c.expr(n.Left, s)
+		c.expr(n.Index, s)
+		c.expr(n.Value, s)
+	case *ast.FieldAssignStatement:
+		c.expr(n.Left, s)
+		c.expr(n.Value, s)
+	case *ast.ExpressionStatement:
+		c.expr(n.Expression, s)
+	case *ast.ReturnStatement:
+		for _, v := range n.Values {
+			c.expr(v, s)
+		}
+	case *ast.IfStatement:
+		c.expr(n.Condition, s)
+		c.block(n.Consequence.Statements, s)
+		for _, elif := range n.ElifClauses {
+			c.expr(elif.Condition, s)
+			c.block(elif.Consequence.Statements, s)
+		}
+		if n.Alternative != nil {
+			c.block(n.Alternative.Statements, s)
+		}
+	case *ast.LoopStatement:
+		if n.Iterator != "" {
+			c.expr(n.Iterable, s)
+			c.nested(n.Body, s, &symbol{name: n.Iterator, kind: symIterator, pos: n.Pos, used: true})
+			return
+		}
+		if n.Condition != nil {
+			c.expr(n.Condition, s)
+		}
+		c.nested(n.Body, s)
+	case *ast.FnDeclaration:
+		c.declare(s, &symbol{name: n.Name, kind: symFn, pos: n.Pos, used: true,
+			callable: true, min: len(n.Params), max: len(n.Params)})
+		if c.resolve {
+			s.names[n.Name].binding.Params = n.Params
+			s.names[n.Name].binding.Doc = n.Doc
+		}
+		c.function(s, n.Params, n.Pos, n.Body)
+	case *ast.MatchStatement:
+		c.expr(n.Subject, s)
+		for _, arm := range n.Arms {
+			if id, ok := arm.Pattern.(*ast.Identifier); ok {
+				c.declare(s, &symbol{name: id.Name, kind: symMatch, pos: id.Pos, used: true})
+			} else {
+				c.expr(arm.Pattern, s)
+			}
+			if arm.Guard != nil {
+				c.expr(arm.Guard, s)
+			}
+			c.block(arm.Body.Statements, s)
+		}
+	case *ast.TestBlock:
+		c.nested(n.Body, s)
+	case *ast.BenchBlock:
+		c.nested(n.Body, s)
+	case *ast.DescribeBlock:
+		c.nested(n.Body, s)
+	case *ast.HookBlock:
+		c.nested(n.Body, s)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Expressions
+// ---------------------------------------------------------------------------
+
+func (c *checker) expr(e ast.Expression, s *scope) {
+	switch n := e.(type) {
+	case *ast.Identifier:
+		c.use(n, s)
+	case *ast.StringInterpolation:
+		for _, p := range n.Parts {
+			c.expr(p, s)
+		}
+	case *ast.BinaryExpression:
+		c.expr(n.Left, s)
+		c.expr(n.Right, s)
+	case *ast.UnaryExpression:
+		c.expr(n.Operand, s)
+	case *ast.CallExpression:
+		c.call(n, 0, s)
+	case *ast.PipelineExpression:
+		c.expr(n.Left, s)
+		c.call(n.Right, 1, s)
+	case *ast.IndexExpression:
+		c.expr(n.Left, s)
+		c.expr(n.Index, s)
+	case *ast.DotExpression:
+		c.expr(n.Left, s)
+	case *ast.SafeAccessExpression:
+		c.expr(n.Left, s)
+	case *ast.ArrayLiteral:
+		for _, el := range n.Elements {
+			c.expr(el, s)
+		}
+	case *ast.MapLiteral:
+		for i := range n.Keys {
+			c.expr(n.Keys[i], s)
+			c.expr(n.Values[i], s)
+		}
+	case *ast.FnLiteral:
+		c.function(s, n.Params, n.Pos, n.Body)
+	case *ast.RangeExpression:
+		c.expr(n.Start, s)
+		c.expr(n.End, s)
+		if n.Step != nil {
+			c.expr(n.Step, s)
+		}
+	case *ast.CoalesceExpression:
+		c.expr(n.Left, s)
+		c.expr(n.Right, s)
+	}
+}
+
+// use resolves a read of id and marks its binding used.
+func (c *checker) use(id *ast.Identifier, s *scope) *symbol {
+	sym := s.lookup(id.Name)
+	if sym == nil {
+		c.report(diag.Error, diag.CodeUndefinedVariable, id.Pos, nameEnd(id.Pos, id.Name), "undefined variable '%s'", id.Name)
+		return nil
+	}
+	sym.used = true
+	sym.ref(id.Pos)
+	return sym
+}
+
+// ref records a use of sym at pos when resolving.
+func (sym *symbol) ref(pos lexer.Position) {
+	if sym.binding != nil {
+		sym.binding.Refs = append(sym.binding.Refs, pos)
+	}
+}
+
+// call checks a call with extra leading arguments supplied by a pipeline.
+func (c *checker) call(n *ast.CallExpression, extra int, s *scope) {
+	var callee *symbol
+	if id, ok := n.Function.(*ast.Identifier); ok {
+		callee = c.use(id, s)
+	} else {
+		c.expr(n.Function, s)
+	}
+	for _, a := range n.Arguments {
+		c.expr(a, s)
+	}
+	if callee == nil || !callee.callable {
+		return
+	}
+	got := len(n.Arguments) + extra
+	if got >= callee.min && (callee.max < 0 || got <= callee.max) {
+		return
+	}
+	var want string
+	switch {
+	case callee.max < 0:
+		want = fmt.Sprintf("at least %d", callee.min)
+	case callee.min == callee.max:
+		want = fmt.Sprintf("%d", callee.min)
+	default:
+		want = fmt.Sprintf("%d to %d", callee.min, callee.max)
+	}
+	pos := n.Function.TokenPos()
+	c.report(diag.Error, diag.CodeArity, pos, nameEnd(pos, callee.name), "%s() takes %s %s, got %d", callee.name, want, plural(want), got)
+}
+
+func plural(n string) string {
+	if n == "1" {
+		return "argument"
+	}
+	return "arguments"
+}
+
+func (c *checker) report(sev diag.Severity, code string, start, end lexer.Position, format string, args ...interface{}) *diag.Diagnostic {
+	d := &diag.Diagnostic{Start: start, End: end, Code: code, Severity: sev, Message: fmt.Sprintf(format, args...)}
+	c.diags = append(c.diags, d)
+	return d
+}
+
+// nameEnd returns the position just past name starting at pos.
+func nameEnd(pos lexer.Position, name string) lexer.Position {
+	pos.Column += len(name)
+	return pos
+}
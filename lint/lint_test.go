@@ -0,0 +1,58 @@
+package lint_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/lint"
+	"github.com/glace-lang/glace/parser"
+)
+
+func TestCheck(t *testing.T) {
+	src := `let len = 3
+fn f(a, b) {
+    let unused = 1
+    let _ignored = 2
+    return a
+    print("x")
+}
+f(1)
+x = 4
+len = 2
+[1, 2] |> push(3, 4)
+fn g() {
+    return later + count
+}
+mut count = 0
+let later = g()
+loop i in 0..3 {
+    count = count + i
+}
+sort([3, 1])
+print(nope)
+`
+	want := []string{
+		"1:1 W0304 let 'len' shadows a builtin",
+		"2:1 W0303 parameter 'b' is never used",
+		"3:5 W0302 variable 'unused' is never used",
+		"6:5 W0301 unreachable code",
+		"8:1 E0206 f() takes 2 arguments, got 1",
+		"9:1 E0201 undefined variable 'x'",
+		"10:1 E0202 cannot assign to immutable variable 'len'",
+		"11:11 E0206 push() takes 2 arguments, got 3",
+		"21:7 E0201 undefined variable 'nope'",
+	}
+
+	prog, diags := parser.ParseSource(src, "t.glace")
+	if len(diags) > 0 {
+		t.Fatalf("parse: %v", diags)
+	}
+	var got []string
+	for _, d := range lint.Check(prog) {
+		got = append(got, fmt.Sprintf("%d:%d %s %s", d.Start.Line, d.Start.Column, d.Code, d.Message))
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,64 @@
+package lint
+
+import (
+	"sort"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/lexer"
+)
+
+// Range is a span of source, from a block's opening '{' to its closing
+// '}'. The body of a => form has no closing brace; its End is zero.
+type Range struct {
+	Start, End lexer.Position
+}
+
+// Contains reports whether pos falls within r. The zero Range contains
+// every position, and a Range with a zero End runs to the end of its
+// first line.
+func (r Range) Contains(pos lexer.Position) bool {
+	switch {
+	case r == (Range{}):
+		return true
+	case r.End == (lexer.Position{}):
+		return pos.Line == r.Start.Line && !before(pos, r.Start)
+	}
+	return !before(pos, r.Start) && !before(r.End, pos)
+}
+
+// Binding is a name a program declares, with every place it is used.
+type Binding struct {
+	Name string
+	// Kind is "let", "mut", "fn", "parameter", "loop variable" or
+	// "match binding".
+	Kind string
+	// Pos is the start of the declaring statement, fn literal or loop, or
+	// the pattern of a match binding; the name itself follows it.
+	Pos    lexer.Position
+	Params []string         // for fns, and lets bound to a fn literal
+	Doc    string           // /// comments on a fn or let
+	Refs   []lexer.Position // reads and assignments, in source order
+	Scope  Range            // the block the binding is visible in
+}
+
+// Resolve returns the bindings prog declares, in the order the checker
+// meets them, with each read and assignment of a name attributed to the
+// binding it refers to. Programs with syntax errors resolve as far as
+// their Bad* nodes allow.
+func Resolve(prog *ast.Program) []*Binding {
+	c := &checker{universe: builtins(), resolve: true}
+	top := c.open(c.universe, false, Range{})
+	c.block(prog.Statements, top)
+	c.close(top)
+	for _, b := range c.bindings {
+		sort.Slice(b.Refs, func(i, j int) bool { return before(b.Refs[i], b.Refs[j]) })
+	}
+	return c.bindings
+}
+
+func before(a, b lexer.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
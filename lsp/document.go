@@ -0,0 +1,296 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/lexer"
+	"github.com/glace-lang/glace/lint"
+	"github.com/glace-lang/glace/parser"
+)
+
+// document is an open file and everything known about its current text.
+type document struct {
+	uri      string
+	lines    []string
+	prog     *ast.Program
+	diags    []*diag.Diagnostic
+	tokens   []lexer.Token
+	bindings []*lint.Binding
+	names    map[*lint.Binding]lexer.Position // where each binding's name is written
+}
+
+// analyze parses text and resolves its names. Lint warnings are only
+// added when the text parses cleanly, as `glace check` does.
+func analyze(uri, text string) *document {
+	d := &document{uri: uri, lines: strings.Split(text, "\n"), names: map[*lint.Binding]lexer.Position{}}
+	d.prog, d.diags = parser.ParseSource(text, uri)
+	if len(d.diags) == 0 {
+		d.diags = lint.Check(d.prog)
+	}
+	d.tokens = lexer.New(text, uri).Tokenize()
+	d.bindings = lint.Resolve(d.prog)
+	for _, b := range d.bindings {
+		d.names[b] = d.namePos(b)
+	}
+	return d
+}
+
+// namePos finds the name of b in the token stream: the first identifier
+// spelled like it at or after the declaration's position.
+func (d *document) namePos(b *lint.Binding) lexer.Position {
+	for _, t := range d.tokens {
+		if t.Type == lexer.TOKEN_IDENT && t.Literal == b.Name && !before(t.Pos, b.Pos) {
+			return t.Pos
+		}
+	}
+	return b.Pos
+}
+
+// identAt returns the identifier token under pos, or nil.
+func (d *document) identAt(pos lexer.Position) *lexer.Token {
+	for i, t := range d.tokens {
+		if t.Type == lexer.TOKEN_IDENT && t.Pos.Line == pos.Line &&
+			pos.Column >= t.Pos.Column && pos.Column <= t.Pos.Column+len(t.Literal) {
+			return &d.tokens[i]
+		}
+	}
+	return nil
+}
+
+// bindingAt returns the binding whose name or reference is under pos.
+func (d *document) bindingAt(pos lexer.Position) (*lint.Binding, *lexer.Token) {
+	t := d.identAt(pos)
+	if t == nil {
+		return nil, nil
+	}
+	for _, b := range d.bindings {
+		if d.names[b] == t.Pos {
+			return b, t
+		}
+		for _, r := range b.Refs {
+			if r == t.Pos {
+				return b, t
+			}
+		}
+	}
+	return nil, t
+}
+
+// ---------------------------------------------------------------------------
+// Features
+// ---------------------------------------------------------------------------
+
+func (d *document) diagnostics() []lspDiagnostic {
+	out := []lspDiagnostic{}
+	for _, dg := range d.diags {
+		r := lspRange{d.position(dg.Start), d.position(dg.End)}
+		if r.End == r.Start {
+			r.End.Character++
+		}
+		msg := dg.Message
+		for _, n := range dg.Notes {
+			msg += "\nnote: " + n
+		}
+		out = append(out, lspDiagnostic{
+			Range:    r,
+			Severity: int(dg.Severity) + 1,
+			Code:     dg.Code,
+			Source:   "glace",
+			Message:  msg,
+		})
+	}
+	return out
+}
+
+func (d *document) hover(pos lexer.Position, builtins map[string]*evaluator.BuiltinFn) *hover {
+	b, t := d.bindingAt(pos)
+	if t == nil {
+		return nil
+	}
+	var text string
+	switch {
+	case b != nil && b.Kind == "fn":
+		text = fence(fmt.Sprintf("fn %s(%s)", b.Name, strings.Join(b.Params, ", ")))
+	case b != nil && b.Params != nil:
+		text = fence(fmt.Sprintf("%s %s = fn(%s)", b.Kind, b.Name, strings.Join(b.Params, ", ")))
+	case b != nil && (b.Kind == "let" || b.Kind == "mut"):
+		text = fence(b.Kind + " " + b.Name)
+	case b != nil:
+		text = fence(fmt.Sprintf("(%s) %s", b.Kind, b.Name))
+	case builtins[t.Literal] != nil:
+		fn := builtins[t.Literal]
+		text = fence(fn.Signature) + "\n" + fn.Doc
+	default:
+		return nil
+	}
+	if b != nil && b.Doc != "" {
+		text += "\n" + b.Doc
+	}
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: text},
+		Range:    d.nameRange(t.Pos, t.Literal),
+	}
+}
+
+func fence(code string) string {
+	return "```glace\n" + code + "\n```\n"
+}
+
+func (d *document) definition(pos lexer.Position) []location {
+	b, _ := d.bindingAt(pos)
+	if b == nil {
+		return []location{}
+	}
+	return []location{{URI: d.uri, Range: d.nameRange(d.names[b], b.Name)}}
+}
+
+func (d *document) references(pos lexer.Position, includeDecl bool) []location {
+	b, _ := d.bindingAt(pos)
+	if b == nil {
+		return []location{}
+	}
+	out := []location{}
+	if includeDecl {
+		out = append(out, location{URI: d.uri, Range: d.nameRange(d.names[b], b.Name)})
+	}
+	for _, r := range b.Refs {
+		out = append(out, location{URI: d.uri, Range: d.nameRange(r, b.Name)})
+	}
+	return out
+}
+
+// symbols lists the top-level lets, muts and fns.
+func (d *document) symbols() []documentSymbol {
+	out := []documentSymbol{}
+	for _, b := range d.bindings {
+		if b.Scope != (lint.Range{}) {
+			continue
+		}
+		var kind int
+		switch b.Kind {
+		case "fn":
+			kind = symbolFunction
+		case "let":
+			kind = symbolConstant
+			if b.Params != nil {
+				kind = symbolFunction
+			}
+		case "mut":
+			kind = symbolVariable
+		default:
+			continue
+		}
+		name := d.nameRange(d.names[b], b.Name)
+		out = append(out, documentSymbol{
+			Name:           b.Name,
+			Detail:         b.Kind,
+			Kind:           kind,
+			Range:          lspRange{d.position(b.Pos), name.End},
+			SelectionRange: name,
+		})
+	}
+	return out
+}
+
+// completions offers the names in scope at pos, then builtins and
+// keywords. Clients filter by the word being typed.
+func (d *document) completions(pos lexer.Position, builtins map[string]*evaluator.BuiltinFn) []completionItem {
+	seen := map[string]bool{}
+	out := []completionItem{}
+	for _, b := range d.bindings {
+		if seen[b.Name] || !b.Scope.Contains(pos) {
+			continue
+		}
+		// Functions and parameters are visible throughout their scope;
+		// other names only after they are declared.
+		if b.Kind != "fn" && b.Kind != "parameter" && !before(d.names[b], pos) {
+			continue
+		}
+		seen[b.Name] = true
+		item := completionItem{Label: b.Name, Kind: completionVariable, Detail: b.Kind}
+		if b.Kind == "fn" || b.Params != nil {
+			item.Kind = completionFunction
+			item.Detail = fmt.Sprintf("fn %s(%s)", b.Name, strings.Join(b.Params, ", "))
+		}
+		out = append(out, item)
+	}
+
+	names := make([]string, 0, len(builtins))
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		fn := builtins[name]
+		out = append(out, completionItem{Label: name, Kind: completionFunction, Detail: fn.Signature, Documentation: fn.Doc})
+	}
+
+	keywords := make([]string, 0, len(lexer.Keywords))
+	for kw := range lexer.Keywords {
+		keywords = append(keywords, kw)
+	}
+	sort.Strings(keywords)
+	for _, kw := range keywords {
+		out = append(out, completionItem{Label: kw, Kind: completionKeyword})
+	}
+	return out
+}
+
+// ---------------------------------------------------------------------------
+// Positions
+// ---------------------------------------------------------------------------
+
+// LSP positions are zero-based and count UTF-16 code units; Glace
+// positions are one-based and count bytes.
+
+func (d *document) position(p lexer.Position) position {
+	if p.Line < 1 {
+		return position{}
+	}
+	col := p.Column - 1
+	if p.Line <= len(d.lines) {
+		line := d.lines[p.Line-1]
+		if col > len(line) {
+			col = len(line)
+		}
+		col = len(utf16.Encode([]rune(line[:col])))
+	}
+	return position{Line: p.Line - 1, Character: col}
+}
+
+func (d *document) pos(p position) lexer.Position {
+	col := p.Character
+	if p.Line < len(d.lines) {
+		line, units := d.lines[p.Line], 0
+		col = 0
+		for col < len(line) && units < p.Character {
+			r, size := utf8.DecodeRuneInString(line[col:])
+			units += len(utf16.Encode([]rune{r}))
+			col += size
+		}
+	}
+	return lexer.Position{File: d.uri, Line: p.Line + 1, Column: col + 1}
+}
+
+func (d *document) nameRange(p lexer.Position, name string) lspRange {
+	end := p
+	end.Column += len(name)
+	return lspRange{d.position(p), d.position(end)}
+}
+
+func before(a, b lexer.Position) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
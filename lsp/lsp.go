@@ -0,0 +1,325 @@
+// Package lsp implements a Language Server Protocol server for Glace.
+// Documents are kept in full (no incremental sync) and re-analysed on
+// every change; the analysis is the parser's diagnostics plus the
+// resolver behind `glace check`.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"strconv"
+
+	"github.com/glace-lang/glace/evaluator"
+)
+
+// Server speaks LSP over a pair of streams.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	docs     map[string]*document
+	builtins map[string]*evaluator.BuiltinFn
+}
+
+// NewServer creates a server that reads requests from in and writes
+// responses and notifications to out.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	env := evaluator.NewEnvironment()
+	evaluator.RegisterBuiltins(env)
+	evaluator.RegisterHOBuiltins(env)
+	builtins := map[string]*evaluator.BuiltinFn{}
+	for _, b := range env.Bindings() {
+		if fn, ok := b.Value.(*evaluator.BuiltinFn); ok {
+			builtins[b.Name] = fn
+		}
+	}
+	return &Server{
+		in:       bufio.NewReader(in),
+		out:      out,
+		docs:     map[string]*document{},
+		builtins: builtins,
+	}
+}
+
+// ServeStdio runs an LSP session over the process's stdin and stdout.
+func ServeStdio() error {
+	return NewServer(os.Stdin, os.Stdout).Serve()
+}
+
+// Serve handles messages until the client sends exit or closes the stream.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		s.handle(msg)
+	}
+}
+
+func (s *Server) handle(msg *message) {
+	switch msg.Method {
+	case "initialize":
+		s.respond(msg, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"textDocumentSync":       1, // full
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"documentSymbolProvider": true,
+				"completionProvider":     map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{"name": "glace"},
+		})
+
+	case "initialized", "$/cancelRequest", "$/setTrace":
+
+	case "shutdown":
+		s.respond(msg, nil)
+
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument struct {
+				URI  string `json:"uri"`
+				Text string `json:"text"`
+			} `json:"textDocument"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		s.update(p.TextDocument.URI, p.TextDocument.Text)
+
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument   textDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		if n := len(p.ContentChanges); n > 0 {
+			s.update(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+		}
+
+	case "textDocument/didClose":
+		var p struct {
+			TextDocument textDocument `json:"textDocument"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		delete(s.docs, p.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", publishDiagnostics{URI: p.TextDocument.URI, Diagnostics: []lspDiagnostic{}})
+
+	case "textDocument/hover":
+		d, pos := s.position(msg)
+		if d == nil {
+			s.respond(msg, nil)
+			return
+		}
+		s.respond(msg, d.hover(d.pos(pos), s.builtins))
+
+	case "textDocument/definition":
+		d, pos := s.position(msg)
+		if d == nil {
+			s.respond(msg, []location{})
+			return
+		}
+		s.respond(msg, d.definition(d.pos(pos)))
+
+	case "textDocument/references":
+		var p struct {
+			Context struct {
+				IncludeDeclaration bool `json:"includeDeclaration"`
+			} `json:"context"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		d, pos := s.position(msg)
+		if d == nil {
+			s.respond(msg, []location{})
+			return
+		}
+		s.respond(msg, d.references(d.pos(pos), p.Context.IncludeDeclaration))
+
+	case "textDocument/documentSymbol":
+		var p struct {
+			TextDocument textDocument `json:"textDocument"`
+		}
+		json.Unmarshal(msg.Params, &p)
+		d := s.docs[p.TextDocument.URI]
+		if d == nil {
+			s.respond(msg, []documentSymbol{})
+			return
+		}
+		s.respond(msg, d.symbols())
+
+	case "textDocument/completion":
+		d, pos := s.position(msg)
+		if d == nil {
+			s.respond(msg, []completionItem{})
+			return
+		}
+		s.respond(msg, d.completions(d.pos(pos), s.builtins))
+
+	default:
+		if msg.ID != nil {
+			s.fail(msg, codeMethodNotFound, fmt.Sprintf("unsupported method %q", msg.Method))
+		}
+	}
+}
+
+// update re-analyses a document and publishes its diagnostics.
+func (s *Server) update(uri, text string) {
+	d := analyze(uri, text)
+	s.docs[uri] = d
+	s.notify("textDocument/publishDiagnostics", publishDiagnostics{URI: uri, Diagnostics: d.diagnostics()})
+}
+
+// position decodes TextDocumentPositionParams.
+func (s *Server) position(msg *message) (*document, position) {
+	var p struct {
+		TextDocument textDocument `json:"textDocument"`
+		Position     position     `json:"position"`
+	}
+	json.Unmarshal(msg.Params, &p)
+	return s.docs[p.TextDocument.URI], p.Position
+}
+
+// ---------------------------------------------------------------------------
+// Protocol types
+// ---------------------------------------------------------------------------
+
+const codeMethodNotFound = -32601
+
+const (
+	completionFunction = 3
+	completionVariable = 6
+	completionKeyword  = 14
+
+	symbolFunction = 12
+	symbolVariable = 13
+	symbolConstant = 14
+)
+
+// message covers requests, responses and notifications of JSON-RPC 2.0.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type textDocument struct {
+	URI string `json:"uri"`
+}
+
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start position `json:"start"`
+	End   position `json:"end"`
+}
+
+type location struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code,omitempty"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type publishDiagnostics struct {
+	URI         string          `json:"uri"`
+	Diagnostics []lspDiagnostic `json:"diagnostics"`
+}
+
+type markupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type hover struct {
+	Contents markupContent `json:"contents"`
+	Range    lspRange      `json:"range"`
+}
+
+type documentSymbol struct {
+	Name           string   `json:"name"`
+	Detail         string   `json:"detail,omitempty"`
+	Kind           int      `json:"kind"`
+	Range          lspRange `json:"range"`
+	SelectionRange lspRange `json:"selectionRange"`
+}
+
+type completionItem struct {
+	Label         string `json:"label"`
+	Kind          int    `json:"kind"`
+	Detail        string `json:"detail,omitempty"`
+	Documentation string `json:"documentation,omitempty"`
+}
+
+// ---------------------------------------------------------------------------
+// Wire format
+// ---------------------------------------------------------------------------
+
+func (s *Server) read() (*message, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("lsp: bad Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	msg := &message{}
+	if err := json.Unmarshal(body, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func (s *Server) send(msg *message) {
+	msg.JSONRPC = "2.0"
+	data, _ := json.Marshal(msg)
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+// respond answers req; a nil result is sent as null.
+func (s *Server) respond(req *message, result interface{}) {
+	data, _ := json.Marshal(result)
+	s.send(&message{ID: req.ID, Result: data})
+}
+
+func (s *Server) fail(req *message, code int, text string) {
+	s.send(&message{ID: req.ID, Error: &rpcError{Code: code, Message: text}})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	data, _ := json.Marshal(params)
+	s.send(&message{Method: method, Params: data})
+}
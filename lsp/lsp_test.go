@@ -0,0 +1,100 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const testURI = "file:///t.glace"
+
+const testSource = `fn add(a, b) {
+    return a + b
+}
+let total = add(1, 2)
+print(total)
+let bad = (1 +
+`
+
+func TestServer(t *testing.T) {
+	var in bytes.Buffer
+	frame := func(id int, method string, params interface{}) {
+		msg := map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+		if id > 0 {
+			msg["id"] = id
+		}
+		data, _ := json.Marshal(msg)
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+	doc := map[string]string{"uri": testURI}
+	at := func(line, char int) map[string]interface{} {
+		return map[string]interface{}{"textDocument": doc, "position": position{line, char}}
+	}
+	frame(1, "initialize", map[string]interface{}{})
+	frame(0, "textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]string{"uri": testURI, "text": testSource},
+	})
+	frame(2, "textDocument/hover", at(4, 1))  // print
+	frame(3, "textDocument/hover", at(3, 13)) // add
+	frame(4, "textDocument/definition", at(4, 8))
+	frame(5, "textDocument/references", map[string]interface{}{
+		"textDocument": doc, "position": position{0, 4}, "context": map[string]bool{"includeDeclaration": true},
+	})
+	frame(6, "textDocument/documentSymbol", map[string]interface{}{"textDocument": doc})
+	frame(7, "textDocument/completion", at(1, 12))
+	frame(8, "shutdown", nil)
+	frame(0, "exit", nil)
+
+	var out bytes.Buffer
+	if err := NewServer(&in, &out).Serve(); err != nil {
+		t.Fatal(err)
+	}
+
+	results := map[int]string{}
+	var diagnostics publishDiagnostics
+	s := &Server{in: bufio.NewReader(&out)}
+	for {
+		msg, err := s.read()
+		if err != nil {
+			break
+		}
+		if msg.Method == "textDocument/publishDiagnostics" {
+			json.Unmarshal(msg.Params, &diagnostics)
+			continue
+		}
+		var id int
+		json.Unmarshal(msg.ID, &id)
+		results[id] = string(msg.Result)
+	}
+
+	if len(diagnostics.Diagnostics) != 1 || diagnostics.Diagnostics[0].Range.Start.Line != 5 {
+		t.Errorf("diagnostics = %+v, want one syntax error on line 6", diagnostics.Diagnostics)
+	}
+	checks := []struct {
+		id   int
+		want string
+	}{
+		{2, `print(args...)`},
+		{3, `fn add(a, b)`},
+		{4, `"range":{"start":{"line":3,"character":4},"end":{"line":3,"character":9}}`},
+		{5, `{"line":0,"character":3}`},
+		{5, `{"line":3,"character":12}`},
+		{6, `"name":"add","detail":"fn","kind":12`},
+		{6, `"name":"total"`},
+		{7, `"label":"a","kind":6`},
+		{7, `"label":"filter"`},
+		{7, `"label":"loop","kind":14`},
+		{8, `null`},
+	}
+	for _, c := range checks {
+		if !strings.Contains(results[c.id], c.want) {
+			t.Errorf("result %d = %s\nwant it to contain %s", c.id, results[c.id], c.want)
+		}
+	}
+	if strings.Contains(results[7], `"label":"total"`) {
+		t.Errorf("completion inside add offers total, which is declared later: %s", results[7])
+	}
+}
@@ -2,9 +2,12 @@ package parser
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
 	"github.com/glace-lang/glace/lexer"
 )
 
@@ -13,20 +16,74 @@ import (
 type Parser struct {
 	tokens  []lexer.Token
 	current int
-	errors  []string
+	errors  []*diag.Diagnostic
+	panic   bool // an error was reported and the parser has not resynchronized yet
+	panicAt int  // index of the token where the current panic started
 }
 
 func New(tokens []lexer.Token) *Parser {
-	return &Parser{tokens: tokens, errors: make([]string, 0)}
+	return &Parser{tokens: tokens, errors: make([]*diag.Diagnostic, 0)}
 }
 
-// Parse is the top-level entry point.
-func Parse(tokens []lexer.Token) (*ast.Program, []string) {
+// Parse is the top-level entry point. TOKEN_ILLEGAL tokens are skipped
+// silently, since the lexer has already reported them; use ParseSource to
+// get lexical and syntax errors together.
+func Parse(tokens []lexer.Token) (*ast.Program, []*diag.Diagnostic) {
 	p := New(tokens)
 	prog := p.parseProgram()
 	return prog, p.errors
 }
 
+// ParseSource lexes and parses source, returning its lexical and syntax
+// errors together in source order.
+func ParseSource(source, file string) (*ast.Program, []*diag.Diagnostic) {
+	l := lexer.New(source, file)
+	prog, errs := Parse(l.Tokenize())
+	prog.Comments = l.Comments()
+	attachDocs(prog)
+	all := append(l.Diagnostics(), errs...)
+	sort.SliceStable(all, func(i, j int) bool {
+		a, b := all[i].Start, all[j].Start
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return prog, all
+}
+
+// attachDocs sets the Doc of each fn declaration and let statement that
+// has /// comments on the lines directly above it.
+func attachDocs(prog *ast.Program) {
+	docs := map[int]string{} // line -> doc comment text
+	for _, c := range prog.Comments {
+		if c.IsDoc() && !c.Trailing {
+			text := strings.TrimPrefix(c.Text, "///")
+			docs[c.Pos.Line] = strings.TrimPrefix(text, " ")
+		}
+	}
+	if len(docs) == 0 {
+		return
+	}
+	above := func(line int) string {
+		var lines []string
+		for l := line - 1; ; l-- {
+			text, ok := docs[l]
+			if !ok {
+				break
+			}
+			lines = append([]string{text}, lines...)
+		}
+		return strings.Join(lines, "\n")
+	}
+	ast.Walk(prog, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FnDeclaration:
+			n.Doc = above(n.Pos.Line)
+		case *ast.LetStatement:
+			n.Doc = above(n.Pos.Line)
+		}
+		return true
+	})
+}
+
 func (p *Parser) parseProgram() *ast.Program {
 	prog := &ast.Program{Statements: make([]ast.Statement, 0)}
 	for !p.isAtEnd() {
@@ -34,9 +91,7 @@ func (p *Parser) parseProgram() *ast.Program {
 		if p.isAtEnd() {
 			break
 		}
-		if stmt := p.parseStatement(); stmt != nil {
-			prog.Statements = append(prog.Statements, stmt)
-		}
+		prog.Statements = append(prog.Statements, p.parseStatementRecover())
 	}
 	return prog
 }
@@ -45,6 +100,34 @@ func (p *Parser) parseProgram() *ast.Program {
 // Statement Parsing
 // ---------------------------------------------------------------------------
 
+// parseStatementRecover parses one statement and checks that it ends the
+// line. If anything in it was malformed, the parser goes back to the
+// first error, skips from there to the next statement boundary and
+// returns a BadStatement covering the damage, so one mistake yields one
+// error and the statements around it still parse.
+func (p *Parser) parseStatementRecover() ast.Statement {
+	start := p.current
+	stmt := p.parseStatement()
+	if !p.panic && !p.isStatementEnd() && !p.afterLineEnd() {
+		p.errorAt(p.peek(), diag.CodeExpectedToken, "expected end of statement, got %s", describe(p.peek()))
+	}
+	if !p.panic && stmt != nil {
+		return stmt
+	}
+
+	// Parsing went on past the error without reporting anything, and may
+	// have taken valid code after it for part of the damaged statement.
+	if p.panicAt >= start && p.panicAt < p.current {
+		p.current = p.panicAt
+	}
+	p.synchronize(start)
+	p.panic = false
+	if p.current == start {
+		p.advance() // always make progress
+	}
+	return &ast.BadStatement{Pos: p.tokens[start].Pos, End: p.prevEnd()}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.peek().Type {
 	case lexer.TOKEN_LET:
@@ -70,6 +153,13 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseMatchStatement()
 	case lexer.TOKEN_TEST:
 		return p.parseTestBlock()
+	case lexer.TOKEN_BENCH:
+		return p.parseBenchBlock()
+	case lexer.TOKEN_DESCRIBE:
+		return p.parseDescribeBlock()
+	case lexer.TOKEN_BEFORE_EACH, lexer.TOKEN_AFTER_EACH, lexer.TOKEN_BEFORE_ALL, lexer.TOKEN_AFTER_ALL:
+		tok := p.advance()
+		return &ast.HookBlock{Pos: tok.Pos, Kind: tok.Literal, Body: p.parseBlock()}
 	default:
 		return p.parseExpressionOrAssignment()
 	}
@@ -80,12 +170,10 @@ func (p *Parser) parseLetStatement() ast.Statement {
 	pos := p.advance().Pos // consume 'let'
 	name := p.advance()    // consume identifier
 	if name.Type != lexer.TOKEN_IDENT {
-		p.addError(fmt.Sprintf("expected identifier after 'let', got %q at %s", name.Literal, name.Pos))
-		p.synchronize()
+		p.errorAt(name, diag.CodeExpectedToken, "expected identifier after 'let', got %s", describe(name))
 		return nil
 	}
 	if !p.expect(lexer.TOKEN_ASSIGN) {
-		p.synchronize()
 		return nil
 	}
 	value := p.parseExpression(PREC_LOWEST)
@@ -97,12 +185,10 @@ func (p *Parser) parseMutStatement() ast.Statement {
 	pos := p.advance().Pos
 	name := p.advance()
 	if name.Type != lexer.TOKEN_IDENT {
-		p.addError(fmt.Sprintf("expected identifier after 'mut', got %q at %s", name.Literal, name.Pos))
-		p.synchronize()
+		p.errorAt(name, diag.CodeExpectedToken, "expected identifier after 'mut', got %s", describe(name))
 		return nil
 	}
 	if !p.expect(lexer.TOKEN_ASSIGN) {
-		p.synchronize()
 		return nil
 	}
 	value := p.parseExpression(PREC_LOWEST)
@@ -113,11 +199,16 @@ func (p *Parser) parseMutStatement() ast.Statement {
 func (p *Parser) parseFnDeclaration() ast.Statement {
 	pos := p.advance().Pos // consume 'fn'
 	name := p.advance()    // consume name
+	if name.Type != lexer.TOKEN_IDENT {
+		p.errorAt(name, diag.CodeExpectedToken, "expected function name after 'fn', got %s", describe(name))
+		return nil
+	}
 	params := p.parseParams()
 
 	// Arrow form: fn name(params) => expr
 	if p.peek().Type == lexer.TOKEN_ARROW {
 		p.advance() // consume '=>'
+		p.continueLine()
 		expr := p.parseExpression(PREC_LOWEST)
 		body := &ast.BlockStatement{
 			Pos: expr.TokenPos(),
@@ -125,7 +216,7 @@ func (p *Parser) parseFnDeclaration() ast.Statement {
 				&ast.ReturnStatement{Pos: expr.TokenPos(), Values: []ast.Expression{expr}},
 			},
 		}
-		return &ast.FnDeclaration{Pos: pos, Name: name.Literal, Params: params, Body: body}
+		return &ast.FnDeclaration{Pos: pos, Name: name.Literal, Params: params, Body: body, Arrow: true}
 	}
 
 	body := p.parseBlock()
@@ -233,8 +324,9 @@ func (p *Parser) parseMatchStatement() ast.Statement {
 		p.skipNewlines()
 	}
 
+	end := p.peek().Pos
 	p.expect(lexer.TOKEN_RBRACE)
-	return &ast.MatchStatement{Pos: pos, Subject: subject, Arms: arms}
+	return &ast.MatchStatement{Pos: pos, Subject: subject, Arms: arms, End: end}
 }
 
 func (p *Parser) parseMatchArm() ast.MatchArm {
@@ -266,6 +358,7 @@ func (p *Parser) parseMatchArm() ast.MatchArm {
 				&ast.ReturnStatement{Pos: expr.TokenPos(), Values: []ast.Expression{expr}},
 			},
 		}
+		arm.Arrow = true
 	}
 	return arm
 }
@@ -274,8 +367,7 @@ func (p *Parser) parseMatchArm() ast.MatchArm {
 func (p *Parser) parseTestBlock() ast.Statement {
 	pos := p.advance().Pos // consume 'test'
 	if p.peek().Type != lexer.TOKEN_STRING {
-		p.addError(fmt.Sprintf("expected string after 'test', got %q at %s", p.peek().Literal, p.peek().Pos))
-		p.synchronize()
+		p.errorAt(p.peek(), diag.CodeExpectedToken, "expected string after 'test', got %s", describe(p.peek()))
 		return nil
 	}
 	desc := p.advance().Literal
@@ -283,21 +375,39 @@ func (p *Parser) parseTestBlock() ast.Statement {
 	return &ast.TestBlock{Pos: pos, Description: desc, Body: body}
 }
 
+// describe "<description>" <block>
+func (p *Parser) parseDescribeBlock() ast.Statement {
+	pos := p.advance().Pos // consume 'describe'
+	if p.peek().Type != lexer.TOKEN_STRING {
+		p.errorAt(p.peek(), diag.CodeExpectedToken, "expected string after 'describe', got %s", describe(p.peek()))
+		return nil
+	}
+	desc := p.advance().Literal
+	body := p.parseBlock()
+	return &ast.DescribeBlock{Pos: pos, Description: desc, Body: body}
+}
+
+// bench "<description>" <block>
+func (p *Parser) parseBenchBlock() ast.Statement {
+	pos := p.advance().Pos // consume 'bench'
+	if p.peek().Type != lexer.TOKEN_STRING {
+		p.errorAt(p.peek(), diag.CodeExpectedToken, "expected string after 'bench', got %s", describe(p.peek()))
+		return nil
+	}
+	desc := p.advance().Literal
+	body := p.parseBlock()
+	return &ast.BenchBlock{Pos: pos, Description: desc, Body: body}
+}
+
 func (p *Parser) parseExpressionStatement() ast.Statement {
 	pos := p.peek().Pos
 	expr := p.parseExpression(PREC_LOWEST)
-	if expr == nil {
-		return nil
-	}
 	return &ast.ExpressionStatement{Pos: pos, Expression: expr}
 }
 
 func (p *Parser) parseExpressionOrAssignment() ast.Statement {
 	pos := p.peek().Pos
 	expr := p.parseExpression(PREC_LOWEST)
-	if expr == nil {
-		return nil
-	}
 
 	if p.peek().Type == lexer.TOKEN_ASSIGN {
 		p.advance() // consume '='
@@ -307,8 +417,10 @@ func (p *Parser) parseExpressionOrAssignment() ast.Statement {
 			return &ast.AssignStatement{Pos: pos, Name: target.Name, Value: value}
 		case *ast.IndexExpression:
 			return &ast.IndexAssignStatement{Pos: pos, Left: target.Left, Index: target.Index, Value: value}
+		case *ast.DotExpression:
+			return &ast.FieldAssignStatement{Pos: pos, Left: target.Left, Field: target.Field, Value: value}
 		default:
-			p.addError(fmt.Sprintf("invalid assignment target at %s", pos))
+			p.errorSpan(pos, pos, diag.CodeInvalidAssignment, "invalid assignment target")
 			return nil
 		}
 	}
@@ -317,6 +429,11 @@ func (p *Parser) parseExpressionOrAssignment() ast.Statement {
 }
 
 func (p *Parser) parseBlock() *ast.BlockStatement {
+	// After an error the enclosing statement is resynchronized as a
+	// whole, so its blocks are not parsed.
+	if p.panic {
+		return nil
+	}
 	pos := p.peek().Pos
 	if !p.expect(lexer.TOKEN_LBRACE) {
 		return nil
@@ -325,13 +442,17 @@ func (p *Parser) parseBlock() *ast.BlockStatement {
 
 	p.skipNewlines()
 	for !p.isAtEnd() && p.peek().Type != lexer.TOKEN_RBRACE {
-		if stmt := p.parseStatement(); stmt != nil {
-			block.Statements = append(block.Statements, stmt)
-		}
+		block.Statements = append(block.Statements, p.parseStatementRecover())
 		p.skipNewlines()
 	}
 
-	p.expect(lexer.TOKEN_RBRACE)
+	// The loop only stops at '}' or the end of the file. A missing '}' is
+	// reported, but the block is complete, so the enclosing statement is
+	// not treated as damaged.
+	block.End = p.peek().Pos
+	if !p.expect(lexer.TOKEN_RBRACE) {
+		p.panic = false
+	}
 	return block
 }
 
@@ -341,19 +462,32 @@ func (p *Parser) parseBlock() *ast.BlockStatement {
 
 func (p *Parser) parseExpression(prec Precedence) ast.Expression {
 	left := p.parsePrefixExpression()
-	if left == nil {
-		return nil
-	}
-
-	for !p.isAtEnd() && prec < p.peekPrecedence() {
-		left = p.parseInfixExpression(left)
-		if left == nil {
-			return nil
+	for !p.isAtEnd() {
+		if p.peek().Type == lexer.TOKEN_NEWLINE && p.pipelineContinues() {
+			p.skipNewlines()
 		}
+		if prec >= p.peekPrecedence() {
+			break
+		}
+		left = p.parseInfixExpression(left)
 	}
 	return left
 }
 
+// pipelineContinues reports whether the newlines at the current token are
+// followed by |>, which continues a pipeline split across lines:
+//
+//	let total = orders
+//	    |> filter(fn(o) => o.paid)
+//	    |> reduce(0, fn(acc, o) => acc + o.amount)
+func (p *Parser) pipelineContinues() bool {
+	i := p.current
+	for i < len(p.tokens) && p.tokens[i].Type == lexer.TOKEN_NEWLINE {
+		i++
+	}
+	return i < len(p.tokens) && p.tokens[i].Type == lexer.TOKEN_PIPE
+}
+
 // parsePrefixExpression dispatches to the correct prefix handler.
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	switch p.peek().Type {
@@ -380,9 +514,14 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 	case lexer.TOKEN_MINUS, lexer.TOKEN_NOT:
 		return p.parseUnaryExpression()
 	default:
-		p.addError(fmt.Sprintf("unexpected token %q at %s", p.peek().Literal, p.peek().Pos))
+		tok := p.peek()
+		p.errorAt(tok, diag.CodeUnexpectedToken, "unexpected %s", describe(tok))
+		if tok.Type == lexer.TOKEN_NEWLINE || tok.Type == lexer.TOKEN_RBRACE {
+			// Leave statement boundaries for the statement to resynchronize on.
+			return &ast.BadExpression{Pos: tok.Pos, End: tok.Pos}
+		}
 		p.advance()
-		return nil
+		return &ast.BadExpression{Pos: tok.Pos, End: p.prevEnd()}
 	}
 }
 
@@ -422,8 +561,8 @@ func (p *Parser) parseIntLiteral() ast.Expression {
 	tok := p.advance()
 	val, err := strconv.ParseInt(tok.Literal, 10, 64)
 	if err != nil {
-		p.addError(fmt.Sprintf("invalid integer %q at %s", tok.Literal, tok.Pos))
-		return nil
+		p.errorAt(tok, diag.CodeInvalidLiteral, "invalid integer %q", tok.Literal)
+		return &ast.BadExpression{Pos: tok.Pos, End: p.prevEnd()}
 	}
 	return &ast.IntegerLiteral{Pos: tok.Pos, Value: val}
 }
@@ -432,8 +571,8 @@ func (p *Parser) parseFloatLiteral() ast.Expression {
 	tok := p.advance()
 	val, err := strconv.ParseFloat(tok.Literal, 64)
 	if err != nil {
-		p.addError(fmt.Sprintf("invalid float %q at %s", tok.Literal, tok.Pos))
-		return nil
+		p.errorAt(tok, diag.CodeInvalidLiteral, "invalid float %q", tok.Literal)
+		return &ast.BadExpression{Pos: tok.Pos, End: p.prevEnd()}
 	}
 	return &ast.FloatLiteral{Pos: tok.Pos, Value: val}
 }
@@ -467,7 +606,8 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 func (p *Parser) parseArrayLiteral() ast.Expression {
 	tok := p.advance() // consume '['
 	elements := p.parseExpressionList(lexer.TOKEN_RBRACKET)
-	return &ast.ArrayLiteral{Pos: tok.Pos, Elements: elements}
+	end := p.tokens[min(p.current, len(p.tokens))-1].Pos // the ']', unless it was missing
+	return &ast.ArrayLiteral{Pos: tok.Pos, Elements: elements, End: end}
 }
 
 func (p *Parser) parseMapLiteral() ast.Expression {
@@ -478,7 +618,11 @@ func (p *Parser) parseMapLiteral() ast.Expression {
 	p.skipNewlines()
 	for !p.isAtEnd() && p.peek().Type != lexer.TOKEN_RBRACE {
 		key := p.parseExpression(PREC_LOWEST)
-		p.expect(lexer.TOKEN_COLON)
+		if p.peek().Type == lexer.TOKEN_COLON {
+			p.advance()
+		} else {
+			p.errorAt(p.peek(), diag.CodeExpectedToken, "expected ':' after map key, got %s", describe(p.peek()))
+		}
 		val := p.parseExpression(PREC_LOWEST)
 		keys = append(keys, key)
 		values = append(values, val)
@@ -489,8 +633,9 @@ func (p *Parser) parseMapLiteral() ast.Expression {
 		p.skipNewlines()
 	}
 
+	end := p.peek().Pos
 	p.expect(lexer.TOKEN_RBRACE)
-	return &ast.MapLiteral{Pos: tok.Pos, Keys: keys, Values: values}
+	return &ast.MapLiteral{Pos: tok.Pos, Keys: keys, Values: values, End: end}
 }
 
 // fn(<params>) => <expr>  |  fn(<params>) <block>
@@ -500,6 +645,7 @@ func (p *Parser) parseFnLiteral() ast.Expression {
 
 	if p.peek().Type == lexer.TOKEN_ARROW {
 		p.advance() // consume '=>'
+		p.continueLine()
 		expr := p.parseExpression(PREC_LOWEST)
 		body := &ast.BlockStatement{
 			Pos: expr.TokenPos(),
@@ -507,7 +653,7 @@ func (p *Parser) parseFnLiteral() ast.Expression {
 				&ast.ReturnStatement{Pos: expr.TokenPos(), Values: []ast.Expression{expr}},
 			},
 		}
-		return &ast.FnLiteral{Pos: tok.Pos, Params: params, Body: body}
+		return &ast.FnLiteral{Pos: tok.Pos, Params: params, Body: body, Arrow: true}
 	}
 
 	body := p.parseBlock()
@@ -526,6 +672,7 @@ func (p *Parser) parseUnaryExpression() ast.Expression {
 
 func (p *Parser) parseBinaryExpression(left ast.Expression) ast.Expression {
 	tok := p.advance() // consume operator
+	p.continueLine()
 	prec := tokenPrecedence(tok.Type)
 	right := p.parseExpression(prec)
 	return &ast.BinaryExpression{Pos: tok.Pos, Left: left, Operator: tok.Literal, Right: right}
@@ -551,7 +698,7 @@ func (p *Parser) parseDotExpression(left ast.Expression) ast.Expression {
 	p.advance() // consume '.'
 	field := p.advance()
 	if field.Type != lexer.TOKEN_IDENT {
-		p.addError(fmt.Sprintf("expected field name after '.', got %q at %s", field.Literal, field.Pos))
+		p.errorAt(field, diag.CodeExpectedToken, "expected field name after '.', got %s", describe(field))
 		return left
 	}
 	return &ast.DotExpression{Pos: field.Pos, Left: left, Field: field.Literal}
@@ -562,7 +709,7 @@ func (p *Parser) parseSafeAccessExpression(left ast.Expression) ast.Expression {
 	p.advance() // consume '?.'
 	field := p.advance()
 	if field.Type != lexer.TOKEN_IDENT {
-		p.addError(fmt.Sprintf("expected field name after '?.', got %q at %s", field.Literal, field.Pos))
+		p.errorAt(field, diag.CodeExpectedToken, "expected field name after '?.', got %s", describe(field))
 		return left
 	}
 	return &ast.SafeAccessExpression{Pos: field.Pos, Left: left, Field: field.Literal}
@@ -571,11 +718,12 @@ func (p *Parser) parseSafeAccessExpression(left ast.Expression) ast.Expression {
 // <left> |> <call>
 func (p *Parser) parsePipelineExpression(left ast.Expression) ast.Expression {
 	tok := p.advance() // consume '|>'
+	p.continueLine()
 	right := p.parseExpression(PREC_PIPELINE)
 
 	call, ok := right.(*ast.CallExpression)
 	if !ok {
-		p.addError(fmt.Sprintf("right side of |> must be a function call at %s", tok.Pos))
+		p.errorAt(tok, diag.CodeInvalidPipeline, "right side of |> must be a function call")
 		return left
 	}
 	return &ast.PipelineExpression{Pos: tok.Pos, Left: left, Right: call}
@@ -584,6 +732,7 @@ func (p *Parser) parsePipelineExpression(left ast.Expression) ast.Expression {
 // <left>..<right> [step <expr>]
 func (p *Parser) parseRangeExpression(left ast.Expression) ast.Expression {
 	tok := p.advance() // consume '..'
+	p.continueLine()
 	end := p.parseExpression(PREC_RANGE)
 	rangeExpr := &ast.RangeExpression{Pos: tok.Pos, Start: left, End: end}
 
@@ -597,6 +746,7 @@ func (p *Parser) parseRangeExpression(left ast.Expression) ast.Expression {
 // <left> ?? <right>
 func (p *Parser) parseCoalesceExpression(left ast.Expression) ast.Expression {
 	tok := p.advance() // consume '??'
+	p.continueLine()
 	right := p.parseExpression(PREC_COALESCE)
 	return &ast.CoalesceExpression{Pos: tok.Pos, Left: left, Right: right}
 }
@@ -611,10 +761,10 @@ func (p *Parser) parseParams() []string {
 	params := make([]string, 0)
 
 	if p.peek().Type != lexer.TOKEN_RPAREN {
-		params = append(params, p.advance().Literal)
+		params = append(params, p.parseParam())
 		for p.peek().Type == lexer.TOKEN_COMMA {
 			p.advance() // consume ','
-			params = append(params, p.advance().Literal)
+			params = append(params, p.parseParam())
 		}
 	}
 
@@ -622,6 +772,16 @@ func (p *Parser) parseParams() []string {
 	return params
 }
 
+func (p *Parser) parseParam() string {
+	tok := p.peek()
+	if tok.Type != lexer.TOKEN_IDENT {
+		p.errorAt(tok, diag.CodeExpectedToken, "expected parameter name, got %s", describe(tok))
+		return ""
+	}
+	p.advance()
+	return tok.Literal
+}
+
 // parseExpressionList parses comma-separated expressions until the end token.
 func (p *Parser) parseExpressionList(end lexer.TokenType) []ast.Expression {
 	list := make([]ast.Expression, 0)
@@ -683,6 +843,30 @@ func (p *Parser) isStatementEnd() bool {
 	return t == lexer.TOKEN_NEWLINE || t == lexer.TOKEN_EOF || t == lexer.TOKEN_RBRACE
 }
 
+// afterLineEnd reports whether the last consumed token was a newline or a
+// closing brace. Statements such as if, which look past newlines for elif
+// and else, may already have consumed their terminator.
+func (p *Parser) afterLineEnd() bool {
+	if p.current == 0 {
+		return false
+	}
+	t := p.tokens[p.current-1].Type
+	return t == lexer.TOKEN_NEWLINE || t == lexer.TOKEN_RBRACE
+}
+
+// prevEnd returns the position just past the last consumed token.
+func (p *Parser) prevEnd() lexer.Position {
+	if p.current == 0 {
+		return p.peek().Pos
+	}
+	tok := p.tokens[p.current-1]
+	end := tok.Pos
+	if tok.Type != lexer.TOKEN_NEWLINE {
+		end.Column += len(tok.Literal)
+	}
+	return end
+}
+
 func (p *Parser) peek() lexer.Token {
 	if p.current >= len(p.tokens) {
 		return lexer.Token{Type: lexer.TOKEN_EOF}
@@ -708,7 +892,7 @@ func (p *Parser) expect(expected lexer.TokenType) bool {
 		p.advance()
 		return true
 	}
-	p.addError(fmt.Sprintf("expected %v, got %v at %s", expected, p.peek().Type, p.peek().Pos))
+	p.errorAt(p.peek(), diag.CodeExpectedToken, "expected '%s', got %s", expected, describe(p.peek()))
 	return false
 }
 
@@ -722,26 +906,143 @@ func (p *Parser) skipNewlines() {
 	}
 }
 
-func (p *Parser) addError(msg string) {
-	p.errors = append(p.errors, msg)
+// continueLine skips the newlines after an operator or => that ends a
+// line, so the operand may start on the next one. It stops short of a
+// line that begins a new statement, so an unfinished expression is
+// reported there rather than swallowing the statement.
+func (p *Parser) continueLine() {
+	i := p.current
+	for i < len(p.tokens) && p.tokens[i].Type == lexer.TOKEN_NEWLINE {
+		i++
+	}
+	if i == p.current || i == len(p.tokens) {
+		return
+	}
+	switch p.tokens[i].Type {
+	case lexer.TOKEN_LET, lexer.TOKEN_MUT, lexer.TOKEN_RETURN, lexer.TOKEN_IF,
+		lexer.TOKEN_ELIF, lexer.TOKEN_ELSE, lexer.TOKEN_LOOP, lexer.TOKEN_BREAK,
+		lexer.TOKEN_CONTINUE, lexer.TOKEN_MATCH, lexer.TOKEN_TEST, lexer.TOKEN_BENCH,
+		lexer.TOKEN_DESCRIBE, lexer.TOKEN_BEFORE_EACH, lexer.TOKEN_AFTER_EACH,
+		lexer.TOKEN_BEFORE_ALL, lexer.TOKEN_AFTER_ALL,
+		lexer.TOKEN_IMPORT, lexer.TOKEN_RBRACE, lexer.TOKEN_EOF:
+		return
+	}
+	p.current = i
+}
+
+// errorAt reports a syntax error spanning tok. Errors at TOKEN_ILLEGAL
+// only start recovery, because the lexer has already reported the bad input.
+func (p *Parser) errorAt(tok lexer.Token, code, format string, args ...interface{}) {
+	if tok.Type == lexer.TOKEN_ILLEGAL {
+		if !p.panic {
+			p.panic, p.panicAt = true, p.current
+		}
+		return
+	}
+	end := tok.Pos
+	end.Column += len(tok.Literal)
+	if tok.Type == lexer.TOKEN_NEWLINE {
+		end = tok.Pos
+	}
+	p.errorSpan(tok.Pos, end, code, fmt.Sprintf(format, args...))
+}
+
+// errorSpan reports a syntax error unless the parser is already recovering
+// from one, in which case it is most likely a consequence of the first.
+func (p *Parser) errorSpan(start, end lexer.Position, code, msg string) {
+	if p.panic {
+		return
+	}
+	p.panic, p.panicAt = true, p.current
+	p.errors = append(p.errors, &diag.Diagnostic{
+		Start:    start,
+		End:      end,
+		Code:     code,
+		Severity: diag.Error,
+		Message:  msg,
+	})
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() []*diag.Diagnostic {
 	return p.errors
 }
 
-// synchronize advances to the next statement boundary for error recovery.
-func (p *Parser) synchronize() {
-	for !p.isAtEnd() {
-		if p.peek().Type == lexer.TOKEN_NEWLINE {
-			p.advance()
-			return
+// describe names a token for an error message.
+func describe(tok lexer.Token) string {
+	switch tok.Type {
+	case lexer.TOKEN_NEWLINE:
+		return "end of line"
+	case lexer.TOKEN_EOF:
+		return "end of file"
+	case lexer.TOKEN_STRING:
+		return fmt.Sprintf("string %q", tok.Literal)
+	case lexer.TOKEN_IDENT, lexer.TOKEN_INT, lexer.TOKEN_FLOAT:
+		return fmt.Sprintf("%q", tok.Literal)
+	}
+	return fmt.Sprintf("'%s'", tok.Type)
+}
+
+// synchronize skips the rest of the damaged statement that began at
+// token start, for error recovery. It stops at a newline, a '}' closing
+// the enclosing block, or a keyword that starts a statement, once the
+// brackets the statement opened are closed. A line that starts left of
+// the statement, or level with it, also ends the damage when brackets are
+// left open: a closing bracket there is taken as the statement's last
+// line, and anything else as the next statement.
+func (p *Parser) synchronize(start int) {
+	depth := 0
+	for _, tok := range p.tokens[start:p.current] {
+		switch tok.Type {
+		case lexer.TOKEN_LBRACE, lexer.TOKEN_LPAREN, lexer.TOKEN_LBRACKET:
+			depth++
+		case lexer.TOKEN_RBRACE, lexer.TOKEN_RPAREN, lexer.TOKEN_RBRACKET:
+			if depth > 0 {
+				depth--
+			}
 		}
+	}
+	column := p.tokens[start].Pos.Column
+	for !p.isAtEnd() {
 		switch p.peek().Type {
+		case lexer.TOKEN_LBRACE, lexer.TOKEN_LPAREN, lexer.TOKEN_LBRACKET:
+			depth++
+		case lexer.TOKEN_RPAREN, lexer.TOKEN_RBRACKET:
+			if depth > 0 {
+				depth--
+			}
+		case lexer.TOKEN_RBRACE:
+			if depth == 0 {
+				return
+			}
+			depth--
+		case lexer.TOKEN_NEWLINE:
+			if depth == 0 {
+				return
+			}
+			next := p.current
+			for next < len(p.tokens) && p.tokens[next].Type == lexer.TOKEN_NEWLINE {
+				next++
+			}
+			if next == len(p.tokens) || p.tokens[next].Pos.Column > column {
+				break
+			}
+			switch p.tokens[next].Type {
+			case lexer.TOKEN_RBRACE, lexer.TOKEN_RPAREN, lexer.TOKEN_RBRACKET:
+				if p.tokens[next].Pos.Column == column {
+					p.current = next + 1
+					depth = 0
+					continue
+				}
+			}
+			return
 		case lexer.TOKEN_LET, lexer.TOKEN_MUT, lexer.TOKEN_FN,
 			lexer.TOKEN_RETURN, lexer.TOKEN_IF, lexer.TOKEN_LOOP,
-			lexer.TOKEN_MATCH, lexer.TOKEN_TEST:
-			return
+			lexer.TOKEN_BREAK, lexer.TOKEN_CONTINUE,
+			lexer.TOKEN_MATCH, lexer.TOKEN_TEST, lexer.TOKEN_BENCH, lexer.TOKEN_DESCRIBE,
+			lexer.TOKEN_BEFORE_EACH, lexer.TOKEN_AFTER_EACH, lexer.TOKEN_BEFORE_ALL, lexer.TOKEN_AFTER_ALL:
+			if depth == 0 {
+				return
+			}
 		}
 		p.advance()
 	}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/glace-lang/glace/ast"
+)
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let x = (1 + 2
+fn f(a b) {
+    return a
+}
+fn g(n) {
+    let = n
+    print(n)
+}
+let y = x
+`
+	program, errs := ParseSource(input, "t.glace")
+
+	expectedLines := []int{1, 2, 6}
+	if len(errs) != len(expectedLines) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expectedLines), len(errs), errs)
+	}
+	for i, line := range expectedLines {
+		if errs[i].Start.Line != line {
+			t.Errorf("errs[%d] at line %d, expected line %d: %s", i, errs[i].Start.Line, line, errs[i])
+		}
+	}
+
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d", len(program.Statements))
+	}
+	for i, bad := range []bool{true, true, false, false} {
+		_, isBad := program.Statements[i].(*ast.BadStatement)
+		if isBad != bad {
+			t.Errorf("statement %d: got %s", i, program.Statements[i])
+		}
+	}
+
+	g := program.Statements[2].(*ast.FnDeclaration)
+	if _, ok := g.Body.Statements[0].(*ast.BadStatement); !ok || len(g.Body.Statements) != 2 {
+		t.Errorf("expected g's body to keep its good statement after a BadStatement, got %v", g.Body.Statements)
+	}
+}
+
+func TestRecoveryKeepsFollowingStatements(t *testing.T) {
+	tests := []struct {
+		input string
+		error string
+		kinds []string // statement types, "Bad" for BadStatement
+	}{
+		{
+			"let x = 1\nif x > {\n  print(1)\n}\nprint(2)",
+			"3:11: expected ':' after map key, got end of line",
+			[]string{"Let", "Bad", "Expression"},
+		},
+		{
+			"fn f( {\n  print(1)\n}\nprint(2)",
+			"1:7: expected parameter name, got '{'",
+			[]string{"Bad", "Expression"},
+		},
+		{
+			"fn f(a, 1) => a\nprint(2)",
+			"1:9: expected parameter name, got \"1\"",
+			[]string{"Bad", "Expression"},
+		},
+		{
+			"fn outer() {\n    if x > {\n        print(1)\n    }\n    print(2)\n}\nprint(3)",
+			"3:17: expected ':' after map key, got end of line",
+			[]string{"Fn", "Expression"},
+		},
+		{
+			"fn outer() {\n    let xs = [1,\n        2 3\n}\nprint(3)",
+			"3:11: expected ']', got \"3\"",
+			[]string{"Fn", "Expression"},
+		},
+	}
+	for _, tt := range tests {
+		program, errs := ParseSource(tt.input, "t.glace")
+		if len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.error) {
+			t.Errorf("%q: expected the single error %q, got %v", tt.input, tt.error, errs)
+		}
+		var kinds []string
+		for _, stmt := range program.Statements {
+			kind := strings.TrimPrefix(fmt.Sprintf("%T", stmt), "*ast.")
+			kinds = append(kinds, strings.TrimSuffix(strings.TrimSuffix(kind, "Statement"), "Declaration"))
+		}
+		if strings.Join(kinds, " ") != strings.Join(tt.kinds, " ") {
+			t.Errorf("%q: got statements %v, want %v", tt.input, kinds, tt.kinds)
+		}
+	}
+
+	// The damaged if is confined to the function body.
+	program, _ := ParseSource(tests[3].input, "t.glace")
+	body := program.Statements[0].(*ast.FnDeclaration).Body.Statements
+	if len(body) != 2 {
+		t.Fatalf("expected a BadStatement and print(2) in the body, got %v", body)
+	}
+	if bad, ok := body[0].(*ast.BadStatement); !ok || bad.End.Line != 4 {
+		t.Errorf("expected the bad if to end on line 4, got %s", body[0])
+	}
+}
+
+func TestLineContinuation(t *testing.T) {
+	input := `let total = 1 +
+    2 *
+    3
+let xs = [1, 2] |>
+    map(fn(x) =>
+        x + total)
+let z = (1 +
+let w = 2
+`
+	program, errs := ParseSource(input, "t.glace")
+	if len(errs) != 1 || errs[0].Start.Line != 7 {
+		t.Fatalf("expected one error at the end of the unfinished line, got %v", errs)
+	}
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d: %v", len(program.Statements), program.Statements)
+	}
+	if _, ok := program.Statements[1].(*ast.LetStatement).Value.(*ast.PipelineExpression); !ok {
+		t.Errorf("expected a pipeline across lines, got %s", program.Statements[1])
+	}
+	if _, ok := program.Statements[3].(*ast.LetStatement); !ok {
+		t.Errorf("expected the statement after an unfinished expression to survive, got %s", program.Statements[3])
+	}
+}
@@ -0,0 +1,185 @@
+package repl
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/parser"
+)
+
+// A command is a colon command such as ":type expr".
+type command struct {
+	name  string
+	args  string // shown by :help
+	help  string
+	run   func(s *session, arg string)
+	noArg bool // run may be called without an argument
+}
+
+// commands is initialised in init because :help refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{name: ":help", help: "show this list", run: (*session).help, noArg: true},
+		{name: ":env", help: "list the names you have defined", run: (*session).listEnv, noArg: true},
+		{name: ":type", args: "<expr>", help: "show the type of an expression", run: (*session).showType},
+		{name: ":ast", args: "<code>", help: "show the syntax tree of some code", run: (*session).showAST},
+		{name: ":time", args: "<code>", help: "run code and show how long it took", run: (*session).time},
+		{name: ":doc", args: "<name>", help: "show the documentation of a function", run: (*session).doc},
+		{name: ":load", args: "<file>", help: "run a file in this session", run: (*session).load},
+		{name: ":reload", help: "start over with the last loaded file, re-running your other inputs and their side effects", run: (*session).reload, noArg: true},
+		{name: ":reset", help: "forget everything defined so far", run: (*session).resetCommand, noArg: true},
+		{name: ":save", args: "<file>", help: "write this session's inputs to a file", run: (*session).saveCommand},
+	}
+}
+
+// command runs a colon command line.
+func (s *session) command(line string) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		if arg == "" && !c.noArg {
+			fmt.Fprintf(s.out, "  usage: %s %s\n", c.name, c.args)
+			return
+		}
+		c.run(s, arg)
+		return
+	}
+	fmt.Fprintf(s.out, "  unknown command %s (try :help)\n", name)
+}
+
+func (s *session) help(string) {
+	for _, c := range commands {
+		fmt.Fprintf(s.out, "  %-16s %s\n", strings.TrimSpace(c.name+" "+c.args), c.help)
+	}
+	fmt.Fprintf(s.out, "  %-16s %s\n", "exit", "leave the REPL")
+}
+
+// listEnv prints the bindings that are not builtins, with their types and
+// whether they are mutable.
+func (s *session) listEnv(string) {
+	n := 0
+	for _, b := range s.env.Bindings() {
+		if fn, ok := b.Value.(*evaluator.BuiltinFn); ok && fn.Name == b.Name {
+			continue
+		}
+		kind := "let"
+		if b.Mutable {
+			kind = "mut"
+		}
+		fmt.Fprintf(s.out, "  %s %s: %s = %s\n", kind, b.Name, b.Value.Type(), b.Value.String())
+		n++
+	}
+	if n == 0 {
+		fmt.Fprintln(s.out, "  nothing defined yet")
+	}
+}
+
+func (s *session) showType(arg string) {
+	if v, ok := s.run(arg, "<repl>"); ok {
+		s.inputs = append(s.inputs, arg)
+		fmt.Fprintf(s.out, "  %s\n", v.Type())
+	}
+}
+
+func (s *session) showAST(arg string) {
+	program, diags := parser.ParseSource(arg, "<repl>")
+	if len(diags) > 0 {
+		diag.RenderAll(s.out, diags, map[string]string{"<repl>": arg})
+		return
+	}
+	for _, stmt := range program.Statements {
+		fmt.Fprint(s.out, ast.FormatTree(stmt))
+	}
+}
+
+func (s *session) time(arg string) {
+	start := time.Now()
+	v, ok := s.run(arg, "<repl>")
+	elapsed := time.Since(start)
+	if !ok {
+		return
+	}
+	s.inputs = append(s.inputs, arg)
+	if v != nil && v.Type() != "none" {
+		fmt.Fprintf(s.out, "=> %s\n", v.String())
+	}
+	fmt.Fprintf(s.out, "  took %s\n", elapsed)
+}
+
+func (s *session) doc(name string) {
+	v, ok := s.env.Get(name)
+	if !ok {
+		fmt.Fprintf(s.out, "  undefined: %s\n", name)
+		return
+	}
+	sig, doc, ok := evaluator.FnDoc(v)
+	if !ok {
+		fmt.Fprintf(s.out, "  %s is a %s, not a function\n", name, v.Type())
+		return
+	}
+	fmt.Fprintf(s.out, "  %s\n", sig)
+	if doc != "" {
+		for _, line := range strings.Split(doc, "\n") {
+			fmt.Fprintln(s.out, strings.TrimRight("    "+line, " "))
+		}
+	}
+}
+
+// reload starts the session again with the last loaded file read anew.
+// The other inputs are replayed around it, in order and without their
+// output, so their side effects such as input() and file writes happen
+// again. Any that no longer run are reported and dropped.
+func (s *session) reload(string) {
+	if s.lastLoad == "" {
+		fmt.Fprintln(s.out, "  nothing loaded yet")
+		return
+	}
+	if _, err := os.Stat(s.lastLoad); err != nil {
+		fmt.Fprintf(s.out, "  error: %s\n", err)
+		return
+	}
+	inputs, at := s.inputs, s.lastLoadAt
+	s.reset()
+	for i, src := range inputs {
+		if i == at {
+			s.load(s.lastLoad)
+			continue
+		}
+		var ok bool
+		s.env.Runtime().Capture(func() error {
+			_, ok = s.run(src, "<repl>")
+			return nil
+		})
+		if ok {
+			s.inputs = append(s.inputs, src)
+		} else {
+			fmt.Fprintf(s.out, "  dropped from the session: %s\n", src)
+		}
+	}
+	if at < 0 {
+		s.load(s.lastLoad)
+	}
+}
+
+func (s *session) resetCommand(string) {
+	s.reset()
+	fmt.Fprintln(s.out, "  environment reset")
+}
+
+func (s *session) saveCommand(path string) {
+	if err := s.save(path); err != nil {
+		fmt.Fprintf(s.out, "  error: %s\n", err)
+		return
+	}
+	fmt.Fprintf(s.out, "  saved %d inputs to %s\n", len(s.inputs), path)
+}
@@ -0,0 +1,340 @@
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Keys the editor acts on. Ctrl-<letter> arrives as the letter's position
+// in the alphabet.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyBackspace = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyDelete    = 127
+
+	// Escape sequences are decoded to values past the byte range.
+	keyUp = 256 + iota
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDeleteForward
+	keyUnknown
+)
+
+// editor is a line editor for a terminal in raw mode: cursor movement,
+// history with reverse search, and tab completion.
+type editor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	fd       int // the terminal put in raw mode while reading; -1 for none
+	complete func(word string) []string
+
+	history     []string
+	historyFile string
+
+	prompt string
+	buf    []rune
+	pos    int // cursor position in buf
+}
+
+func newEditor(in io.Reader, out io.Writer, fd int, complete func(string) []string) *editor {
+	return &editor{in: bufio.NewReader(in), out: out, fd: fd, complete: complete}
+}
+
+func (e *editor) AddHistory(line string) {
+	if line == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+}
+
+func (e *editor) Close() error {
+	return saveHistory(e.historyFile, e.history)
+}
+
+func (e *editor) ReadLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		restore, err := makeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	e.prompt, e.buf, e.pos = prompt, nil, 0
+	histPos := len(e.history) // len(history) is the line being edited
+	var draft []rune          // the edited line, kept while browsing history
+	e.refresh()
+
+	for {
+		key, r, err := e.readKey()
+		if err != nil {
+			return "", err
+		}
+		switch key {
+		case keyCR, keyLF:
+			fmt.Fprint(e.out, "\r\n")
+			return string(e.buf), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			e.deleteForward()
+		case keyBackspace, keyDelete:
+			if e.pos > 0 {
+				e.buf = append(e.buf[:e.pos-1], e.buf[e.pos:]...)
+				e.pos--
+			}
+		case keyDeleteForward:
+			e.deleteForward()
+		case keyLeft, keyCtrlB:
+			if e.pos > 0 {
+				e.pos--
+			}
+		case keyRight, keyCtrlF:
+			if e.pos < len(e.buf) {
+				e.pos++
+			}
+		case keyHome, keyCtrlA:
+			e.pos = 0
+		case keyEnd, keyCtrlE:
+			e.pos = len(e.buf)
+		case keyCtrlK:
+			e.buf = e.buf[:e.pos]
+		case keyCtrlU:
+			e.buf = e.buf[e.pos:]
+			e.pos = 0
+		case keyCtrlW:
+			start := e.pos
+			for start > 0 && e.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && e.buf[start-1] != ' ' {
+				start--
+			}
+			e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+			e.pos = start
+		case keyCtrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case keyUp, keyCtrlP, keyDown, keyCtrlN:
+			next := histPos - 1
+			if key == keyDown || key == keyCtrlN {
+				next = histPos + 1
+			}
+			if next < 0 || next > len(e.history) {
+				break
+			}
+			if histPos == len(e.history) {
+				draft = e.buf
+			}
+			histPos = next
+			if histPos == len(e.history) {
+				e.buf = draft
+			} else {
+				e.buf = []rune(e.history[histPos])
+			}
+			e.pos = len(e.buf)
+		case keyTab:
+			e.completeWord()
+		case keyCtrlR:
+			line, done, err := e.search()
+			if err != nil || done {
+				return line, err
+			}
+		case 0:
+			if unicode.IsPrint(r) {
+				e.buf = append(e.buf[:e.pos], append([]rune{r}, e.buf[e.pos:]...)...)
+				e.pos++
+			}
+		}
+		e.refresh()
+	}
+}
+
+func (e *editor) deleteForward() {
+	if e.pos < len(e.buf) {
+		e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+	}
+}
+
+// refresh redraws the prompt and buffer and places the cursor.
+func (e *editor) refresh() {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K\r", e.prompt, string(e.buf))
+	if n := utf8.RuneCountInString(e.prompt) + e.pos; n > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dC", n)
+	}
+}
+
+// readKey reads one key press: a control key or escape sequence as key,
+// or a printable rune as r with key 0.
+func (e *editor) readKey() (key int, r rune, err error) {
+	r, _, err = e.in.ReadRune()
+	if err != nil {
+		return 0, 0, err
+	}
+	switch {
+	case r == keyEscape:
+		return e.readEscape(), 0, nil
+	case r < 32 || r == keyDelete:
+		return int(r), 0, nil
+	}
+	return 0, r, nil
+}
+
+// readEscape decodes the rest of an escape sequence such as ESC [ A.
+func (e *editor) readEscape() int {
+	b, err := e.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return keyUnknown
+	}
+	var seq []byte
+	for {
+		c, err := e.in.ReadByte()
+		if err != nil {
+			return keyUnknown
+		}
+		seq = append(seq, c)
+		if c >= 0x40 && c <= 0x7e {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return keyUp
+	case "B":
+		return keyDown
+	case "C":
+		return keyRight
+	case "D":
+		return keyLeft
+	case "H", "1~", "7~":
+		return keyHome
+	case "F", "4~", "8~":
+		return keyEnd
+	case "3~":
+		return keyDeleteForward
+	}
+	return keyUnknown
+}
+
+// completeWord completes the word before the cursor, or the colon
+// command at the start of the line. The candidates' common prefix is
+// inserted; when that adds nothing they are listed instead.
+func (e *editor) completeWord() {
+	start := e.pos
+	for start > 0 && isWordRune(e.buf[start-1]) {
+		start--
+	}
+	if start == 1 && e.buf[0] == ':' {
+		start = 0
+	}
+	word := string(e.buf[start:e.pos])
+	if word == "" {
+		return
+	}
+	candidates := e.complete(word)
+	if len(candidates) == 0 {
+		return
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(candidates) == 1 && strings.HasPrefix(word, ":") {
+		prefix += " "
+	}
+	if prefix == word {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+		return
+	}
+	insert := []rune(prefix[len(word):])
+	e.buf = append(e.buf[:e.pos], append(insert, e.buf[e.pos:]...)...)
+	e.pos += len(insert)
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// search runs reverse incremental search (Ctrl-R). Typing narrows the
+// search, Ctrl-R finds the next older match, Enter runs the match and any
+// other key leaves the match in the buffer for editing. done reports
+// whether the line was accepted.
+func (e *editor) search() (line string, done bool, err error) {
+	var query []rune
+	match := len(e.history) // index of the current match
+	find := func(from int) {
+		for i := from; i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				match = i
+				return
+			}
+		}
+	}
+	for {
+		found := ""
+		if match < len(e.history) {
+			found = e.history[match]
+		}
+		fmt.Fprintf(e.out, "\r(reverse-i-search)`%s': %s\x1b[K", string(query), found)
+
+		key, r, err := e.readKey()
+		if err != nil {
+			return "", true, err
+		}
+		switch key {
+		case keyCtrlR:
+			if match > 0 {
+				find(match - 1)
+			}
+		case keyBackspace, keyDelete:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history) - 1)
+			}
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", true, ErrInterrupted
+		case keyCtrlG:
+			return "", false, nil
+		case keyCR, keyLF:
+			fmt.Fprint(e.out, "\r\n")
+			return found, true, nil
+		case 0:
+			query = append(query, r)
+			find(min(match, len(e.history)-1))
+		default:
+			e.buf, e.pos = []rune(found), len([]rune(found))
+			return "", false, nil
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package repl
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEditor(t *testing.T) {
+	complete := func(word string) []string {
+		var out []string
+		for _, name := range []string{"print", "push", "pop", "reverse", ":reload", ":reset"} {
+			if strings.HasPrefix(name, word) {
+				out = append(out, name)
+			}
+		}
+		return out
+	}
+	keys := strings.Join([]string{
+		"let x = 1\r",
+		"rev\t([1])\r",       // single completion
+		"p\tush(a)\r",        // ambiguous and nothing to add, so listed
+		":rel\t\r",           // colon command
+		"abc\x1b[D\x1b[DX\r", // left arrow twice, insert
+		"hello\x01>\x05<\r",  // Ctrl-A, Ctrl-E
+		"\x1b[A\x1b[A\r",     // history: two back
+		"\x12let\r",          // reverse search
+		"junk\x03",           // Ctrl-C
+		"one two\x17three\r", // Ctrl-W
+		"\x04",               // Ctrl-D on an empty line
+	}, "")
+	var out strings.Builder
+	e := newEditor(strings.NewReader(keys), &out, -1, complete)
+
+	want := []string{
+		"let x = 1",
+		"reverse([1])",
+		"push(a)",
+		":reload ",
+		"aXbc",
+		">hello<",
+		"aXbc",
+		"let x = 1",
+	}
+	for i, w := range want {
+		line, err := e.ReadLine("> ")
+		if err != nil || line != w {
+			t.Fatalf("line %d = %q, %v; want %q", i, line, err, w)
+		}
+		e.AddHistory(line)
+	}
+	if _, err := e.ReadLine("> "); err != ErrInterrupted {
+		t.Errorf("Ctrl-C: err = %v, want ErrInterrupted", err)
+	}
+	if line, _ := e.ReadLine("> "); line != "one three" {
+		t.Errorf("Ctrl-W: line = %q", line)
+	}
+	if _, err := e.ReadLine("> "); err != io.EOF {
+		t.Errorf("Ctrl-D: err = %v, want io.EOF", err)
+	}
+	if !strings.Contains(out.String(), "print  push  pop") {
+		t.Errorf("ambiguous completion was not listed:\n%q", out.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "history")
+	e.historyFile = path
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadHistory(path); len(got) != len(want) || got[0] != "let x = 1" {
+		t.Errorf("history round trip = %q", got)
+	}
+}
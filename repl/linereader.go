@@ -0,0 +1,140 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrInterrupted is returned by LineReader.ReadLine when the user presses
+// Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+// LineReader reads the REPL's input one line at a time.
+type LineReader interface {
+	// ReadLine shows prompt and returns the next line without its line
+	// ending. It returns io.EOF at the end of input and ErrInterrupted
+	// when the user presses Ctrl-C.
+	ReadLine(prompt string) (string, error)
+	// AddHistory records a line the user entered.
+	AddHistory(line string)
+	// Close releases the reader and saves its history.
+	Close() error
+}
+
+// historySize is how many lines are kept in the history file.
+const historySize = 1000
+
+// DefaultHistoryFile returns ~/.glace_history, or "" if there is no home
+// directory.
+func DefaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".glace_history")
+}
+
+// newLineReader returns a line editor when in is a terminal, and a plain
+// scanner otherwise.
+func (s *session) newLineReader(in io.Reader, out io.Writer, historyFile string, interrupts <-chan struct{}) LineReader {
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		e := newEditor(f, out, int(f.Fd()), s.complete)
+		e.historyFile = historyFile
+		e.history = loadHistory(historyFile)
+		return e
+	}
+	return newScanReader(in, out, interrupts)
+}
+
+// ---------------------------------------------------------------------------
+// Plain scanning
+// ---------------------------------------------------------------------------
+
+// scanReader reads lines with a bufio.Scanner, for input that is not a
+// terminal. It keeps no history. Ctrl-C arrives as a signal, forwarded on
+// interrupts.
+type scanReader struct {
+	out        io.Writer
+	lines      chan string
+	done       chan struct{}
+	interrupts <-chan struct{}
+}
+
+func newScanReader(in io.Reader, out io.Writer, interrupts <-chan struct{}) *scanReader {
+	r := &scanReader{out: out, lines: make(chan string), done: make(chan struct{}), interrupts: interrupts}
+	go func() {
+		defer close(r.lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case r.lines <- scanner.Text():
+			case <-r.done:
+				return
+			}
+		}
+	}()
+	return r
+}
+
+func (r *scanReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	select {
+	case <-r.interrupts:
+		fmt.Fprintln(r.out)
+		return "", ErrInterrupted
+	case line, ok := <-r.lines:
+		if !ok {
+			return "", io.EOF
+		}
+		return line, nil
+	}
+}
+
+func (r *scanReader) AddHistory(string) {}
+
+func (r *scanReader) Close() error {
+	close(r.done)
+	return nil
+}
+
+// ---------------------------------------------------------------------------
+// History file
+// ---------------------------------------------------------------------------
+
+func loadHistory(path string) []string {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	if len(lines) > historySize {
+		lines = lines[len(lines)-historySize:]
+	}
+	return lines
+}
+
+func saveHistory(path string, lines []string) error {
+	if path == "" {
+		return nil
+	}
+	if len(lines) > historySize {
+		lines = lines[len(lines)-historySize:]
+	}
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o600)
+}
@@ -1,57 +1,362 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/glace-lang/glace/ast"
+	"github.com/glace-lang/glace/diag"
 	"github.com/glace-lang/glace/evaluator"
 	"github.com/glace-lang/glace/lexer"
 	"github.com/glace-lang/glace/parser"
 )
 
 const PROMPT = "glace> "
+const CONT_PROMPT = "  ...> "
 const VERSION = "0.1.0"
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
+// Options configures a REPL session. The zero value is usable.
+type Options struct {
+	// SessionDir is where sessions are saved on exit and found by Resume.
+	// Empty means DefaultSessionDir().
+	SessionDir string
+	// Resume replays the most recently saved session before the first prompt.
+	Resume bool
+	// HistoryFile keeps the line editor's history between runs. Empty
+	// means DefaultHistoryFile().
+	HistoryFile string
+	// LineReader, if set, replaces the built-in reader: a line editor when
+	// the input is a terminal, plain line scanning otherwise.
+	LineReader LineReader
+}
+
+// DefaultSessionDir returns ~/.glace/sessions, or "" if there is no home
+// directory.
+func DefaultSessionDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".glace", "sessions")
+}
+
+// session is the state of one REPL run.
+type session struct {
+	env        *evaluator.Environment
+	out        io.Writer
+	inputs     []string // accepted inputs in order, as written by :save
+	lastLoad   string   // the file :reload runs again
+	lastLoadAt int      // index of its source in inputs; -1 if not there
+
+	interrupted atomic.Bool // set by Ctrl-C, cleared once handled
+}
+
+// reset gives the session a fresh environment holding only the builtins.
+func (s *session) reset() {
 	env := evaluator.NewEnvironment()
 	evaluator.RegisterBuiltins(env)
 	evaluator.RegisterHOBuiltins(env)
+	env.Runtime().AddHooks(&evaluator.Hooks{
+		Statement: func(ast.Statement, *evaluator.Environment) error {
+			if s.interrupted.Load() {
+				return ErrInterrupted
+			}
+			return nil
+		},
+	})
+	s.env = env
+	s.inputs = nil
+	s.lastLoadAt = -1
+}
+
+func Start(in io.Reader, out io.Writer) {
+	Run(in, out, nil)
+}
+
+// Run starts a REPL reading from in. On exit the accepted inputs are saved
+// as a new session file in opts.SessionDir.
+func Run(in io.Reader, out io.Writer, opts *Options) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	dir := opts.SessionDir
+	if dir == "" {
+		dir = DefaultSessionDir()
+	}
+
+	s := &session{out: out}
+	s.reset()
+
+	// Ctrl-C stops a running program at its next statement (see reset),
+	// or discards the pending input at a prompt.
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	defer signal.Stop(sigs)
+	interrupts := make(chan struct{}, 1)
+	go func() {
+		for range sigs {
+			s.interrupted.Store(true)
+			select {
+			case interrupts <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	reader := opts.LineReader
+	if reader == nil {
+		history := opts.HistoryFile
+		if history == "" {
+			history = DefaultHistoryFile()
+		}
+		reader = s.newLineReader(in, out, history, interrupts)
+	}
+	defer reader.Close()
 
 	fmt.Fprintf(out, "Glace v%s — type 'exit' to quit\n", VERSION)
 
+	if opts.Resume {
+		if path := lastSession(dir); path == "" {
+			fmt.Fprintln(out, "  no saved session to resume")
+		} else {
+			s.load(path)
+		}
+	}
+
+	var pending []string // lines of an incomplete input
+loop:
 	for {
-		fmt.Fprint(out, PROMPT)
-		if !scanner.Scan() {
-			break
+		prompt := PROMPT
+		if len(pending) > 0 {
+			prompt = CONT_PROMPT
 		}
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
+		text, err := reader.ReadLine(prompt)
+		if err == ErrInterrupted {
+			s.interrupted.Store(false)
+			if len(pending) > 0 {
+				pending = nil
+				fmt.Fprintln(out, "  input discarded")
+			}
 			continue
 		}
-		if line == "exit" || line == "quit" {
-			fmt.Fprintln(out, "bye!")
+		if err != nil {
 			break
 		}
+		if strings.TrimSpace(text) != "" {
+			reader.AddHistory(text)
+		}
 
-		tokens := lexer.New(line, "<repl>").Tokenize()
-		program, errors := parser.Parse(tokens)
-		if len(errors) > 0 {
-			for _, e := range errors {
-				fmt.Fprintf(out, "  parse error: %s\n", e)
+		if len(pending) == 0 {
+			line := strings.TrimSpace(text)
+			if line == "" {
+				continue
+			}
+			if line == "exit" || line == "quit" {
+				fmt.Fprintln(out, "bye!")
+				break loop
+			}
+			if strings.HasPrefix(line, ":") {
+				s.command(line)
+				continue
 			}
-			continue
 		}
 
-		result, err := evaluator.Eval(program, env)
-		if err != nil {
-			fmt.Fprintf(out, "  error: %s\n", err)
+		pending = append(pending, text)
+		src := strings.Join(pending, "\n")
+		if incomplete(src) {
 			continue
 		}
-		if result != nil && result.Type() != "none" {
-			fmt.Fprintf(out, "=> %s\n", result.String())
+		pending = nil
+		src = strings.TrimSpace(src)
+		if s.eval(src, "<repl>") {
+			s.inputs = append(s.inputs, src)
+		}
+		// An interrupt that arrived while the program ran has been handled.
+		s.interrupted.Store(false)
+		select {
+		case <-interrupts:
+		default:
+		}
+	}
+
+	if len(s.inputs) > 0 && dir != "" {
+		if err := s.saveSession(dir); err != nil {
+			fmt.Fprintf(out, "  could not save session: %s\n", err)
+		}
+	}
+}
+
+// complete returns the completions of word: colon commands when it
+// starts with a colon, otherwise keywords and the names defined in the
+// session, builtins included.
+func (s *session) complete(word string) []string {
+	var names []string
+	if strings.HasPrefix(word, ":") {
+		for _, c := range commands {
+			names = append(names, c.name)
 		}
+	} else {
+		for kw := range lexer.Keywords {
+			names = append(names, kw)
+		}
+		for _, b := range s.env.Bindings() {
+			names = append(names, b.Name)
+		}
+	}
+	var out []string
+	for _, name := range names {
+		if strings.HasPrefix(name, word) {
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// incomplete reports whether src needs more lines before it can be
+// parsed: a bracket or string is left open, or the last token is a
+// binary operator, |>, =>, or a comma.
+func incomplete(src string) bool {
+	depth := 0
+	last := lexer.TOKEN_EOF
+	for _, tok := range lexer.New(src, "<repl>").Tokenize() {
+		switch tok.Type {
+		case lexer.TOKEN_LPAREN, lexer.TOKEN_LBRACE, lexer.TOKEN_LBRACKET:
+			depth++
+		case lexer.TOKEN_RPAREN, lexer.TOKEN_RBRACE, lexer.TOKEN_RBRACKET:
+			depth--
+		case lexer.TOKEN_ILLEGAL:
+			if tok.Literal == "unterminated string" {
+				return true
+			}
+		}
+		if tok.Type != lexer.TOKEN_NEWLINE && tok.Type != lexer.TOKEN_EOF {
+			last = tok.Type
+		}
+	}
+	if depth > 0 {
+		return true
+	}
+	switch last {
+	case lexer.TOKEN_PLUS, lexer.TOKEN_MINUS, lexer.TOKEN_STAR, lexer.TOKEN_SLASH,
+		lexer.TOKEN_PERCENT, lexer.TOKEN_EQ, lexer.TOKEN_NEQ, lexer.TOKEN_LT,
+		lexer.TOKEN_GT, lexer.TOKEN_LTE, lexer.TOKEN_GTE, lexer.TOKEN_AND,
+		lexer.TOKEN_OR, lexer.TOKEN_PIPE, lexer.TOKEN_DOTDOT, lexer.TOKEN_ARROW,
+		lexer.TOKEN_COALESCE, lexer.TOKEN_COMMA:
+		return true
+	}
+	return false
+}
+
+// eval parses and runs src, printing its value or errors. It reports
+// whether src ran without error.
+func (s *session) eval(src, file string) bool {
+	result, ok := s.run(src, file)
+	if ok && result != nil && result.Type() != "none" {
+		fmt.Fprintf(s.out, "=> %s\n", result.String())
+	}
+	return ok
+}
+
+// run parses and runs src, printing any errors, and returns its value.
+func (s *session) run(src, file string) (evaluator.Value, bool) {
+	program, diags := parser.ParseSource(src, file)
+	if len(diags) > 0 {
+		diag.RenderAll(s.out, diags, map[string]string{file: src})
+		return nil, false
+	}
+
+	result, err := evaluator.Eval(program, s.env)
+	if err != nil {
+		if re, ok := err.(*evaluator.RuntimeError); ok && len(re.Trace) > 0 {
+			fmt.Fprintln(s.out, evaluator.FormatTraceback(re, map[string]string{file: src}))
+			return nil, false
+		}
+		fmt.Fprintf(s.out, "  error: %s\n", err)
+		return nil, false
+	}
+	return result, true
+}
+
+// save writes the accepted inputs to path, one per line.
+func (s *session) save(path string) error {
+	return os.WriteFile(path, []byte(s.text()), 0o644)
+}
+
+// text returns the accepted inputs, one per line.
+func (s *session) text() string {
+	var b strings.Builder
+	for _, input := range s.inputs {
+		b.WriteString(input)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// maxSessions is how many session files are kept in the session directory.
+const maxSessions = 20
+
+// saveSession writes the accepted inputs to a new session file in dir and
+// removes the oldest files beyond maxSessions. Names start with the time
+// to the microsecond, so they sort by age, and end with a random suffix,
+// so sessions that exit at the same moment do not overwrite each other.
+func (s *session) saveSession(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "session-"+time.Now().Format("20060102-150405.000000")+"-*.glace")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, s.text()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	matches, _ := filepath.Glob(filepath.Join(dir, "session-*.glace"))
+	sort.Strings(matches)
+	for len(matches) > maxSessions {
+		if err := os.Remove(matches[0]); err != nil {
+			return err
+		}
+		matches = matches[1:]
+	}
+	return nil
+}
+
+// load runs the file at path in the session. Its source becomes one
+// accepted input, so a later :save includes it.
+func (s *session) load(path string) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(s.out, "  error: %s\n", err)
+		return
+	}
+	src := strings.TrimRight(string(source), "\n")
+	s.lastLoad, s.lastLoadAt = path, -1
+	if s.eval(src, path) {
+		s.lastLoadAt = len(s.inputs)
+		s.inputs = append(s.inputs, src)
+		fmt.Fprintf(s.out, "  loaded %s\n", path)
+	}
+}
+
+// lastSession returns the newest session file in dir, or "" if none.
+// Session names embed the time they were saved, so the newest sorts last.
+func lastSession(dir string) string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "session-*.glace"))
+	if len(matches) == 0 {
+		return ""
 	}
+	sort.Strings(matches)
+	return matches[len(matches)-1]
 }
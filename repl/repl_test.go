@@ -0,0 +1,186 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveAndResume(t *testing.T) {
+	dir := t.TempDir()
+	saved := filepath.Join(dir, "mine.glace")
+
+	var out strings.Builder
+	Run(strings.NewReader("fn sq(x) => x * x\nlet oops = \nlet n = sq(3)\n:save "+saved+"\n"), &out, &Options{SessionDir: dir})
+	if !strings.Contains(out.String(), "saved 2 inputs") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+
+	out.Reset()
+	Run(strings.NewReader(":load "+saved+"\nsq(n)\n"), &out, &Options{SessionDir: t.TempDir()})
+	if !strings.Contains(out.String(), "=> 81") {
+		t.Errorf(":load did not restore definitions:\n%s", out.String())
+	}
+
+	out.Reset()
+	Run(strings.NewReader("sq(n) + 1\n"), &out, &Options{SessionDir: dir, Resume: true})
+	if !strings.Contains(out.String(), "=> 82") {
+		t.Errorf("--resume did not restore the session:\n%s", out.String())
+	}
+}
+
+func TestSessionFilesAreUniqueAndBounded(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < maxSessions; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("session-20000101-0000%02d.glace", i))
+		if err := os.WriteFile(name, []byte("let old = 1\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, input := range []string{"let a = 1\n", "let b = 2\n"} {
+		Run(strings.NewReader(input), io.Discard, &Options{SessionDir: dir})
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(dir, "session-*.glace"))
+	if len(matches) != maxSessions {
+		t.Errorf("expected %d session files, got %d", maxSessions, len(matches))
+	}
+	var saved []string
+	for _, m := range matches {
+		data, _ := os.ReadFile(m)
+		if !strings.Contains(string(data), "old") {
+			saved = append(saved, string(data))
+		}
+	}
+	if len(saved) != 2 || saved[0] != "let a = 1\n" || saved[1] != "let b = 2\n" {
+		t.Errorf("both sessions should be kept, in order: %q", saved)
+	}
+	if last := lastSession(dir); !strings.HasSuffix(readFile(t, last), "let b = 2\n") {
+		t.Errorf("lastSession picked %s", last)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := `fn add(a, b) {
+    return a + b
+}
+[1, 2, 3] |>
+    map(fn(x) => x * 10) |>
+    reduce(0, add)
+let s = "two
+lines"
+len(s)
+`
+	var out strings.Builder
+	Run(strings.NewReader(input), &out, &Options{SessionDir: t.TempDir()})
+	got := out.String()
+	for _, want := range []string{"=> 60", "=> 9", CONT_PROMPT} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "error") {
+		t.Errorf("unexpected error:\n%s", got)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lib.glace")
+	if err := os.WriteFile(file, []byte("/// Twice x.\nfn double(x) => x * 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Join([]string{
+		"mut count = 3",
+		":env",
+		":type count + 0.5",
+		":ast 1 + 2",
+		":time count * 2",
+		":doc push",
+		":load " + file,
+		":doc double",
+		":reset",
+		":env",
+		":reload",
+		"double(4)",
+		":help",
+		":nope",
+	}, "\n")
+
+	var out strings.Builder
+	Run(strings.NewReader(input), &out, &Options{SessionDir: t.TempDir()})
+	got := out.String()
+	for _, want := range []string{
+		"mut count: int = 3",
+		"  float\n",
+		"BinaryExpression @1:3",
+		"=> 6\n  took ",
+		"push(arr, val)\n    Append val to arr.",
+		"double(x)\n    Twice x.",
+		"environment reset\nglace>   nothing defined yet",
+		"=> 8",
+		":reload",
+		"unknown command :nope",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestReloadReplacesTheLoadedFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lib.glace")
+	write := func(src string) {
+		if err := os.WriteFile(file, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var out strings.Builder
+	s := &session{out: &out}
+	s.reset()
+	s.env.Runtime().SetStdout(&out)
+
+	write("let factor = 2\nfn scale(x) => x * factor\n")
+	s.command(":load " + file)
+	for _, src := range []string{`print("scaling")`, "let y = scale(5)"} {
+		if s.eval(src, "<repl>") {
+			s.inputs = append(s.inputs, src)
+		}
+	}
+	write("let factor = 3\nfn scale(x) => x * factor\n")
+	out.Reset()
+	s.command(":reload")
+
+	if got := out.String(); strings.Contains(got, "error") || strings.Contains(got, "scaling") {
+		t.Errorf(":reload failed or repeated output:\n%s", got)
+	}
+	if y, _ := s.env.Get("y"); y == nil || y.String() != "15" {
+		t.Errorf("y = %v after :reload, want 15", y)
+	}
+	if len(s.inputs) != 3 || s.lastLoadAt != 0 || !strings.Contains(s.inputs[0], "factor = 3") {
+		t.Errorf("inputs after :reload: %q (file at %d)", s.inputs, s.lastLoadAt)
+	}
+
+	// An input that no longer runs is reported and dropped.
+	write("let factor = 3\n")
+	out.Reset()
+	s.command(":reload")
+	if got := out.String(); !strings.Contains(got, "undefined variable 'scale'") ||
+		!strings.Contains(got, "dropped from the session: let y = scale(5)") {
+		t.Errorf("replay failure not reported:\n%s", got)
+	}
+	if len(s.inputs) != 2 {
+		t.Errorf("inputs after a failed replay: %q", s.inputs)
+	}
+}
@@ -0,0 +1,329 @@
+// Package testreport prints the results of `glace test` as text for
+// people, or as JSON, TAP or JUnit XML for CI systems.
+package testreport
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/lexer"
+)
+
+// Formats lists the names accepted by Write.
+var Formats = []string{"text", "json", "tap", "junit"}
+
+// Suite is the results of the tests in one file.
+type Suite struct {
+	File    string
+	Results []evaluator.TestResult
+	Output  string // printed by the file outside its tests
+	Error   string // why the tests could not run, such as a syntax error
+}
+
+// Write prints suites in format, which is one of Formats. verbose adds
+// each test's duration to the text format; the others always include it.
+func Write(w io.Writer, format string, suites []Suite, verbose bool) error {
+	switch format {
+	case "text":
+		return Text(w, suites, verbose)
+	case "json":
+		return JSON(w, suites)
+	case "tap":
+		return TAP(w, suites)
+	case "junit":
+		return JUnit(w, suites)
+	}
+	return fmt.Errorf("unknown test report format %q", format)
+}
+
+// counts returns how many tests passed and failed, and how many files
+// could not run.
+func counts(suites []Suite) (passed, failed, errors int) {
+	for _, s := range suites {
+		if s.Error != "" {
+			errors++
+		}
+		for _, r := range s.Results {
+			if r.Passed {
+				passed++
+			} else {
+				failed++
+			}
+		}
+	}
+	return passed, failed, errors
+}
+
+// Failed reports whether any test failed or any file could not run.
+func Failed(suites []Suite) bool {
+	_, failed, errors := counts(suites)
+	return failed > 0 || errors > 0
+}
+
+// Text prints a PASS or FAIL line per test, the output of failed tests,
+// and a count of each. With several suites, each starts with its file.
+func Text(w io.Writer, suites []Suite, verbose bool) error {
+	var b strings.Builder
+	for _, s := range suites {
+		if len(suites) > 1 {
+			fmt.Fprintf(&b, "%s\n", s.File)
+		}
+		b.WriteString(s.Output)
+		if s.Error != "" {
+			fmt.Fprintf(&b, "  ERROR: %s\n", s.Error)
+		}
+		for _, r := range s.Results {
+			took := ""
+			if verbose {
+				took = fmt.Sprintf(" (%s)", r.Duration.Round(time.Microsecond))
+			}
+			if r.Passed {
+				fmt.Fprintf(&b, "  PASS: %s%s\n", r.Name, took)
+				continue
+			}
+			// Assertion diffs continue on further lines.
+			fmt.Fprintf(&b, "  FAIL: %s%s — %s\n", r.Name, took, strings.ReplaceAll(r.Error, "\n", "\n    "))
+			if r.Output != "" {
+				b.WriteString("    output:\n")
+				for _, line := range strings.Split(strings.TrimSuffix(r.Output, "\n"), "\n") {
+					fmt.Fprintf(&b, "      %s\n", line)
+				}
+			}
+		}
+	}
+	passed, failed, errors := counts(suites)
+	fmt.Fprintf(&b, "\n%d passed, %d failed", passed, failed)
+	if len(suites) > 1 {
+		fmt.Fprintf(&b, " in %d files", len(suites))
+	}
+	if errors > 0 {
+		fmt.Fprintf(&b, ", %d could not run", errors)
+	}
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ---------------------------------------------------------------------------
+// JSON
+// ---------------------------------------------------------------------------
+
+type jsonReport struct {
+	Passed int        `json:"passed"`
+	Failed int        `json:"failed"`
+	Files  []jsonFile `json:"files"`
+	Tests  []jsonTest `json:"tests"`
+}
+
+type jsonFile struct {
+	File   string `json:"file"`
+	Error  string `json:"error,omitempty"`
+	Output string `json:"output,omitempty"`
+}
+
+type jsonTest struct {
+	Name       string          `json:"name"`
+	Pos        lexer.Position  `json:"pos"`
+	Passed     bool            `json:"passed"`
+	DurationMs float64         `json:"duration_ms"`
+	Error      string          `json:"error,omitempty"`
+	Failure    *lexer.Position `json:"failure,omitempty"`
+	Output     string          `json:"output,omitempty"`
+}
+
+// JSON prints one object with the pass and fail counts, the files that
+// ran, and every test.
+func JSON(w io.Writer, suites []Suite) error {
+	report := jsonReport{Files: []jsonFile{}, Tests: []jsonTest{}}
+	report.Passed, report.Failed, _ = counts(suites)
+	for _, s := range suites {
+		report.Files = append(report.Files, jsonFile{File: s.File, Error: s.Error, Output: s.Output})
+		for _, r := range s.Results {
+			t := jsonTest{
+				Name:       r.Name,
+				Pos:        r.Pos,
+				Passed:     r.Passed,
+				DurationMs: milliseconds(r.Duration),
+				Error:      r.Error,
+				Output:     r.Output,
+			}
+			if !r.Passed {
+				failure := r.FailurePos
+				t.Failure = &failure
+			}
+			report.Tests = append(report.Tests, t)
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// ---------------------------------------------------------------------------
+// TAP
+// ---------------------------------------------------------------------------
+
+// TAP prints the Test Anything Protocol, version 13. Failures carry a
+// YAML block with the message, location and output. A file that could
+// not run is a failed test point of its own, and what a file printed
+// outside its tests is shown as comments.
+func TAP(w io.Writer, suites []Suite) error {
+	var b strings.Builder
+	passed, failed, errors := counts(suites)
+	fmt.Fprintf(&b, "TAP version 13\n1..%d\n", passed+failed+errors)
+	n := 0
+	for _, s := range suites {
+		if s.Output != "" {
+			for _, line := range strings.Split(strings.TrimSuffix(s.Output, "\n"), "\n") {
+				fmt.Fprintf(&b, "# %s\n", line)
+			}
+		}
+		if s.Error != "" {
+			n++
+			fmt.Fprintf(&b, "not ok %d - %s\n", n, tapEscape(s.File))
+			fmt.Fprintf(&b, "  ---\n  message: %s\n  ...\n", yamlString(s.Error))
+		}
+		for _, r := range s.Results {
+			n++
+			if r.Passed {
+				fmt.Fprintf(&b, "ok %d - %s\n", n, tapEscape(r.Name))
+				continue
+			}
+			fmt.Fprintf(&b, "not ok %d - %s\n", n, tapEscape(r.Name))
+			b.WriteString("  ---\n")
+			fmt.Fprintf(&b, "  message: %s\n", yamlString(r.Error))
+			fmt.Fprintf(&b, "  at: %s\n", yamlString(r.FailurePos.String()))
+			fmt.Fprintf(&b, "  duration_ms: %g\n", milliseconds(r.Duration))
+			if r.Output != "" {
+				b.WriteString("  output: |\n")
+				for _, line := range strings.Split(strings.TrimSuffix(r.Output, "\n"), "\n") {
+					fmt.Fprintf(&b, "    %s\n", line)
+				}
+			}
+			b.WriteString("  ...\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// tapEscape escapes the characters that would end a test's description.
+func tapEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "#", `\#`, "\n", " ").Replace(s)
+}
+
+// yamlString quotes s as a YAML double-quoted scalar, which accepts JSON
+// string syntax.
+func yamlString(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// ---------------------------------------------------------------------------
+// JUnit
+// ---------------------------------------------------------------------------
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Errors   int          `xml:"errors,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Errors    int         `xml:"errors,attr"`
+	Time      string      `xml:"time,attr"`
+	Cases     []junitCase `xml:"testcase"`
+	SystemOut string      `xml:"system-out,omitempty"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr,omitempty"`
+	Line      int           `xml:"line,attr,omitempty"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure"`
+	Error     *junitFailure `xml:"error"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// JUnit prints JUnit XML with a testsuite per file, as read by most CI
+// servers. A file that could not run holds one testcase with an error.
+// Times are in seconds.
+func JUnit(w io.Writer, suites []Suite) error {
+	report := junitSuites{}
+	var total time.Duration
+	for _, s := range suites {
+		suite := junitSuite{Name: s.File, SystemOut: s.Output}
+		if s.Error != "" {
+			suite.Cases = append(suite.Cases, junitCase{
+				Name:      s.File,
+				Classname: s.File,
+				Time:      seconds(0),
+				Error:     &junitFailure{Message: s.Error, Type: "error", Text: s.Error},
+			})
+			suite.Errors++
+		}
+		var elapsed time.Duration
+		for _, r := range s.Results {
+			c := junitCase{
+				Name:      r.Name,
+				Classname: s.File,
+				File:      r.Pos.File,
+				Line:      r.Pos.Line,
+				Time:      seconds(r.Duration),
+				SystemOut: r.Output,
+			}
+			if !r.Passed {
+				c.Failure = &junitFailure{Message: r.Error, Type: "failure", Text: r.Error}
+				suite.Failures++
+			}
+			suite.Cases = append(suite.Cases, c)
+			elapsed += r.Duration
+		}
+		suite.Tests = len(suite.Cases)
+		suite.Time = seconds(elapsed)
+		report.Suites = append(report.Suites, suite)
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		total += elapsed
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,128 @@
+package testreport
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glace-lang/glace/evaluator"
+	"github.com/glace-lang/glace/lexer"
+)
+
+var suites = []Suite{{
+	File: "math.glace",
+	Results: []evaluator.TestResult{
+		{Name: "adds", Pos: lexer.Position{File: "math.glace", Line: 2, Column: 1}, Passed: true, Duration: 1500 * time.Microsecond},
+		{
+			Name:       "divides # by zero",
+			Pos:        lexer.Position{File: "math.glace", Line: 5, Column: 1},
+			Duration:   2 * time.Millisecond,
+			Error:      "division by zero",
+			FailurePos: lexer.Position{File: "math.glace", Line: 6, Column: 12},
+			Output:     "dividing\n",
+		},
+	},
+}}
+
+func TestText(t *testing.T) {
+	expected := `  PASS: adds (1.5ms)
+  FAIL: divides # by zero (2ms) — division by zero
+    output:
+      dividing
+
+1 passed, 1 failed
+`
+	var b strings.Builder
+	Text(&b, suites, true)
+	if b.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
+
+func TestTAP(t *testing.T) {
+	expected := `TAP version 13
+1..2
+ok 1 - adds
+not ok 2 - divides \# by zero
+  ---
+  message: "division by zero"
+  at: "math.glace:6:12"
+  duration_ms: 2
+  output: |
+    dividing
+  ...
+`
+	var b strings.Builder
+	TAP(&b, suites)
+	if b.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, b.String())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	var b strings.Builder
+	JSON(&b, suites)
+	var report jsonReport
+	if err := json.Unmarshal([]byte(b.String()), &report); err != nil {
+		t.Fatalf("invalid JSON: %s\n%s", err, b.String())
+	}
+	if report.Passed != 1 || report.Failed != 1 || len(report.Tests) != 2 {
+		t.Fatalf("wrong counts: %s", b.String())
+	}
+	failed := report.Tests[1]
+	if failed.Failure == nil || *failed.Failure != suites[0].Results[1].FailurePos || failed.DurationMs != 2 {
+		t.Errorf("wrong failure: %+v", failed)
+	}
+	if report.Tests[0].Failure != nil {
+		t.Errorf("passing test has a failure location: %+v", report.Tests[0])
+	}
+}
+
+func TestJUnit(t *testing.T) {
+	var b strings.Builder
+	JUnit(&b, suites)
+	var report junitSuites
+	if err := xml.Unmarshal([]byte(b.String()), &report); err != nil {
+		t.Fatalf("invalid XML: %s\n%s", err, b.String())
+	}
+	if report.Tests != 2 || report.Failures != 1 || report.Time != "0.004" {
+		t.Errorf("wrong totals: %s", b.String())
+	}
+	cases := report.Suites[0].Cases
+	if cases[0].Failure != nil || cases[1].Failure == nil || cases[1].Failure.Message != "division by zero" {
+		t.Errorf("wrong failures: %s", b.String())
+	}
+	if cases[1].Line != 5 || cases[1].SystemOut != "dividing\n" {
+		t.Errorf("wrong testcase: %+v", cases[1])
+	}
+}
+
+func TestTextSeveralFiles(t *testing.T) {
+	several := append([]Suite{
+		{File: "setup.glace", Output: "loading\n", Results: []evaluator.TestResult{{Name: "loads", Passed: true}}},
+		{File: "broken.glace", Error: "broken.glace:1:9: unexpected end of line"},
+	}, suites...)
+	expected := `setup.glace
+loading
+  PASS: loads
+broken.glace
+  ERROR: broken.glace:1:9: unexpected end of line
+math.glace
+  PASS: adds
+  FAIL: divides # by zero — division by zero
+    output:
+      dividing
+
+2 passed, 1 failed in 3 files, 1 could not run
+`
+	var b strings.Builder
+	Text(&b, several, false)
+	if b.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, b.String())
+	}
+	if Failed(several[:1]) || !Failed(several[1:2]) {
+		t.Errorf("Failed should only report the suite that could not run")
+	}
+}